@@ -12,6 +12,7 @@ import (
 	"github.com/yourusername/go-rag/internal/config"
 	"github.com/yourusername/go-rag/internal/database"
 	"github.com/yourusername/go-rag/internal/embeddings"
+	"github.com/yourusername/go-rag/internal/prompt"
 	"github.com/yourusername/go-rag/internal/service"
 )
 
@@ -36,7 +37,7 @@ func main() {
 	}
 
 	// Initialize database connection
-	db, err := database.NewPostgresVectorDB(cfg.Database.ConnectionString(), cfg.Embeddings.Dimensions)
+	db, err := database.NewPostgresVectorDB(cfg.Database.ConnectionString(), cfg.EmbeddingModel())
 	if err != nil {
 		log.Fatalf("Failed to create database connection: %v", err)
 	}
@@ -47,14 +48,85 @@ func main() {
 	}
 	defer db.Close()
 
+	// Searches only use embeddings of the configured model, which is activated
+	// on first use and must be the active model
+	if store, ok := db.(database.EmbeddingIndexStore); ok {
+		active, err := store.EnsureActiveEmbeddingModel(ctx, cfg.EmbeddingModel())
+		if err != nil {
+			log.Fatalf("Failed to get the active embedding model: %v", err)
+		}
+		if active != cfg.EmbeddingModel() {
+			log.Fatalf("Configured embedding model %s is not the active embedding model %s; "+
+				"configure the active model or run the reindex command with %s", cfg.EmbeddingModel(), active, cfg.EmbeddingModel())
+		}
+	}
+
 	// Initialize embedding service
-	embeddingService, err := embeddings.NewGeminiEmbeddingService(&cfg.Gemini)
+	embeddingService, err := embeddings.NewEmbeddingService(cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize embedding service: %v", err)
 	}
 
+	// Cache embeddings in memory and optionally in the database
+	var embeddingCache database.EmbeddingCacheStore
+	if cfg.Embeddings.PersistentCache {
+		store, ok := db.(database.EmbeddingCacheStore)
+		if !ok {
+			log.Fatalf("Database does not support the embedding cache")
+		}
+		embeddingCache = store
+	}
+	if cfg.Embeddings.CacheSize > 0 || embeddingCache != nil {
+		embeddingService = embeddings.NewCachedEmbeddingService(
+			embeddingService, cfg.EmbeddingModel().String(), cfg.Embeddings.CacheSize, embeddingCache)
+	}
+
+	// Load and validate prompt templates
+	templates, err := prompt.LoadTemplates(cfg.Prompt.TemplatesDir)
+	if err != nil {
+		log.Fatalf("Failed to load prompt templates: %v", err)
+	}
+	if err := templates.SetDefault(cfg.Prompt.DefaultTemplate); err != nil {
+		log.Fatalf("Failed to set default prompt template: %v", err)
+	}
+	for collection, name := range cfg.Prompt.CollectionTemplates {
+		if err := templates.SetCollectionTemplate(collection, name); err != nil {
+			log.Fatalf("Failed to set prompt template of collection: %v", err)
+		}
+	}
+	log.Printf("Loaded prompt templates: %v", templates.Names())
+
+	// The PostgreSQL database also stores chat sessions
+	conversations, ok := db.(database.ConversationStore)
+	if !ok {
+		log.Fatalf("Database does not support chat sessions")
+	}
+
+	// Initialize the text generator and the optional reranker using it
+	generator, err := service.NewGenerator(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize generator: %v", err)
+	}
+	reranker, err := service.NewReranker(cfg.Reranker, generator)
+	if err != nil {
+		log.Fatalf("Failed to initialize reranker: %v", err)
+	}
+	if reranker != nil {
+		log.Printf("Reranking %d candidates with %s reranker", cfg.Reranker.Candidates, cfg.Reranker.Type)
+	}
+
 	// Initialize RAG service
-	ragService, err := service.NewRAGService(db, embeddingService, &cfg.Gemini)
+	ragService, err := service.NewRAGService(
+		db,
+		embeddingService,
+		&cfg.Gemini,
+		service.WithPromptTemplates(templates),
+		service.WithConversationStore(conversations),
+		service.WithGenerator(generator),
+		service.WithReranker(reranker, cfg.Reranker.Candidates),
+		service.WithGroundedness(cfg.Groundedness),
+		service.WithAnswerCache(service.NewAnswerCache(cfg.AnswerCache)),
+	)
 	if err != nil {
 		log.Fatalf("Failed to initialize RAG service: %v", err)
 	}
@@ -6,6 +6,8 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -22,15 +24,25 @@ var (
 	chunkStrategy string
 	chunkSize     int
 	chunkOverlap  int
+	sizeUnit      string
+	minChunkSize  int
+	percentile    float64
+	separators    string
+	forceReindex  bool
 )
 
 func init() {
 	// Define command line flags
 	flag.StringVar(&dataDir, "dir", "", "Directory containing document files to load")
 	flag.StringVar(&filePath, "file", "", "Single document file to load")
-	flag.StringVar(&chunkStrategy, "strategy", "paragraph", "Chunking strategy (paragraph, sentence, fixed_size)")
-	flag.IntVar(&chunkSize, "chunk-size", 1000, "Maximum size of chunks in characters")
-	flag.IntVar(&chunkOverlap, "chunk-overlap", 100, "Overlap between chunks in characters")
+	flag.StringVar(&chunkStrategy, "strategy", "paragraph", "Chunking strategy (paragraph, sentence, fixed_size, semantic, recursive, code)")
+	flag.IntVar(&chunkSize, "chunk-size", 1000, "Maximum size of chunks in size units")
+	flag.IntVar(&chunkOverlap, "chunk-overlap", 100, "Overlap between chunks in size units")
+	flag.StringVar(&sizeUnit, "size-unit", "chars", "Unit for chunk size and overlap (chars, tokens)")
+	flag.IntVar(&minChunkSize, "min-chunk-size", 200, "Minimum size of semantic chunks in size units")
+	flag.Float64Var(&percentile, "semantic-percentile", 10, "Similarity percentile below which the semantic strategy splits")
+	flag.BoolVar(&forceReindex, "force", false, "Re-index files even if their content is unchanged")
+	flag.StringVar(&separators, "separators", "", "Comma-separated, Go-escaped separators for the recursive strategy, coarsest first (e.g. '\\n\\n,\\n,. , ')")
 }
 
 func main() {
@@ -62,7 +74,7 @@ func main() {
 	}
 
 	// Initialize database connection
-	db, err := database.NewPostgresVectorDB(cfg.Database.ConnectionString(), cfg.Embeddings.Dimensions)
+	db, err := database.NewPostgresVectorDB(cfg.Database.ConnectionString(), cfg.EmbeddingModel())
 	if err != nil {
 		log.Fatalf("Failed to create database connection: %v", err)
 	}
@@ -73,12 +85,42 @@ func main() {
 	}
 	defer db.Close()
 
+	// Documents are only embedded with the configured model, which is
+	// activated on first use and must be the active model
+	if store, ok := db.(database.EmbeddingIndexStore); ok {
+		active, err := store.EnsureActiveEmbeddingModel(ctx, cfg.EmbeddingModel())
+		if err != nil {
+			log.Fatalf("Failed to get the active embedding model: %v", err)
+		}
+		if active != cfg.EmbeddingModel() {
+			log.Fatalf("Configured embedding model %s is not the active embedding model %s; "+
+				"configure the active model to load documents", cfg.EmbeddingModel(), active)
+		}
+	}
+
 	// Initialize embedding service
-	embeddingService, err := embeddings.NewGeminiEmbeddingService(&cfg.Gemini)
+	embeddingService, err := embeddings.NewEmbeddingService(cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize embedding service: %v", err)
 	}
 
+	// Cache embeddings in memory and optionally in the database, so that
+	// re-indexing only embeds new chunks
+	var embeddingCache database.EmbeddingCacheStore
+	if cfg.Embeddings.PersistentCache {
+		store, ok := db.(database.EmbeddingCacheStore)
+		if !ok {
+			log.Fatalf("Database does not support the embedding cache")
+		}
+		embeddingCache = store
+	}
+	var cachedEmbeddings *embeddings.CachedEmbeddingService
+	if cfg.Embeddings.CacheSize > 0 || embeddingCache != nil {
+		cachedEmbeddings = embeddings.NewCachedEmbeddingService(
+			embeddingService, cfg.EmbeddingModel().String(), cfg.Embeddings.CacheSize, embeddingCache)
+		embeddingService = cachedEmbeddings
+	}
+
 	// Convert chunking strategy string to the appropriate enum
 	var chunkingStrategy loader.ChunkingStrategy
 	switch chunkStrategy {
@@ -88,19 +130,42 @@ func main() {
 		chunkingStrategy = loader.BySentence
 	case "fixed_size":
 		chunkingStrategy = loader.ByFixedSize
+	case "semantic":
+		chunkingStrategy = loader.BySemantic
+	case "recursive":
+		chunkingStrategy = loader.ByRecursive
+	case "code":
+		chunkingStrategy = loader.ByCode
 	default:
 		log.Fatalf("Unknown chunking strategy: %s", chunkStrategy)
 	}
 
+	// Convert size unit string to the appropriate enum
+	var chunkSizeUnit loader.SizeUnit
+	switch sizeUnit {
+	case "chars":
+		chunkSizeUnit = loader.SizeInCharacters
+	case "tokens":
+		chunkSizeUnit = loader.SizeInTokens
+	default:
+		log.Fatalf("Unknown size unit: %s", sizeUnit)
+	}
+
 	// Initialize chunking options
 	chunkingOptions := loader.ChunkingOptions{
-		Strategy:     chunkingStrategy,
-		MaxChunkSize: chunkSize,
-		ChunkOverlap: chunkOverlap,
+		Strategy:           chunkingStrategy,
+		MaxChunkSize:       chunkSize,
+		ChunkOverlap:       chunkOverlap,
+		SizeUnit:           chunkSizeUnit,
+		MaxInputTokens:     cfg.Embeddings.MaxInputTokens,
+		MinChunkSize:       minChunkSize,
+		SemanticPercentile: percentile,
+		Separators:         parseSeparators(separators),
 	}
 
 	// Initialize document loader
 	documentLoader := loader.NewDocumentLoader(db, embeddingService, chunkingOptions)
+	documentLoader.SetForceReindex(forceReindex)
 
 	// Start the loading process
 	startTime := time.Now()
@@ -134,4 +199,30 @@ func main() {
 	// Report completion
 	elapsed := time.Since(startTime)
 	log.Printf("Document loading completed in %v", elapsed)
+	if cachedEmbeddings != nil {
+		stats := cachedEmbeddings.Stats()
+		log.Printf("Embeddings: %d generated, %d from memory, %d from the database cache",
+			stats.Misses, stats.MemoryHits, stats.StoreHits)
+	}
+}
+
+// parseSeparators parses a comma-separated list of separators, interpreting
+// Go escape sequences such as \n in each of them
+func parseSeparators(value string) []string {
+	if value == "" {
+		return nil
+	}
+
+	var result []string
+	for _, raw := range strings.Split(value, ",") {
+		sep, err := strconv.Unquote(`"` + raw + `"`)
+		if err != nil {
+			log.Fatalf("Invalid separator %q: %v", raw, err)
+		}
+		if sep != "" {
+			result = append(result, sep)
+		}
+	}
+
+	return result
 }
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/yourusername/go-rag/internal/config"
+	"github.com/yourusername/go-rag/internal/database"
+	"github.com/yourusername/go-rag/internal/embeddings"
+	"github.com/yourusername/go-rag/internal/eval"
+	"github.com/yourusername/go-rag/internal/models"
+	"github.com/yourusername/go-rag/internal/prompt"
+	"github.com/yourusername/go-rag/internal/service"
+)
+
+// CLI flags
+var (
+	datasetPath          string
+	outputPath           string
+	baselinePath         string
+	label                string
+	k                    int
+	answer               bool
+	judge                bool
+	retrievalMode        string
+	hydeIncludeQuery     bool
+	mmr                  bool
+	mmrLambda            float64
+	mmrCandidates        int
+	multiQuery           int
+	neighborChunks       int
+	template             string
+	maxRegression        float64
+	maxLatencyRegression float64
+)
+
+func init() {
+	// Define command line flags
+	flag.StringVar(&datasetPath, "dataset", "", "JSONL dataset of questions with expected sources")
+	flag.StringVar(&outputPath, "out", "eval-report.json", "File the JSON report is written to")
+	flag.StringVar(&baselinePath, "baseline", "", "Report of a previous run to compare with")
+	flag.StringVar(&label, "label", "", "Description of the evaluated configuration")
+	flag.IntVar(&k, "k", 5, "Number of retrieved documents the metrics are computed for")
+	flag.BoolVar(&answer, "answer", false, "Also generate an answer for every question")
+	flag.BoolVar(&judge, "judge", false, "Grade answers for faithfulness and relevance with Gemini (implies -answer)")
+	flag.StringVar(&retrievalMode, "mode", "vector", "Retrieval mode (vector, hyde)")
+	flag.BoolVar(&hydeIncludeQuery, "hyde-include-query", false, "Average the HyDE embedding with the query embedding")
+	flag.BoolVar(&mmr, "mmr", false, "Diversify results with maximal marginal relevance")
+	flag.Float64Var(&mmrLambda, "mmr-lambda", models.DefaultMMRLambda, "MMR weight of relevance against diversity")
+	flag.IntVar(&mmrCandidates, "mmr-candidates", 0, "MMR candidate pool size (0 = 4 times k)")
+	flag.IntVar(&multiQuery, "multi-query", 0, "Number of alternative queries generated and searched for every question")
+	flag.IntVar(&neighborChunks, "neighbor-chunks", 0, "Neighboring chunks added around hits for answers")
+	flag.StringVar(&template, "template", "", "Prompt template used for answers")
+	flag.Float64Var(&maxRegression, "max-regression", 0.01, "Largest tolerated drop of a quality metric compared with the baseline")
+	flag.Float64Var(&maxLatencyRegression, "max-latency-regression", -1, "Largest tolerated relative growth of p95 latency compared with the baseline (negative = ignore)")
+}
+
+func main() {
+	// Parse command-line flags
+	flag.Parse()
+
+	// Validate input
+	if datasetPath == "" {
+		log.Fatal("-dataset must be specified")
+	}
+
+	// Set up context with cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Set up signal handling
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigChan
+		log.Printf("Received signal: %v, shutting down gracefully", sig)
+		cancel()
+	}()
+
+	cases, err := eval.LoadDataset(datasetPath)
+	if err != nil {
+		log.Fatalf("Failed to load dataset: %v", err)
+	}
+
+	// Load the baseline before running so that a bad path fails fast
+	var baseline *eval.Report
+	if baselinePath != "" {
+		if baseline, err = eval.LoadReport(baselinePath); err != nil {
+			log.Fatalf("Failed to load baseline: %v", err)
+		}
+	}
+
+	// Load configuration
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Initialize database connection
+	db, err := database.NewPostgresVectorDB(cfg.Database.ConnectionString(), cfg.EmbeddingModel())
+	if err != nil {
+		log.Fatalf("Failed to create database connection: %v", err)
+	}
+
+	// Connect to the database
+	if err := db.Connect(ctx); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	// Searches only use embeddings of the configured model, which must be the active model
+	if store, ok := db.(database.EmbeddingIndexStore); ok {
+		active, err := store.EnsureActiveEmbeddingModel(ctx, cfg.EmbeddingModel())
+		if err != nil {
+			log.Fatalf("Failed to get the active embedding model: %v", err)
+		}
+		if active != cfg.EmbeddingModel() {
+			log.Fatalf("Configured embedding model %s is not the active embedding model %s", cfg.EmbeddingModel(), active)
+		}
+	}
+
+	// Initialize embedding service
+	embeddingService, err := embeddings.NewEmbeddingService(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize embedding service: %v", err)
+	}
+
+	// Load prompt templates
+	templates, err := prompt.LoadTemplates(cfg.Prompt.TemplatesDir)
+	if err != nil {
+		log.Fatalf("Failed to load prompt templates: %v", err)
+	}
+	if err := templates.SetDefault(cfg.Prompt.DefaultTemplate); err != nil {
+		log.Fatalf("Failed to set default prompt template: %v", err)
+	}
+
+	// Initialize the generator, the reranker and the RAG service as the API does
+	generator, err := service.NewGenerator(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize generator: %v", err)
+	}
+	reranker, err := service.NewReranker(cfg.Reranker, generator)
+	if err != nil {
+		log.Fatalf("Failed to initialize reranker: %v", err)
+	}
+	ragService, err := service.NewRAGService(
+		db,
+		embeddingService,
+		&cfg.Gemini,
+		service.WithPromptTemplates(templates),
+		service.WithGenerator(generator),
+		service.WithReranker(reranker, cfg.Reranker.Candidates),
+		service.WithGroundedness(cfg.Groundedness),
+	)
+	if err != nil {
+		log.Fatalf("Failed to initialize RAG service: %v", err)
+	}
+
+	options := eval.Options{
+		K:      k,
+		Answer: answer,
+		Label:  label,
+		Query: models.RAGQuery{
+			RetrievalOptions: models.RetrievalOptions{
+				Mode:             models.RetrievalMode(retrievalMode),
+				HyDEIncludeQuery: hydeIncludeQuery,
+				MMR:              mmr,
+				MMRLambda:        &mmrLambda,
+				MMRCandidates:    mmrCandidates,
+			},
+			MultiQuery:     multiQuery,
+			NeighborChunks: neighborChunks,
+			Template:       template,
+		},
+	}
+	if err := options.Query.RetrievalOptions.Validate(); err != nil {
+		log.Fatalf("Invalid retrieval options: %v", err)
+	}
+	if judge {
+		options.Judge = eval.NewLLMJudge(generator)
+	}
+
+	// Run the evaluation
+	log.Printf("Evaluating %d cases from %s", len(cases), datasetPath)
+	report, err := eval.NewRunner(ragService, options).Run(ctx, cases)
+	if err != nil {
+		log.Fatalf("Failed to run evaluation: %v", err)
+	}
+
+	if err := report.Save(outputPath); err != nil {
+		log.Fatalf("Failed to save report: %v", err)
+	}
+	log.Printf("Report written to %s", outputPath)
+	report.WriteText(os.Stdout)
+
+	// Gate on the baseline
+	if baseline != nil {
+		diff := eval.Compare(baseline, report)
+		diff.WriteText(os.Stdout)
+
+		if regressed := diff.Regressed(maxRegression, maxLatencyRegression); len(regressed) > 0 {
+			for _, m := range regressed {
+				log.Printf("Regression of %s: %.4f -> %.4f", m.Name, m.Baseline, m.Current)
+			}
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yourusername/go-rag/internal/config"
+	"github.com/yourusername/go-rag/internal/database"
+	"github.com/yourusername/go-rag/internal/embeddings"
+	"github.com/yourusername/go-rag/internal/loader"
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// CLI flags
+var (
+	batchSize int
+	activate  bool
+	status    bool
+	prune     bool
+)
+
+func init() {
+	// Define command line flags
+	flag.IntVar(&batchSize, "batch-size", 100, "Number of documents embedded at once")
+	flag.BoolVar(&activate, "activate", true, "Activate the model once all documents are embedded")
+	flag.BoolVar(&status, "status", false, "Show the registered embedding models and their progress, then exit")
+	flag.BoolVar(&prune, "prune", false, "Delete the embeddings of retired models, then exit")
+}
+
+func main() {
+	// Parse command-line flags
+	flag.Parse()
+
+	// Set up context with cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Set up signal handling
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigChan
+		log.Printf("Received signal: %v, stopping; run again to resume", sig)
+		cancel()
+	}()
+
+	// Load configuration; the configured embedding model is the model to re-index with
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	model := cfg.EmbeddingModel()
+
+	// Initialize database connection
+	db, err := database.NewPostgresVectorDB(cfg.Database.ConnectionString(), model)
+	if err != nil {
+		log.Fatalf("Failed to create database connection: %v", err)
+	}
+
+	// Connect to the database
+	if err := db.Connect(ctx); err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	store, ok := db.(database.EmbeddingIndexStore)
+	if !ok {
+		log.Fatalf("Database does not support re-indexing")
+	}
+
+	if status {
+		printStatus(ctx, store)
+		return
+	}
+
+	if prune {
+		deleted, err := store.PruneEmbeddings(ctx)
+		if err != nil {
+			log.Fatalf("Failed to prune embeddings: %v", err)
+		}
+		log.Printf("Deleted %d embeddings of retired models", deleted)
+		return
+	}
+
+	// Initialize embedding service
+	embeddingService, err := embeddings.NewEmbeddingService(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize embedding service: %v", err)
+	}
+
+	reindexer := loader.NewReindexer(store, embeddingService, model)
+	reindexer.SetBatchSize(batchSize)
+	reindexer.SetActivate(activate)
+
+	log.Printf("Re-indexing documents with %s (%d dimensions)", model, model.Dimensions)
+	report, err := reindexer.Run(ctx)
+	if err != nil {
+		log.Fatalf("Failed to re-index documents after embedding %d: %v", report.Embedded, err)
+	}
+
+	// Report completion
+	log.Printf("Re-indexing completed in %v: %d of %d documents embedded", report.Duration, report.Embedded, report.Total)
+	if report.Activated {
+		log.Printf("%s is now the active embedding model; instances configured with the previous model reject "+
+			"requests until restarted with it, run -prune once all are restarted", model)
+	}
+}
+
+// printStatus prints the registered embedding models and their indexing progress
+func printStatus(ctx context.Context, store database.EmbeddingIndexStore) {
+	statuses, err := store.ListEmbeddingModels(ctx)
+	if err != nil {
+		log.Fatalf("Failed to list embedding models: %v", err)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "MODEL\tDIMENSIONS\tSTATE\tINDEXED\tUPDATED")
+	for _, s := range statuses {
+		indexed, total := s.IndexedDocuments, s.TotalDocuments
+		// The progress of the active model is counted, as documents are written after activation
+		if s.State == models.EmbeddingModelActive {
+			missing, all, err := store.CountUnembeddedDocuments(ctx, s.EmbeddingModel)
+			if err != nil {
+				log.Fatalf("Failed to count documents without embeddings: %v", err)
+			}
+			indexed, total = all-missing, all
+		}
+		fmt.Fprintf(writer, "%s\t%d\t%s\t%d/%d\t%s\n",
+			s.EmbeddingModel, s.Dimensions, s.State, indexed, total, s.UpdatedAt.Format(time.RFC3339))
+	}
+	writer.Flush()
+}
@@ -0,0 +1,20 @@
+-- Create sources table: one row per ingested file or record
+CREATE TABLE IF NOT EXISTS rag.sources (
+    id UUID PRIMARY KEY,
+    uri TEXT,
+    title TEXT,
+    checksum TEXT NOT NULL,
+    metadata JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Look up sources by URI when re-indexing files
+CREATE UNIQUE INDEX IF NOT EXISTS sources_uri_idx ON rag.sources (uri) WHERE uri IS NOT NULL;
+
+-- Link chunks to their source with an ordinal position
+ALTER TABLE rag.documents ADD COLUMN IF NOT EXISTS source_id UUID REFERENCES rag.sources(id) ON DELETE CASCADE;
+ALTER TABLE rag.documents ADD COLUMN IF NOT EXISTS position INT NOT NULL DEFAULT 0;
+
+-- Create index for listing the chunks of a source in order
+CREATE INDEX IF NOT EXISTS documents_source_position_idx ON rag.documents (source_id, position);
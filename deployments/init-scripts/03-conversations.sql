@@ -0,0 +1,29 @@
+-- Create chat sessions table: one row per conversation
+CREATE TABLE IF NOT EXISTS rag.chat_sessions (
+    id UUID PRIMARY KEY,
+    title TEXT,
+    metadata JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Create chat messages table: one row per user or model turn. seq orders the
+-- messages in the order they were stored, as both messages of a turn can share
+-- the same created_at.
+CREATE TABLE IF NOT EXISTS rag.chat_messages (
+    id UUID PRIMARY KEY,
+    seq BIGINT GENERATED ALWAYS AS IDENTITY,
+    session_id UUID NOT NULL REFERENCES rag.chat_sessions(id) ON DELETE CASCADE,
+    role TEXT NOT NULL,
+    content TEXT NOT NULL,
+    standalone_query TEXT,
+    sources JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Add the sequence to tables created before it existed
+ALTER TABLE rag.chat_messages ADD COLUMN IF NOT EXISTS seq BIGINT GENERATED ALWAYS AS IDENTITY;
+
+-- Create index for reading the history of a session in order
+DROP INDEX IF EXISTS rag.chat_messages_session_created_idx;
+CREATE INDEX IF NOT EXISTS chat_messages_session_seq_idx ON rag.chat_messages (session_id, seq);
@@ -0,0 +1,28 @@
+-- Track a corpus version that changes with every write to documents or
+-- embeddings, so that cached answers of an older corpus are not served
+CREATE TABLE IF NOT EXISTS rag.corpus_state (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    version BIGINT NOT NULL DEFAULT 0
+);
+
+INSERT INTO rag.corpus_state (id, version) VALUES (TRUE, 0) ON CONFLICT (id) DO NOTHING;
+
+-- Increment the corpus version once per modifying statement
+CREATE OR REPLACE FUNCTION rag.bump_corpus_version()
+RETURNS TRIGGER
+AS $$
+BEGIN
+    UPDATE rag.corpus_state SET version = version + 1;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS documents_corpus_version ON rag.documents;
+CREATE TRIGGER documents_corpus_version
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON rag.documents
+    FOR EACH STATEMENT EXECUTE FUNCTION rag.bump_corpus_version();
+
+DROP TRIGGER IF EXISTS embeddings_corpus_version ON rag.embeddings;
+CREATE TRIGGER embeddings_corpus_version
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON rag.embeddings
+    FOR EACH STATEMENT EXECUTE FUNCTION rag.bump_corpus_version();
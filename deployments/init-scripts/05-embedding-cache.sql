@@ -0,0 +1,10 @@
+-- Create embedding cache table: embeddings keyed by a hash of model, task type and text
+CREATE TABLE IF NOT EXISTS rag.embedding_cache (
+    key TEXT PRIMARY KEY,
+    model TEXT NOT NULL,
+    embedding vector NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Create index for pruning the embeddings of a model
+CREATE INDEX IF NOT EXISTS embedding_cache_model_idx ON rag.embedding_cache (model);
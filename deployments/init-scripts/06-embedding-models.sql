@@ -0,0 +1,52 @@
+-- Create embedding model registry: the models stored embeddings were generated
+-- with and the progress of re-indexing documents with a new model
+CREATE TABLE IF NOT EXISTS rag.embedding_models (
+    model TEXT NOT NULL,
+    version TEXT NOT NULL DEFAULT '',
+    dimensions INT NOT NULL,
+    -- indexing while documents are embedded, active for the model queries are
+    -- answered with, retired once another model was activated
+    state TEXT NOT NULL CHECK (state IN ('indexing', 'active', 'retired')),
+    indexed_documents INT NOT NULL DEFAULT 0,
+    total_documents INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    activated_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (model, version)
+);
+
+-- At most one model is active
+CREATE UNIQUE INDEX IF NOT EXISTS embedding_models_active_idx ON rag.embedding_models (state) WHERE state = 'active';
+
+-- Record the model of every embedding. Existing embeddings were generated
+-- with the former default model embedding-001; relabel them if another model
+-- was configured.
+ALTER TABLE rag.embeddings ADD COLUMN IF NOT EXISTS model TEXT NOT NULL DEFAULT 'embedding-001';
+ALTER TABLE rag.embeddings ADD COLUMN IF NOT EXISTS model_version TEXT NOT NULL DEFAULT '';
+ALTER TABLE rag.embeddings ALTER COLUMN model DROP DEFAULT;
+
+INSERT INTO rag.embedding_models (model, version, dimensions, state, activated_at)
+SELECT 'embedding-001', '', 768, 'active', NOW()
+WHERE EXISTS (SELECT 1 FROM rag.embeddings)
+ON CONFLICT DO NOTHING;
+
+-- Every document has at most one embedding per model
+CREATE UNIQUE INDEX IF NOT EXISTS embeddings_document_model_idx ON rag.embeddings (document_id, model, model_version);
+
+-- Store embeddings of any dimensions, so that the embeddings of a new model
+-- are generated next to those of the active model. Every model has its own
+-- partial index on its embeddings cast to its dimensions.
+DROP INDEX IF EXISTS rag.embeddings_vector_idx;
+ALTER TABLE rag.embeddings ALTER COLUMN embedding TYPE vector;
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM rag.embedding_models WHERE model = 'embedding-001' AND version = '') THEN
+        CREATE INDEX IF NOT EXISTS embeddings_embedding_001_idx ON rag.embeddings
+            USING ivfflat ((embedding::vector(768)) vector_cosine_ops) WITH (lists = 100)
+            WHERE model = 'embedding-001' AND model_version = '';
+    END IF;
+END $$;
+
+-- Similarity search is restricted to a model by the application
+DROP FUNCTION IF EXISTS rag.search_similar_documents(vector, FLOAT, INT);
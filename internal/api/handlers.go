@@ -1,13 +1,17 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"github.com/yourusername/go-rag/internal/database"
 	"github.com/yourusername/go-rag/internal/models"
+	"github.com/yourusername/go-rag/internal/prompt"
 	"github.com/yourusername/go-rag/internal/service"
 )
 
@@ -21,6 +25,7 @@ type DocumentRequest struct {
 type SearchRequest struct {
 	Query string `json:"query" binding:"required"`
 	Limit int    `json:"limit,omitempty"`
+	models.RetrievalOptions
 }
 
 // Server represents the HTTP server for the RAG API
@@ -64,11 +69,27 @@ func (s *Server) SetupRoutes(router *gin.Engine) {
 			documents.DELETE("/:id", s.DeleteDocumentHandler)
 		}
 
+		// Source routes
+		sources := api.Group("/sources")
+		{
+			sources.GET("", s.ListSourcesHandler)
+			sources.GET("/:id/chunks", s.ListSourceChunksHandler)
+			sources.DELETE("/:id", s.DeleteSourceHandler)
+		}
+
 		// Search route
 		api.POST("/search", s.SearchHandler)
 
 		// RAG query route
 		api.POST("/query", s.QueryHandler)
+
+		// Chat routes
+		chat := api.Group("/chat")
+		{
+			chat.POST("", s.ChatHandler)
+			chat.GET("/sessions/:id", s.GetChatHistoryHandler)
+			chat.DELETE("/sessions/:id", s.DeleteChatSessionHandler)
+		}
 	}
 }
 
@@ -87,7 +108,7 @@ func (s *Server) StoreDocumentHandler(c *gin.Context) {
 
 	documentID, err := s.ragService.AddDocument(c.Request.Context(), request.Content, request.Metadata)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store document: " + err.Error()})
+		c.JSON(serverErrorStatus(err), gin.H{"error": "Failed to store document: " + err.Error()})
 		return
 	}
 
@@ -152,6 +173,68 @@ func (s *Server) DeleteDocumentHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Document deletion not implemented in MVP", "id": idParam})
 }
 
+// ListSourcesHandler handles requests to list ingested sources
+func (s *Server) ListSourcesHandler(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	sources, err := s.ragService.ListSources(c.Request.Context(), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sources: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sources)
+}
+
+// ListSourceChunksHandler handles requests to list the chunks of a source
+func (s *Server) ListSourceChunksHandler(c *gin.Context) {
+	sourceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid source ID format"})
+		return
+	}
+
+	chunks, err := s.ragService.ListSourceChunks(c.Request.Context(), sourceID)
+	if err != nil {
+		if errors.Is(err, database.ErrSourceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Source not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list source chunks: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, chunks)
+}
+
+// DeleteSourceHandler handles requests to delete a source and all of its chunks
+func (s *Server) DeleteSourceHandler(c *gin.Context) {
+	sourceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid source ID format"})
+		return
+	}
+
+	if err := s.ragService.DeleteSource(c.Request.Context(), sourceID); err != nil {
+		if errors.Is(err, database.ErrSourceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Source not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete source: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": sourceID.String(), "deleted": true})
+}
+
 // SearchHandler handles vector similarity search requests
 func (s *Server) SearchHandler(c *gin.Context) {
 	var request SearchRequest
@@ -160,9 +243,13 @@ func (s *Server) SearchHandler(c *gin.Context) {
 		return
 	}
 
-	results, err := s.ragService.SearchSimilar(c.Request.Context(), request.Query, request.Limit)
+	results, err := s.ragService.SearchWithOptions(c.Request.Context(), request.Query, request.Limit, request.RetrievalOptions)
+	if isInvalidRequestError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search: " + err.Error()})
+		c.JSON(serverErrorStatus(err), gin.H{"error": "Failed to search: " + err.Error()})
 		return
 	}
 
@@ -177,11 +264,135 @@ func (s *Server) QueryHandler(c *gin.Context) {
 		return
 	}
 
-	response, err := s.ragService.Query(c.Request.Context(), request.Query, request.Limit)
+	// Explain mode can also be enabled with ?explain=true
+	if explain, err := strconv.ParseBool(c.DefaultQuery("explain", "false")); err == nil && explain {
+		request.Explain = true
+	}
+
+	// The answer cache can be bypassed with Cache-Control: no-cache
+	if strings.Contains(c.GetHeader("Cache-Control"), "no-cache") {
+		request.NoCache = true
+	}
+
+	response, err := s.ragService.QueryWithOptions(c.Request.Context(), request)
+	if isInvalidRequestError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(serverErrorStatus(err), gin.H{"error": "Failed to process query: " + err.Error()})
+		return
+	}
+
+	setCacheHeaders(c, response)
+	c.JSON(http.StatusOK, response)
+}
+
+// ChatHandler handles messages sent to a chat session
+func (s *Server) ChatHandler(c *gin.Context) {
+	var request models.ChatRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	if request.Message == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Message is required"})
+		return
+	}
+
+	response, err := s.ragService.Chat(c.Request.Context(), request)
+	if errors.Is(err, database.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chat session not found"})
+		return
+	}
+	if isInvalidRequestError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process query: " + err.Error()})
+		c.JSON(serverErrorStatus(err), gin.H{"error": "Failed to process message: " + err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, response)
 }
+
+// GetChatHistoryHandler handles requests to list the messages of a chat session
+func (s *Server) GetChatHistoryHandler(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID format"})
+		return
+	}
+
+	messages, err := s.ragService.GetChatHistory(c.Request.Context(), sessionID)
+	if err != nil {
+		if errors.Is(err, database.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Chat session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get chat history: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"session_id": sessionID.String(), "messages": messages})
+}
+
+// DeleteChatSessionHandler handles requests to delete a chat session
+func (s *Server) DeleteChatSessionHandler(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID format"})
+		return
+	}
+
+	if err := s.ragService.DeleteChatSession(c.Request.Context(), sessionID); err != nil {
+		if errors.Is(err, database.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Chat session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete chat session: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": sessionID.String(), "deleted": true})
+}
+
+// setCacheHeaders reports in the X-Cache header whether the answer came from
+// the answer cache and, for hits, the similarity and age of the cached answer
+func setCacheHeaders(c *gin.Context, response *models.RAGResponse) {
+	metadata, ok := response.Metadata.(map[string]interface{})
+	if !ok {
+		return
+	}
+	status, ok := metadata["cache"].(*service.CacheStatus)
+	if !ok {
+		return
+	}
+
+	if !status.Hit {
+		c.Header("X-Cache", "MISS")
+		return
+	}
+	c.Header("X-Cache", "HIT")
+	c.Header("X-Cache-Similarity", strconv.FormatFloat(float64(status.Similarity), 'f', 4, 32))
+	c.Header("Age", strconv.FormatInt(status.AgeSeconds, 10))
+}
+
+// serverErrorStatus returns the status of a failed request, which is
+// unavailable while the embedding model of the server is not active
+func serverErrorStatus(err error) int {
+	if errors.Is(err, database.ErrEmbeddingModelInactive) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// isInvalidRequestError reports whether err was caused by invalid request options
+func isInvalidRequestError(err error) bool {
+	return errors.Is(err, prompt.ErrTemplateNotFound) ||
+		errors.Is(err, models.ErrInvalidGenerationOptions) ||
+		errors.Is(err, models.ErrInvalidRetrievalOptions) ||
+		errors.Is(err, models.ErrInvalidGroundednessOptions)
+}
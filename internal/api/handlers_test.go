@@ -4,13 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/yourusername/go-rag/internal/database"
 	"github.com/yourusername/go-rag/internal/models"
+	"github.com/yourusername/go-rag/internal/prompt"
+	"github.com/yourusername/go-rag/internal/service"
 )
 
 // MockRAGService is a mock implementation of the RAGService interface for testing
@@ -19,10 +23,23 @@ type MockRAGService struct {
 	AddDocumentFunc func(ctx context.Context, content string, metadata map[string]interface{}) (string, error)
 
 	// SearchSimilar mocks
-	SearchSimilarFunc func(ctx context.Context, query string, limit int) ([]models.SearchResult, error)
+	SearchSimilarFunc     func(ctx context.Context, query string, limit int) ([]models.SearchResult, error)
+	SearchWithOptionsFunc func(ctx context.Context, query string, limit int, options models.RetrievalOptions) ([]models.SearchResult, error)
 
 	// Query mocks
-	QueryFunc func(ctx context.Context, query string, limit int) (*models.RAGResponse, error)
+	QueryFunc            func(ctx context.Context, query string, limit int) (*models.RAGResponse, error)
+	QueryWithOptionsFunc func(ctx context.Context, request models.RAGQuery) (*models.RAGResponse, error)
+	RetrieveFunc         func(ctx context.Context, request models.RAGQuery) ([]models.SearchResult, error)
+
+	// Source mocks
+	ListSourcesFunc      func(ctx context.Context, limit, offset int) ([]models.Source, error)
+	ListSourceChunksFunc func(ctx context.Context, sourceID uuid.UUID) ([]models.Document, error)
+	DeleteSourceFunc     func(ctx context.Context, sourceID uuid.UUID) error
+
+	// Chat mocks
+	ChatFunc              func(ctx context.Context, request models.ChatRequest) (*models.ChatResponse, error)
+	GetChatHistoryFunc    func(ctx context.Context, sessionID uuid.UUID) ([]models.ChatMessage, error)
+	DeleteChatSessionFunc func(ctx context.Context, sessionID uuid.UUID) error
 }
 
 // AddDocument implements RAGService.AddDocument
@@ -35,11 +52,67 @@ func (m *MockRAGService) SearchSimilar(ctx context.Context, query string, limit
 	return m.SearchSimilarFunc(ctx, query, limit)
 }
 
+// SearchWithOptions implements RAGService.SearchWithOptions, falling back to SearchSimilarFunc
+func (m *MockRAGService) SearchWithOptions(
+	ctx context.Context,
+	query string,
+	limit int,
+	options models.RetrievalOptions,
+) ([]models.SearchResult, error) {
+	if m.SearchWithOptionsFunc != nil {
+		return m.SearchWithOptionsFunc(ctx, query, limit, options)
+	}
+	return m.SearchSimilarFunc(ctx, query, limit)
+}
+
 // Query implements RAGService.Query
 func (m *MockRAGService) Query(ctx context.Context, query string, limit int) (*models.RAGResponse, error) {
 	return m.QueryFunc(ctx, query, limit)
 }
 
+// QueryWithOptions implements RAGService.QueryWithOptions, falling back to QueryFunc
+func (m *MockRAGService) QueryWithOptions(ctx context.Context, request models.RAGQuery) (*models.RAGResponse, error) {
+	if m.QueryWithOptionsFunc != nil {
+		return m.QueryWithOptionsFunc(ctx, request)
+	}
+	return m.QueryFunc(ctx, request.Query, request.Limit)
+}
+
+// Retrieve implements RAGService.Retrieve
+func (m *MockRAGService) Retrieve(ctx context.Context, request models.RAGQuery) ([]models.SearchResult, error) {
+	return m.RetrieveFunc(ctx, request)
+}
+
+// ListSources implements RAGService.ListSources
+func (m *MockRAGService) ListSources(ctx context.Context, limit, offset int) ([]models.Source, error) {
+	return m.ListSourcesFunc(ctx, limit, offset)
+}
+
+// ListSourceChunks implements RAGService.ListSourceChunks
+func (m *MockRAGService) ListSourceChunks(ctx context.Context, sourceID uuid.UUID) ([]models.Document, error) {
+	return m.ListSourceChunksFunc(ctx, sourceID)
+}
+
+// DeleteSource implements RAGService.DeleteSource
+func (m *MockRAGService) DeleteSource(ctx context.Context, sourceID uuid.UUID) error {
+	return m.DeleteSourceFunc(ctx, sourceID)
+}
+
+// Chat implements RAGService.Chat
+func (m *MockRAGService) Chat(ctx context.Context, request models.ChatRequest) (*models.ChatResponse, error) {
+	return m.ChatFunc(ctx, request)
+}
+
+// GetChatHistory implements RAGService.GetChatHistory
+func (m *MockRAGService) GetChatHistory(ctx context.Context, sessionID uuid.UUID) ([]models.ChatMessage, error) {
+	return m.GetChatHistoryFunc(ctx, sessionID)
+}
+
+// DeleteChatSession implements RAGService.DeleteChatSession
+func (m *MockRAGService) DeleteChatSession(ctx context.Context, sessionID uuid.UUID) error {
+	return m.DeleteChatSessionFunc(ctx, sessionID)
+}
+
 // setupTestRouter creates a test router with the given MockRAGService
 func setupTestRouter(mockService *MockRAGService) *gin.Engine {
 	gin.SetMode(gin.TestMode)
@@ -181,6 +254,45 @@ func TestSearchHandler(t *testing.T) {
 	}
 }
 
+// TestSearchHandlerRetrievalMode tests passing the retrieval mode to the search
+func TestSearchHandlerRetrievalMode(t *testing.T) {
+	mockService := &MockRAGService{
+		SearchWithOptionsFunc: func(ctx context.Context, query string, limit int, options models.RetrievalOptions) ([]models.SearchResult, error) {
+			if err := options.Validate(); err != nil {
+				return nil, err
+			}
+			if options.Mode != models.RetrievalHyDE || !options.HyDEIncludeQuery {
+				t.Errorf("Expected HyDE with query, got %+v", options)
+			}
+			return []models.SearchResult{}, nil
+		},
+	}
+	router := setupTestRouter(mockService)
+
+	// Test with HyDE
+	body := `{"query":"how install go?","retrieval_mode":"hyde","hyde_include_query":true}`
+	req := httptest.NewRequest("POST", "/api/search", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, recorder.Code)
+	}
+
+	// Test with unknown mode
+	req = httptest.NewRequest("POST", "/api/search", bytes.NewBufferString(`{"query":"q","retrieval_mode":"keyword"}`))
+	req.Header.Set("Content-Type", "application/json")
+	recorder = httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for unknown mode, got %d", http.StatusBadRequest, recorder.Code)
+	}
+}
+
 // TestQueryHandler tests the RAG query endpoint
 func TestQueryHandler(t *testing.T) {
 	// Create mock service
@@ -235,6 +347,119 @@ func TestQueryHandler(t *testing.T) {
 	}
 }
 
+// TestQueryHandlerUnknownTemplate tests that unknown prompt templates are rejected
+func TestQueryHandlerUnknownTemplate(t *testing.T) {
+	mockService := &MockRAGService{
+		QueryWithOptionsFunc: func(ctx context.Context, request models.RAGQuery) (*models.RAGResponse, error) {
+			if request.Template != "missing" {
+				t.Errorf("Expected template 'missing', got '%s'", request.Template)
+			}
+			return nil, fmt.Errorf("failed to build prompt: %w", prompt.ErrTemplateNotFound)
+		},
+	}
+
+	router := setupTestRouter(mockService)
+
+	jsonData, _ := json.Marshal(models.RAGQuery{Query: "test question?", Template: "missing"})
+	req := httptest.NewRequest("POST", "/api/query", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, recorder.Code)
+	}
+}
+
+// TestSearchHandlerInactiveEmbeddingModel tests that searches are unavailable
+// while another embedding model is active
+func TestSearchHandlerInactiveEmbeddingModel(t *testing.T) {
+	mockService := &MockRAGService{
+		SearchWithOptionsFunc: func(ctx context.Context, query string, limit int, options models.RetrievalOptions) ([]models.SearchResult, error) {
+			return nil, fmt.Errorf("failed to find similar documents: %w", database.ErrEmbeddingModelInactive)
+		},
+	}
+
+	router := setupTestRouter(mockService)
+
+	jsonData, _ := json.Marshal(SearchRequest{Query: "test query", Limit: 5})
+	req := httptest.NewRequest("POST", "/api/search", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code %d, got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+}
+
+// TestQueryHandlerExplain tests enabling explain mode with a query parameter
+func TestQueryHandlerExplain(t *testing.T) {
+	var explained bool
+	mockService := &MockRAGService{
+		QueryWithOptionsFunc: func(ctx context.Context, request models.RAGQuery) (*models.RAGResponse, error) {
+			explained = request.Explain
+			return &models.RAGResponse{Answer: "answer"}, nil
+		},
+	}
+
+	router := setupTestRouter(mockService)
+
+	jsonData, _ := json.Marshal(models.RAGQuery{Query: "test question?"})
+	req := httptest.NewRequest("POST", "/api/query?explain=true", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, recorder.Code)
+	}
+	if !explained {
+		t.Errorf("Expected the query to be explained")
+	}
+}
+
+// TestQueryHandlerCacheHeaders tests the answer cache headers and bypass
+func TestQueryHandlerCacheHeaders(t *testing.T) {
+	var bypassed bool
+	mockService := &MockRAGService{
+		QueryWithOptionsFunc: func(ctx context.Context, request models.RAGQuery) (*models.RAGResponse, error) {
+			bypassed = request.NoCache
+			status := &service.CacheStatus{Hit: true, Similarity: 0.97, CachedQuery: "test question", AgeSeconds: 42}
+			return &models.RAGResponse{Answer: "answer", Metadata: map[string]interface{}{"cache": status}}, nil
+		},
+	}
+
+	router := setupTestRouter(mockService)
+
+	jsonData, _ := json.Marshal(models.RAGQuery{Query: "test question?"})
+	req := httptest.NewRequest("POST", "/api/query", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Cache-Control", "no-cache")
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, recorder.Code)
+	}
+	if !bypassed {
+		t.Errorf("Expected Cache-Control: no-cache to bypass the answer cache")
+	}
+	if got := recorder.Header().Get("X-Cache"); got != "HIT" {
+		t.Errorf("Expected X-Cache HIT, got %q", got)
+	}
+	if got := recorder.Header().Get("X-Cache-Similarity"); got != "0.9700" {
+		t.Errorf("Expected X-Cache-Similarity 0.9700, got %q", got)
+	}
+	if got := recorder.Header().Get("Age"); got != "42" {
+		t.Errorf("Expected Age 42, got %q", got)
+	}
+}
+
 // TestGetDocumentHandler tests the document retrieval endpoint
 func TestGetDocumentHandler(t *testing.T) {
 	mockService := &MockRAGService{}
@@ -360,3 +585,264 @@ func TestInvalidJSONInput(t *testing.T) {
 		t.Errorf("Expected status code %d for invalid JSON, got %d", http.StatusBadRequest, recorder.Code)
 	}
 }
+
+// TestListSourcesHandler tests the source listing endpoint
+func TestListSourcesHandler(t *testing.T) {
+	mockService := &MockRAGService{
+		ListSourcesFunc: func(ctx context.Context, limit, offset int) ([]models.Source, error) {
+			if limit != 20 || offset != 10 {
+				t.Errorf("Expected limit 20 and offset 10, got %d and %d", limit, offset)
+			}
+			return []models.Source{models.NewSource("/data/a.txt", "a.txt", "sum", nil)}, nil
+		},
+	}
+	router := setupTestRouter(mockService)
+
+	req := httptest.NewRequest("GET", "/api/sources?limit=20&offset=10", nil)
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, recorder.Code)
+	}
+
+	var sources []models.Source
+	if err := json.Unmarshal(recorder.Body.Bytes(), &sources); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if len(sources) != 1 || sources[0].URI != "/data/a.txt" {
+		t.Errorf("Unexpected sources in response: %+v", sources)
+	}
+}
+
+// TestListSourceChunksHandler tests the source chunk listing endpoint
+func TestListSourceChunksHandler(t *testing.T) {
+	sourceID := uuid.New()
+	mockService := &MockRAGService{
+		ListSourceChunksFunc: func(ctx context.Context, id uuid.UUID) ([]models.Document, error) {
+			if id != sourceID {
+				return nil, fmt.Errorf("failed to get source: %w", database.ErrSourceNotFound)
+			}
+			return []models.Document{
+				models.NewSourceDocument(sourceID, 0, "first", nil),
+				models.NewSourceDocument(sourceID, 1, "second", nil),
+			}, nil
+		},
+	}
+	router := setupTestRouter(mockService)
+
+	// Test with existing source
+	req := httptest.NewRequest("GET", "/api/sources/"+sourceID.String()+"/chunks", nil)
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, recorder.Code)
+	}
+
+	var chunks []models.Document
+	if err := json.Unmarshal(recorder.Body.Bytes(), &chunks); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if len(chunks) != 2 || chunks[1].Position != 1 {
+		t.Errorf("Unexpected chunks in response: %+v", chunks)
+	}
+
+	// Test with unknown source
+	req = httptest.NewRequest("GET", "/api/sources/"+uuid.New().String()+"/chunks", nil)
+	recorder = httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d for unknown source, got %d", http.StatusNotFound, recorder.Code)
+	}
+
+	// Test with invalid UUID
+	req = httptest.NewRequest("GET", "/api/sources/invalid-uuid/chunks", nil)
+	recorder = httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for invalid UUID, got %d", http.StatusBadRequest, recorder.Code)
+	}
+}
+
+// TestDeleteSourceHandler tests the source deletion endpoint
+func TestDeleteSourceHandler(t *testing.T) {
+	sourceID := uuid.New()
+	deleted := false
+	mockService := &MockRAGService{
+		DeleteSourceFunc: func(ctx context.Context, id uuid.UUID) error {
+			if id != sourceID {
+				return fmt.Errorf("failed to delete source: %w", database.ErrSourceNotFound)
+			}
+			deleted = true
+			return nil
+		},
+	}
+	router := setupTestRouter(mockService)
+
+	// Test with existing source
+	req := httptest.NewRequest("DELETE", "/api/sources/"+sourceID.String(), nil)
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, recorder.Code)
+	}
+
+	if !deleted {
+		t.Error("Expected DeleteSource to be called")
+	}
+
+	// Test with unknown source
+	req = httptest.NewRequest("DELETE", "/api/sources/"+uuid.New().String(), nil)
+	recorder = httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d for unknown source, got %d", http.StatusNotFound, recorder.Code)
+	}
+}
+
+// TestChatHandler tests the chat endpoint
+func TestChatHandler(t *testing.T) {
+	sessionID := uuid.New()
+	mockService := &MockRAGService{
+		ChatFunc: func(ctx context.Context, request models.ChatRequest) (*models.ChatResponse, error) {
+			if request.SessionID != nil && *request.SessionID != sessionID {
+				return nil, fmt.Errorf("failed to get chat session: %w", database.ErrSessionNotFound)
+			}
+			if request.Options.NeighborChunks != 1 {
+				t.Errorf("Expected options to be passed, got %+v", request.Options)
+			}
+			return &models.ChatResponse{
+				SessionID:       sessionID,
+				StandaloneQuery: "How do I install Go on Linux?",
+				RAGResponse:     models.RAGResponse{Answer: "Use the tarball [S1]."},
+			}, nil
+		},
+	}
+	router := setupTestRouter(mockService)
+
+	// Test continuing a session
+	body := `{"session_id":"` + sessionID.String() + `","message":"and for Linux?","options":{"neighbor_chunks":1}}`
+	req := httptest.NewRequest("POST", "/api/chat", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, recorder.Code)
+	}
+
+	var response models.ChatResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if response.SessionID != sessionID || response.Answer != "Use the tarball [S1]." {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+	if response.StandaloneQuery != "How do I install Go on Linux?" {
+		t.Errorf("Expected standalone query in response, got '%s'", response.StandaloneQuery)
+	}
+
+	// Test with unknown session
+	body = `{"session_id":"` + uuid.New().String() + `","message":"and for Linux?","options":{"neighbor_chunks":1}}`
+	req = httptest.NewRequest("POST", "/api/chat", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	recorder = httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d for unknown session, got %d", http.StatusNotFound, recorder.Code)
+	}
+
+	// Test with empty message
+	req = httptest.NewRequest("POST", "/api/chat", bytes.NewBufferString(`{"message":""}`))
+	req.Header.Set("Content-Type", "application/json")
+	recorder = httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d for empty message, got %d", http.StatusBadRequest, recorder.Code)
+	}
+}
+
+// TestChatSessionHandlers tests the chat history and session deletion endpoints
+func TestChatSessionHandlers(t *testing.T) {
+	sessionID := uuid.New()
+	mockService := &MockRAGService{
+		GetChatHistoryFunc: func(ctx context.Context, id uuid.UUID) ([]models.ChatMessage, error) {
+			if id != sessionID {
+				return nil, fmt.Errorf("failed to get chat session: %w", database.ErrSessionNotFound)
+			}
+			return []models.ChatMessage{
+				models.NewChatMessage(sessionID, models.ChatRoleUser, "question"),
+				models.NewChatMessage(sessionID, models.ChatRoleModel, "answer"),
+			}, nil
+		},
+		DeleteChatSessionFunc: func(ctx context.Context, id uuid.UUID) error {
+			if id != sessionID {
+				return fmt.Errorf("failed to delete chat session: %w", database.ErrSessionNotFound)
+			}
+			return nil
+		},
+	}
+	router := setupTestRouter(mockService)
+
+	// Test history of existing session
+	req := httptest.NewRequest("GET", "/api/chat/sessions/"+sessionID.String(), nil)
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, recorder.Code)
+	}
+
+	var history struct {
+		Messages []models.ChatMessage `json:"messages"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &history); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if len(history.Messages) != 2 || history.Messages[1].Role != models.ChatRoleModel {
+		t.Errorf("Unexpected messages in response: %+v", history.Messages)
+	}
+
+	// Test unknown sessions
+	for _, method := range []string{"GET", "DELETE"} {
+		req = httptest.NewRequest(method, "/api/chat/sessions/"+uuid.New().String(), nil)
+		recorder = httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusNotFound {
+			t.Errorf("Expected status code %d for %s of unknown session, got %d", http.StatusNotFound, method, recorder.Code)
+		}
+	}
+
+	// Test deleting existing session
+	req = httptest.NewRequest("DELETE", "/api/chat/sessions/"+sessionID.String(), nil)
+	recorder = httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, recorder.Code)
+	}
+}
@@ -12,18 +12,27 @@ Key responsibilities:
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+
+	"github.com/yourusername/go-rag/internal/models"
 )
 
 // Config represents the application configuration
 type Config struct {
-	Server     ServerConfig
-	Database   DatabaseConfig
-	Gemini     GeminiConfig
-	Embeddings EmbeddingsConfig
+	Server       ServerConfig
+	Database     DatabaseConfig
+	Gemini       GeminiConfig
+	Generator    GeneratorConfig
+	Embeddings   EmbeddingsConfig
+	Prompt       PromptConfig
+	Reranker     RerankerConfig
+	Groundedness GroundednessConfig
+	AnswerCache  AnswerCacheConfig
 }
 
 // ServerConfig contains server-related configuration
@@ -48,11 +57,95 @@ type GeminiConfig struct {
 	APIKey         string
 	TextModel      string
 	EmbeddingModel string
+	// Generation contains the default generation options of the text model
+	Generation models.GenerationOptions
+	// ContextTokenBudget is the number of tokens of the text model's context
+	// available for retrieved documents; zero means unlimited
+	ContextTokenBudget int
+}
+
+// GeneratorConfig contains configuration of the text generator
+type GeneratorConfig struct {
+	// Provider selects the generator: gemini or echo
+	Provider string
+	// Template is the Go text/template the echo generator renders as answer
+	Template string
 }
 
 // EmbeddingsConfig contains embedding-related configuration
 type EmbeddingsConfig struct {
+	// Provider selects the embedding provider: gemini, openai, ollama or local
+	Provider string
+	// OpenAI configures the OpenAI-compatible provider
+	OpenAI EmbeddingProviderConfig
+	// Ollama configures the Ollama provider
+	Ollama     EmbeddingProviderConfig
 	Dimensions int
+	// ModelVersion distinguishes revisions of the embedding model published under the same name
+	ModelVersion   string
+	MaxInputTokens int
+	// CacheSize is the number of embeddings cached in memory; zero disables the cache
+	CacheSize int
+	// PersistentCache also caches embeddings in the database
+	PersistentCache bool
+}
+
+// EmbeddingProviderConfig contains the connection settings of an embedding provider
+type EmbeddingProviderConfig struct {
+	BaseURL string
+	Model   string
+	// APIKey is sent as a bearer token when set
+	APIKey string
+	// SendDimensions requests embeddings of EMBEDDING_DIMENSIONS from models
+	// that can shorten them, such as text-embedding-3-small
+	SendDimensions bool
+}
+
+// PromptConfig contains prompt template configuration
+type PromptConfig struct {
+	// TemplatesDir is the directory of *.tmpl prompt template files
+	TemplatesDir string
+	// DefaultTemplate is the template used when no other template is selected
+	DefaultTemplate string
+	// CollectionTemplates maps collection names to template names
+	CollectionTemplates map[string]string
+}
+
+// RerankerConfig contains configuration of the reranking stage after vector retrieval
+type RerankerConfig struct {
+	// Type selects the reranker: none, llm_pointwise, llm_listwise or cross_encoder
+	Type string
+	// URL is the base URL of the cross-encoder server
+	URL string
+	// Candidates is the number of documents retrieved for reranking
+	Candidates int
+	// Concurrency limits the documents graded at once by the pointwise LLM reranker
+	Concurrency int
+}
+
+// GroundednessConfig contains configuration of the answer verification
+type GroundednessConfig struct {
+	// Options are the default method, threshold and action of the check. The
+	// llm method costs one generation request per answer; the embedding
+	// method embeds the answer sentences and up to 64 sentences of the cited
+	// documents, in requests of up to 32 texts.
+	Options models.GroundednessOptions
+	// SentenceThreshold is the similarity an answer sentence needs to a
+	// context sentence to be supported in embedding checks
+	SentenceThreshold float64
+	// RefusalMessage replaces refused answers
+	RefusalMessage string
+}
+
+// AnswerCacheConfig contains configuration of the semantic answer cache
+type AnswerCacheConfig struct {
+	Enabled bool
+	// Threshold is the similarity a query needs to a cached query to reuse its answer
+	Threshold float64
+	// TTL is how long answers are cached; zero keeps them until the corpus changes
+	TTL time.Duration
+	// MaxEntries is the number of answers kept; the oldest are evicted first
+	MaxEntries int
 }
 
 // LoadConfig loads the application configuration from environment variables
@@ -78,10 +171,68 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("invalid embedding dimensions: %w", err)
 	}
 
-	// API key validation
-	geminiAPIKey := getEnv("GEMINI_API_KEY", "")
-	if geminiAPIKey == "" {
-		return nil, fmt.Errorf("GEMINI_API_KEY is required")
+	// Embedding model input limit
+	maxInputTokens, err := strconv.Atoi(getEnv("EMBEDDING_MAX_INPUT_TOKENS", "2048"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid embedding max input tokens: %w", err)
+	}
+
+	// Embedding cache
+	embeddingCacheSize, err := strconv.Atoi(getEnv("EMBEDDING_CACHE_SIZE", "10000"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid embedding cache size: %w", err)
+	}
+	persistentEmbeddingCache, err := strconv.ParseBool(getEnv("EMBEDDING_CACHE_PERSISTENT", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid embedding cache persistence: %w", err)
+	}
+	sendOpenAIDimensions, err := strconv.ParseBool(getEnv("OPENAI_EMBEDDING_SEND_DIMENSIONS", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OpenAI embedding dimensions setting: %w", err)
+	}
+
+	// Prompt templates per collection
+	collectionTemplates, err := parseKeyValueList(getEnv("PROMPT_COLLECTION_TEMPLATES", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt collection templates: %w", err)
+	}
+
+	// Generation options
+	generation, err := loadGenerationOptions()
+	if err != nil {
+		return nil, fmt.Errorf("invalid generation options: %w", err)
+	}
+
+	// Context token budget of the text model, either set for the model or the default
+	textModel := getEnv("GEMINI_TEXT_MODEL", "gemini-1.5-pro")
+	contextTokenBudget, err := loadContextTokenBudget(textModel)
+	if err != nil {
+		return nil, fmt.Errorf("invalid context token budget: %w", err)
+	}
+
+	// Number of documents retrieved for reranking
+	rerankCandidates, err := strconv.Atoi(getEnv("RERANKER_CANDIDATES", "50"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid reranker candidates: %w", err)
+	}
+	rerankConcurrency, err := strconv.Atoi(getEnv("RERANKER_CONCURRENCY", "4"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid reranker concurrency: %w", err)
+	}
+	if rerankConcurrency <= 0 {
+		return nil, fmt.Errorf("reranker concurrency must be positive, got %d", rerankConcurrency)
+	}
+
+	// Answer verification
+	groundedness, err := loadGroundednessConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid groundedness configuration: %w", err)
+	}
+
+	// Answer cache
+	answerCache, err := loadAnswerCacheConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid answer cache configuration: %w", err)
 	}
 
 	return &Config{
@@ -99,13 +250,48 @@ func LoadConfig() (*Config, error) {
 			SSLMode:  getEnv("DB_SSL_MODE", "disable"),
 		},
 		Gemini: GeminiConfig{
-			APIKey:         geminiAPIKey,
-			TextModel:      getEnv("GEMINI_TEXT_MODEL", "gemini-1.5-pro"),
-			EmbeddingModel: getEnv("GEMINI_EMBEDDING_MODEL", "embedding-001"),
+			APIKey:             getEnv("GEMINI_API_KEY", ""),
+			TextModel:          textModel,
+			EmbeddingModel:     getEnv("GEMINI_EMBEDDING_MODEL", "embedding-001"),
+			Generation:         generation,
+			ContextTokenBudget: contextTokenBudget,
+		},
+		Generator: GeneratorConfig{
+			Provider: getEnv("GENERATOR_PROVIDER", "gemini"),
+			Template: getEnv("GENERATOR_TEMPLATE", "{{.Prompt}}"),
 		},
 		Embeddings: EmbeddingsConfig{
-			Dimensions: dimensions,
+			Provider: getEnv("EMBEDDING_PROVIDER", "gemini"),
+			OpenAI: EmbeddingProviderConfig{
+				BaseURL:        getEnv("OPENAI_EMBEDDING_BASE_URL", "https://api.openai.com/v1"),
+				Model:          getEnv("OPENAI_EMBEDDING_MODEL", "text-embedding-3-small"),
+				APIKey:         getEnv("OPENAI_EMBEDDING_API_KEY", ""),
+				SendDimensions: sendOpenAIDimensions,
+			},
+			Ollama: EmbeddingProviderConfig{
+				BaseURL: getEnv("OLLAMA_BASE_URL", "http://localhost:11434"),
+				Model:   getEnv("OLLAMA_EMBEDDING_MODEL", "nomic-embed-text"),
+				APIKey:  getEnv("OLLAMA_API_KEY", ""),
+			},
+			Dimensions:      dimensions,
+			ModelVersion:    getEnv("EMBEDDING_MODEL_VERSION", ""),
+			MaxInputTokens:  maxInputTokens,
+			CacheSize:       embeddingCacheSize,
+			PersistentCache: persistentEmbeddingCache,
+		},
+		Prompt: PromptConfig{
+			TemplatesDir:        getEnv("PROMPT_TEMPLATES_DIR", "prompts"),
+			DefaultTemplate:     getEnv("PROMPT_DEFAULT_TEMPLATE", "default"),
+			CollectionTemplates: collectionTemplates,
 		},
+		Reranker: RerankerConfig{
+			Type:        getEnv("RERANKER_TYPE", "none"),
+			URL:         getEnv("RERANKER_URL", "http://localhost:8081"),
+			Candidates:  rerankCandidates,
+			Concurrency: rerankConcurrency,
+		},
+		Groundedness: groundedness,
+		AnswerCache:  answerCache,
 	}, nil
 }
 
@@ -115,6 +301,190 @@ func (c *DatabaseConfig) ConnectionString() string {
 		c.User, c.Password, c.Host, c.Port, c.DBName, c.SSLMode)
 }
 
+// EmbeddingModel returns the model of the selected embedding provider
+func (c *Config) EmbeddingModel() models.EmbeddingModel {
+	model := models.EmbeddingModel{
+		Name:       c.Gemini.EmbeddingModel,
+		Version:    c.Embeddings.ModelVersion,
+		Dimensions: c.Embeddings.Dimensions,
+	}
+	switch c.Embeddings.Provider {
+	case "openai":
+		model.Name = c.Embeddings.OpenAI.Model
+	case "ollama":
+		model.Name = c.Embeddings.Ollama.Model
+	case "local":
+		// Local embeddings depend on the dimensions they are hashed into
+		model.Name = fmt.Sprintf("local-hashing-%d", c.Embeddings.Dimensions)
+	}
+	return model
+}
+
+// loadGroundednessConfig loads the answer verification settings from environment variables
+func loadGroundednessConfig() (GroundednessConfig, error) {
+	cfg := GroundednessConfig{
+		Options: models.GroundednessOptions{
+			Method: models.GroundednessMethod(getEnv("GROUNDEDNESS_METHOD", "none")),
+			Action: models.GroundednessAction(getEnv("GROUNDEDNESS_ACTION", "flag")),
+		},
+		RefusalMessage: getEnv("GROUNDEDNESS_REFUSAL_MESSAGE", ""),
+	}
+
+	var err error
+	if cfg.Options.Threshold, err = getOptionalFloat("GROUNDEDNESS_THRESHOLD"); err != nil {
+		return cfg, err
+	}
+	if cfg.SentenceThreshold, err = strconv.ParseFloat(getEnv("GROUNDEDNESS_SENTENCE_THRESHOLD", "0.75"), 64); err != nil {
+		return cfg, fmt.Errorf("invalid GROUNDEDNESS_SENTENCE_THRESHOLD: %w", err)
+	}
+
+	return cfg, cfg.Options.Validate()
+}
+
+// loadAnswerCacheConfig loads the semantic answer cache settings from environment variables
+func loadAnswerCacheConfig() (AnswerCacheConfig, error) {
+	var cfg AnswerCacheConfig
+	var err error
+
+	if cfg.Enabled, err = strconv.ParseBool(getEnv("ANSWER_CACHE_ENABLED", "false")); err != nil {
+		return cfg, fmt.Errorf("invalid ANSWER_CACHE_ENABLED: %w", err)
+	}
+	if cfg.Threshold, err = strconv.ParseFloat(getEnv("ANSWER_CACHE_THRESHOLD", "0.95"), 64); err != nil {
+		return cfg, fmt.Errorf("invalid ANSWER_CACHE_THRESHOLD: %w", err)
+	}
+	if cfg.Threshold <= 0 || cfg.Threshold > 1 {
+		return cfg, fmt.Errorf("ANSWER_CACHE_THRESHOLD must be greater than 0 and at most 1, got %g", cfg.Threshold)
+	}
+	if cfg.TTL, err = time.ParseDuration(getEnv("ANSWER_CACHE_TTL", "24h")); err != nil {
+		return cfg, fmt.Errorf("invalid ANSWER_CACHE_TTL: %w", err)
+	}
+	if cfg.MaxEntries, err = strconv.Atoi(getEnv("ANSWER_CACHE_MAX_ENTRIES", "1000")); err != nil {
+		return cfg, fmt.Errorf("invalid ANSWER_CACHE_MAX_ENTRIES: %w", err)
+	}
+	if cfg.MaxEntries <= 0 {
+		return cfg, fmt.Errorf("ANSWER_CACHE_MAX_ENTRIES must be positive, got %d", cfg.MaxEntries)
+	}
+
+	return cfg, nil
+}
+
+// loadGenerationOptions loads the default generation options from environment
+// variables. Unset variables leave the model defaults in place.
+func loadGenerationOptions() (models.GenerationOptions, error) {
+	var options models.GenerationOptions
+	var err error
+
+	if options.Temperature, err = getOptionalFloat("GEMINI_TEMPERATURE"); err != nil {
+		return options, err
+	}
+	if options.TopP, err = getOptionalFloat("GEMINI_TOP_P"); err != nil {
+		return options, err
+	}
+	if options.TopK, err = getOptionalInt("GEMINI_TOP_K"); err != nil {
+		return options, err
+	}
+	if options.MaxOutputTokens, err = getOptionalInt("GEMINI_MAX_OUTPUT_TOKENS"); err != nil {
+		return options, err
+	}
+
+	for _, stop := range strings.Split(getEnv("GEMINI_STOP_SEQUENCES", ""), ",") {
+		if stop != "" {
+			options.StopSequences = append(options.StopSequences, stop)
+		}
+	}
+
+	options.SystemInstruction = getEnv("GEMINI_SYSTEM_INSTRUCTION", "")
+
+	// Safety settings are given as CATEGORY=THRESHOLD pairs
+	safety, err := parseKeyValueList(getEnv("GEMINI_SAFETY_SETTINGS", ""))
+	if err != nil {
+		return options, fmt.Errorf("invalid GEMINI_SAFETY_SETTINGS: %w", err)
+	}
+	categories := make([]string, 0, len(safety))
+	for category := range safety {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		options.SafetySettings = append(options.SafetySettings, models.SafetySetting{
+			Category:  category,
+			Threshold: safety[category],
+		})
+	}
+
+	return options, options.Validate()
+}
+
+// loadContextTokenBudget returns the context token budget of a model from
+// CONTEXT_TOKEN_BUDGETS (model=tokens pairs), falling back to CONTEXT_TOKEN_BUDGET
+func loadContextTokenBudget(model string) (int, error) {
+	budgets, err := parseKeyValueList(getEnv("CONTEXT_TOKEN_BUDGETS", ""))
+	if err != nil {
+		return 0, err
+	}
+
+	value, ok := budgets[model]
+	if !ok {
+		value = getEnv("CONTEXT_TOKEN_BUDGET", "8000")
+	}
+
+	budget, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if budget < 0 {
+		return 0, fmt.Errorf("budget must not be negative, got %d", budget)
+	}
+	return budget, nil
+}
+
+// getOptionalFloat returns the float value of an environment variable, or nil if it is not set
+func getOptionalFloat(key string) (*float64, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return nil, nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return &f, nil
+}
+
+// getOptionalInt returns the integer value of an environment variable, or nil if it is not set
+func getOptionalInt(key string) (*int, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return &n, nil
+}
+
+// parseKeyValueList parses a comma-separated list of key=value pairs
+func parseKeyValueList(value string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, val, ok := strings.Cut(pair, "=")
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		if !ok || key == "" || val == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", pair)
+		}
+		result[key] = val
+	}
+	return result, nil
+}
+
 // Helper function to get environment variable with a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -0,0 +1,40 @@
+package config
+
+import "testing"
+
+// TestLoadConfigOffline tests loading the configuration of an offline setup without a Gemini API key
+func TestLoadConfigOffline(t *testing.T) {
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Setenv("EMBEDDING_PROVIDER", "local")
+	t.Setenv("EMBEDDING_DIMENSIONS", "384")
+	t.Setenv("GENERATOR_PROVIDER", "echo")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.Generator.Provider != "echo" || cfg.Generator.Template != "{{.Prompt}}" {
+		t.Errorf("Unexpected generator configuration %+v", cfg.Generator)
+	}
+	if model := cfg.EmbeddingModel(); model.Name != "local-hashing-384" || model.Dimensions != 384 {
+		t.Errorf("Expected model local-hashing-384 with 384 dimensions, got %+v", model)
+	}
+}
+
+// TestLoadConfigRerankerConcurrency tests the default and validation of the reranker concurrency
+func TestLoadConfigRerankerConcurrency(t *testing.T) {
+	t.Setenv("RERANKER_CONCURRENCY", "")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.Reranker.Concurrency != 4 {
+		t.Errorf("Expected default concurrency 4, got %d", cfg.Reranker.Concurrency)
+	}
+
+	t.Setenv("RERANKER_CONCURRENCY", "0")
+	if _, err := LoadConfig(); err == nil {
+		t.Error("Expected error for a concurrency of 0")
+	}
+}
@@ -0,0 +1,246 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// ConversationStore defines the interface for persisting chat sessions and their messages
+type ConversationStore interface {
+	CreateSession(ctx context.Context, session models.ChatSession) error
+	GetSession(ctx context.Context, id uuid.UUID) (models.ChatSession, error)
+	DeleteSession(ctx context.Context, id uuid.UUID) error
+	StoreMessage(ctx context.Context, message models.ChatMessage) error
+	// StoreTurn creates the session unless it is nil and stores the messages
+	// of a turn in a single transaction
+	StoreTurn(ctx context.Context, session *models.ChatSession, messages ...models.ChatMessage) error
+	ListMessages(ctx context.Context, sessionID uuid.UUID, limit int) ([]models.ChatMessage, error)
+}
+
+// ErrSessionNotFound is returned when a requested chat session does not exist
+var ErrSessionNotFound = errors.New("chat session not found")
+
+// messageSources is the JSON stored in the sources column of a chat message
+type messageSources struct {
+	Documents []models.ChatSource `json:"documents,omitempty"`
+	Citations []models.Citation   `json:"citations,omitempty"`
+}
+
+// CreateSession stores a new chat session
+func (p *PostgresVectorDB) CreateSession(ctx context.Context, session models.ChatSession) error {
+	if p.db == nil {
+		return fmt.Errorf("database not connected")
+	}
+
+	// Convert metadata to JSON
+	metadataJSON, err := json.Marshal(session.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	_, err = p.db.ExecContext(
+		ctx,
+		`INSERT INTO rag.chat_sessions (id, title, metadata, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		session.ID, nullableString(session.Title), metadataJSON, session.CreatedAt, session.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert chat session: %w", err)
+	}
+
+	return nil
+}
+
+// GetSession retrieves a chat session by ID
+func (p *PostgresVectorDB) GetSession(ctx context.Context, id uuid.UUID) (models.ChatSession, error) {
+	if p.db == nil {
+		return models.ChatSession{}, fmt.Errorf("database not connected")
+	}
+
+	var session models.ChatSession
+	var title sql.NullString
+	var metadataJSON []byte
+
+	err := p.db.QueryRowContext(
+		ctx,
+		"SELECT id, title, metadata, created_at, updated_at FROM rag.chat_sessions WHERE id = $1",
+		id,
+	).Scan(&session.ID, &title, &metadataJSON, &session.CreatedAt, &session.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.ChatSession{}, ErrSessionNotFound
+		}
+		return models.ChatSession{}, fmt.Errorf("failed to get chat session: %w", err)
+	}
+	session.Title = title.String
+
+	// Parse metadata
+	if len(metadataJSON) > 0 {
+		if err := json.Unmarshal(metadataJSON, &session.Metadata); err != nil {
+			return models.ChatSession{}, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+
+	return session, nil
+}
+
+// DeleteSession deletes a chat session together with its messages
+func (p *PostgresVectorDB) DeleteSession(ctx context.Context, id uuid.UUID) error {
+	if p.db == nil {
+		return fmt.Errorf("database not connected")
+	}
+
+	// Delete session (cascade will delete its messages)
+	result, err := p.db.ExecContext(ctx, "DELETE FROM rag.chat_sessions WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete chat session: %w", err)
+	}
+
+	// Check if any rows were affected
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// StoreMessage stores a message of a chat session and marks the session as updated
+func (p *PostgresVectorDB) StoreMessage(ctx context.Context, message models.ChatMessage) error {
+	return p.StoreTurn(ctx, nil, message)
+}
+
+// StoreTurn creates the session unless it is nil and stores the messages of a
+// turn in a single transaction, so that a failure never leaves an empty
+// session or a turn without its answer
+func (p *PostgresVectorDB) StoreTurn(ctx context.Context, session *models.ChatSession, messages ...models.ChatMessage) error {
+	if p.db == nil {
+		return fmt.Errorf("database not connected")
+	}
+
+	// Begin transaction
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if session != nil {
+		// Convert metadata to JSON
+		metadataJSON, err := json.Marshal(session.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO rag.chat_sessions (id, title, metadata, created_at, updated_at)
+			 VALUES ($1, $2, $3, $4, $5)`,
+			session.ID, nullableString(session.Title), metadataJSON, session.CreatedAt, session.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert chat session: %w", err)
+		}
+	}
+
+	for _, message := range messages {
+		// Convert sources to JSON
+		sourcesJSON, err := json.Marshal(messageSources{Documents: message.Sources, Citations: message.Citations})
+		if err != nil {
+			return fmt.Errorf("failed to marshal sources: %w", err)
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO rag.chat_messages (id, session_id, role, content, standalone_query, sources, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			message.ID, message.SessionID, message.Role, message.Content,
+			nullableString(message.StandaloneQuery), sourcesJSON, message.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert chat message: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE rag.chat_sessions SET updated_at = $1 WHERE id = $2", message.CreatedAt, message.SessionID)
+		if err != nil {
+			return fmt.Errorf("failed to update chat session: %w", err)
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ListMessages retrieves the most recent messages of a chat session in the
+// order they were stored. A limit of zero or less returns all messages.
+func (p *PostgresVectorDB) ListMessages(ctx context.Context, sessionID uuid.UUID, limit int) ([]models.ChatMessage, error) {
+	if p.db == nil {
+		return nil, fmt.Errorf("database not connected")
+	}
+
+	query := `SELECT id, session_id, role, content, standalone_query, sources, created_at
+		 FROM rag.chat_messages WHERE session_id = $1 ORDER BY seq DESC`
+	args := []interface{}{sessionID}
+	if limit > 0 {
+		query += " LIMIT $2"
+		args = append(args, limit)
+	}
+
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chat messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []models.ChatMessage
+	for rows.Next() {
+		var message models.ChatMessage
+		var standaloneQuery sql.NullString
+		var sourcesJSON []byte
+
+		if err := rows.Scan(
+			&message.ID, &message.SessionID, &message.Role, &message.Content,
+			&standaloneQuery, &sourcesJSON, &message.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan chat message row: %w", err)
+		}
+		message.StandaloneQuery = standaloneQuery.String
+
+		// Parse sources
+		if len(sourcesJSON) > 0 {
+			var sources messageSources
+			if err := json.Unmarshal(sourcesJSON, &sources); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal sources: %w", err)
+			}
+			message.Sources = sources.Documents
+			message.Citations = sources.Citations
+		}
+
+		messages = append(messages, message)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating chat message rows: %w", err)
+	}
+
+	// Messages were selected newest first to apply the limit
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// TestConversationMethodsRequireConnection tests that conversation methods fail before Connect
+func TestConversationMethodsRequireConnection(t *testing.T) {
+	db := &PostgresVectorDB{}
+	ctx := context.Background()
+
+	// PostgresVectorDB also serves as the conversation store
+	var store ConversationStore = db
+
+	if err := store.CreateSession(ctx, models.NewChatSession("title", nil)); err == nil {
+		t.Error("Expected error from CreateSession without connection")
+	}
+
+	if _, err := store.GetSession(ctx, uuid.New()); err == nil {
+		t.Error("Expected error from GetSession without connection")
+	}
+
+	if err := store.StoreMessage(ctx, models.NewChatMessage(uuid.New(), models.ChatRoleUser, "hi")); err == nil {
+		t.Error("Expected error from StoreMessage without connection")
+	}
+
+	session := models.NewChatSession("title", nil)
+	if err := store.StoreTurn(ctx, &session, models.NewChatMessage(session.ID, models.ChatRoleUser, "hi")); err == nil {
+		t.Error("Expected error from StoreTurn without connection")
+	}
+
+	if _, err := store.ListMessages(ctx, uuid.New(), 10); err == nil {
+		t.Error("Expected error from ListMessages without connection")
+	}
+
+	if err := store.DeleteSession(ctx, uuid.New()); err == nil {
+		t.Error("Expected error from DeleteSession without connection")
+	}
+}
+
+// TestListMessagesSameTimestamp tests that the messages of a turn keep their
+// order when they share a timestamp. It runs against the database at
+// TEST_DATABASE_URL, initialized with the scripts in deployments/init-scripts.
+func TestListMessagesSameTimestamp(t *testing.T) {
+	connStr := os.Getenv("TEST_DATABASE_URL")
+	if connStr == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	ctx := context.Background()
+	vectorDB, err := NewPostgresVectorDB(connStr, models.EmbeddingModel{Name: "test", Dimensions: 3})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	if err := vectorDB.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer vectorDB.Close()
+	store := vectorDB.(ConversationStore)
+
+	session := models.NewChatSession("title", nil)
+	defer store.DeleteSession(ctx, session.ID)
+
+	// Store turns whose messages share a timestamp; their IDs are random
+	const turns = 20
+	now := time.Now()
+	for i := 0; i < turns; i++ {
+		userMessage := models.NewChatMessage(session.ID, models.ChatRoleUser, fmt.Sprintf("question %d", i))
+		modelMessage := models.NewChatMessage(session.ID, models.ChatRoleModel, fmt.Sprintf("answer %d", i))
+		userMessage.CreatedAt, modelMessage.CreatedAt = now, now
+
+		var created *models.ChatSession
+		if i == 0 {
+			created = &session
+		}
+		if err := store.StoreTurn(ctx, created, userMessage, modelMessage); err != nil {
+			t.Fatalf("Failed to store turn: %v", err)
+		}
+	}
+
+	messages, err := store.ListMessages(ctx, session.ID, 0)
+	if err != nil {
+		t.Fatalf("Failed to list messages: %v", err)
+	}
+	if len(messages) != 2*turns {
+		t.Fatalf("Expected %d messages, got %d", 2*turns, len(messages))
+	}
+	for i, message := range messages {
+		role, content := models.ChatRoleUser, fmt.Sprintf("question %d", i/2)
+		if i%2 == 1 {
+			role, content = models.ChatRoleModel, fmt.Sprintf("answer %d", i/2)
+		}
+		if message.Role != role || message.Content != content {
+			t.Errorf("Message %d: expected %s %q, got %s %q", i, role, content, message.Role, message.Content)
+		}
+	}
+
+	// The limit keeps the most recent turn in order
+	recent, err := store.ListMessages(ctx, session.ID, 2)
+	if err != nil {
+		t.Fatalf("Failed to list messages: %v", err)
+	}
+	if len(recent) != 2 || recent[0].Role != models.ChatRoleUser || recent[1].Content != fmt.Sprintf("answer %d", turns-1) {
+		t.Errorf("Expected the last turn in order, got %+v", recent)
+	}
+}
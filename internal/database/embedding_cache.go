@@ -0,0 +1,90 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pgvector/pgvector-go"
+)
+
+// EmbeddingCacheStore defines the interface for persisting computed embeddings
+// by cache key, so that they survive restarts and are shared between processes
+type EmbeddingCacheStore interface {
+	GetCachedEmbeddings(ctx context.Context, keys []string) (map[string][]float32, error)
+	StoreCachedEmbeddings(ctx context.Context, model string, embeddings map[string][]float32) error
+}
+
+// GetCachedEmbeddings retrieves the cached embeddings of the given keys;
+// unknown keys are missing from the result
+func (p *PostgresVectorDB) GetCachedEmbeddings(ctx context.Context, keys []string) (map[string][]float32, error) {
+	if p.db == nil {
+		return nil, fmt.Errorf("database not connected")
+	}
+
+	embeddings := make(map[string][]float32, len(keys))
+	if len(keys) == 0 {
+		return embeddings, nil
+	}
+
+	rows, err := p.db.QueryContext(
+		ctx,
+		"SELECT key, embedding FROM rag.embedding_cache WHERE key = ANY($1)",
+		pq.Array(keys),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cached embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var vector pgvector.Vector
+		if err := rows.Scan(&key, &vector); err != nil {
+			return nil, fmt.Errorf("failed to scan cached embedding row: %w", err)
+		}
+		embeddings[key] = vector.Slice()
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating cached embedding rows: %w", err)
+	}
+
+	return embeddings, nil
+}
+
+// StoreCachedEmbeddings stores embeddings computed with the given model by
+// cache key, keeping existing entries
+func (p *PostgresVectorDB) StoreCachedEmbeddings(ctx context.Context, model string, embeddings map[string][]float32) error {
+	if p.db == nil {
+		return fmt.Errorf("database not connected")
+	}
+
+	// Begin transaction
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for key, embedding := range embeddings {
+		_, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO rag.embedding_cache (key, model, embedding, created_at)
+			 VALUES ($1, $2, $3, $4) ON CONFLICT (key) DO NOTHING`,
+			key, model, pgvector.NewVector(embedding), now,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert cached embedding: %w", err)
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
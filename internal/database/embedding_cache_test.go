@@ -0,0 +1,23 @@
+package database
+
+import (
+	"context"
+	"testing"
+)
+
+// TestEmbeddingCacheMethodsRequireConnection tests that embedding cache methods fail before Connect
+func TestEmbeddingCacheMethodsRequireConnection(t *testing.T) {
+	db := &PostgresVectorDB{}
+	ctx := context.Background()
+
+	// PostgresVectorDB also serves as the persistent embedding cache
+	var store EmbeddingCacheStore = db
+
+	if _, err := store.GetCachedEmbeddings(ctx, []string{"key"}); err == nil {
+		t.Error("Expected error from GetCachedEmbeddings without connection")
+	}
+
+	if err := store.StoreCachedEmbeddings(ctx, "model", map[string][]float32{"key": {1}}); err == nil {
+		t.Error("Expected error from StoreCachedEmbeddings without connection")
+	}
+}
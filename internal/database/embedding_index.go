@@ -0,0 +1,505 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pgvector/pgvector-go"
+
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// ErrEmbeddingsIncomplete is returned when activating an embedding model that
+// has not embedded every document yet
+var ErrEmbeddingsIncomplete = errors.New("documents without embeddings of the model")
+
+// indexNamePattern matches the characters of model names not allowed in index names
+var indexNamePattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// maxIndexNameLength keeps index names below the 63 character limit of PostgreSQL identifiers
+const maxIndexNameLength = 48
+
+// EmbeddingIndexStore defines the interface for tracking the models stored
+// embeddings were generated with and for re-indexing documents with a new
+// model next to the embeddings of the active model
+type EmbeddingIndexStore interface {
+	// EnsureActiveEmbeddingModel activates the model if no model is active yet
+	// and returns the active model
+	EnsureActiveEmbeddingModel(ctx context.Context, model models.EmbeddingModel) (models.EmbeddingModel, error)
+	ListEmbeddingModels(ctx context.Context) ([]models.EmbeddingModelStatus, error)
+	// StartReindex registers the model for indexing unless it is already registered
+	StartReindex(ctx context.Context, model models.EmbeddingModel) error
+	// CountUnembeddedDocuments returns the number of documents without an
+	// embedding of the model and the total number of documents
+	CountUnembeddedDocuments(ctx context.Context, model models.EmbeddingModel) (int, int, error)
+	ListUnembeddedDocuments(ctx context.Context, model models.EmbeddingModel, limit int) ([]UnembeddedDocument, error)
+	// StoreEmbeddings stores embeddings of the model by document ID, skipping
+	// documents deleted in the meantime
+	StoreEmbeddings(ctx context.Context, model models.EmbeddingModel, embeddings map[uuid.UUID][]float32) error
+	SetReindexProgress(ctx context.Context, model models.EmbeddingModel, indexed, total int) error
+	// CreateEmbeddingIndex creates the vector index of the model without blocking writes
+	CreateEmbeddingIndex(ctx context.Context, model models.EmbeddingModel) error
+	// ActivateEmbeddingModel atomically makes the model the active model and
+	// retires the previous one, failing with ErrEmbeddingsIncomplete if a
+	// document has no embedding of the model
+	ActivateEmbeddingModel(ctx context.Context, model models.EmbeddingModel) error
+	// PruneEmbeddings deletes the embeddings of models that are neither active
+	// nor indexing and returns their number
+	PruneEmbeddings(ctx context.Context) (int64, error)
+}
+
+// UnembeddedDocument is a document without an embedding of a model
+type UnembeddedDocument struct {
+	Document models.Document
+	// Title is the title of the document's source, or the title or file name
+	// in its metadata, as documents are embedded with
+	Title string
+}
+
+// EnsureActiveEmbeddingModel activates the model if no model is active yet
+// and returns the active model
+func (p *PostgresVectorDB) EnsureActiveEmbeddingModel(ctx context.Context, model models.EmbeddingModel) (models.EmbeddingModel, error) {
+	if p.db == nil {
+		return models.EmbeddingModel{}, fmt.Errorf("database not connected")
+	}
+
+	// Begin transaction
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.EmbeddingModel{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var active models.EmbeddingModel
+	err = tx.QueryRowContext(
+		ctx,
+		"SELECT model, version, dimensions FROM rag.embedding_models WHERE state = $1",
+		models.EmbeddingModelActive,
+	).Scan(&active.Name, &active.Version, &active.Dimensions)
+	if err == nil {
+		return active, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return models.EmbeddingModel{}, fmt.Errorf("failed to get active embedding model: %w", err)
+	}
+
+	// Activate the model of the first process using the database
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO rag.embedding_models (model, version, dimensions, state, activated_at)
+		 VALUES ($1, $2, $3, $4, NOW())
+		 ON CONFLICT (model, version) DO UPDATE SET state = $4, activated_at = NOW(), updated_at = NOW()`,
+		model.Name, model.Version, model.Dimensions, models.EmbeddingModelActive,
+	)
+	if err != nil {
+		return models.EmbeddingModel{}, fmt.Errorf("failed to activate embedding model: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, createEmbeddingIndexStatement(model, false)); err != nil {
+		return models.EmbeddingModel{}, fmt.Errorf("failed to create embedding index: %w", err)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return models.EmbeddingModel{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return model, nil
+}
+
+// ListEmbeddingModels lists the registered embedding models, most recently updated first
+func (p *PostgresVectorDB) ListEmbeddingModels(ctx context.Context) ([]models.EmbeddingModelStatus, error) {
+	if p.db == nil {
+		return nil, fmt.Errorf("database not connected")
+	}
+
+	rows, err := p.db.QueryContext(
+		ctx,
+		`SELECT model, version, dimensions, state, indexed_documents, total_documents, updated_at, activated_at
+		 FROM rag.embedding_models ORDER BY updated_at DESC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list embedding models: %w", err)
+	}
+	defer rows.Close()
+
+	var statuses []models.EmbeddingModelStatus
+	for rows.Next() {
+		var status models.EmbeddingModelStatus
+		var activatedAt sql.NullTime
+		if err := rows.Scan(
+			&status.Name, &status.Version, &status.Dimensions, &status.State,
+			&status.IndexedDocuments, &status.TotalDocuments, &status.UpdatedAt, &activatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan embedding model row: %w", err)
+		}
+		if activatedAt.Valid {
+			status.ActivatedAt = &activatedAt.Time
+		}
+		statuses = append(statuses, status)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating embedding model rows: %w", err)
+	}
+
+	return statuses, nil
+}
+
+// StartReindex registers the model for indexing unless it is already
+// registered, in which case retired models are indexed again
+func (p *PostgresVectorDB) StartReindex(ctx context.Context, model models.EmbeddingModel) error {
+	if p.db == nil {
+		return fmt.Errorf("database not connected")
+	}
+
+	var dimensions int
+	err := p.db.QueryRowContext(
+		ctx,
+		`INSERT INTO rag.embedding_models (model, version, dimensions, state)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (model, version) DO UPDATE SET
+		   state = CASE WHEN rag.embedding_models.state = $5 THEN $4 ELSE rag.embedding_models.state END,
+		   updated_at = NOW()
+		 RETURNING dimensions`,
+		model.Name, model.Version, model.Dimensions, models.EmbeddingModelIndexing, models.EmbeddingModelRetired,
+	).Scan(&dimensions)
+	if err != nil {
+		return fmt.Errorf("failed to register embedding model: %w", err)
+	}
+	if dimensions != model.Dimensions {
+		return fmt.Errorf("embedding model %s is registered with %d dimensions, not %d", model, dimensions, model.Dimensions)
+	}
+
+	return nil
+}
+
+// CountUnembeddedDocuments returns the number of documents without an
+// embedding of the model and the total number of documents
+func (p *PostgresVectorDB) CountUnembeddedDocuments(ctx context.Context, model models.EmbeddingModel) (int, int, error) {
+	if p.db == nil {
+		return 0, 0, fmt.Errorf("database not connected")
+	}
+
+	var missing, total int
+	err := p.db.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*) FILTER (WHERE NOT EXISTS (
+		   SELECT 1 FROM rag.embeddings e WHERE e.document_id = d.id AND e.model = $1 AND e.model_version = $2
+		 )), COUNT(*)
+		 FROM rag.documents d`,
+		model.Name, model.Version,
+	).Scan(&missing, &total)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count unembedded documents: %w", err)
+	}
+
+	return missing, total, nil
+}
+
+// ListUnembeddedDocuments retrieves up to limit documents without an embedding of the model
+func (p *PostgresVectorDB) ListUnembeddedDocuments(
+	ctx context.Context,
+	model models.EmbeddingModel,
+	limit int,
+) ([]UnembeddedDocument, error) {
+	if p.db == nil {
+		return nil, fmt.Errorf("database not connected")
+	}
+
+	rows, err := p.db.QueryContext(
+		ctx,
+		`SELECT d.id, d.source_id, d.position, d.content, d.metadata, d.created_at, d.updated_at,
+		   COALESCE(NULLIF(s.title, ''), d.metadata->>'title', d.metadata->>'file_name', '')
+		 FROM rag.documents d
+		 LEFT JOIN rag.sources s ON s.id = d.source_id
+		 WHERE NOT EXISTS (
+		   SELECT 1 FROM rag.embeddings e WHERE e.document_id = d.id AND e.model = $1 AND e.model_version = $2
+		 )
+		 ORDER BY d.source_id, d.position, d.id
+		 LIMIT $3`,
+		model.Name, model.Version, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unembedded documents: %w", err)
+	}
+	defer rows.Close()
+
+	var documents []UnembeddedDocument
+	for rows.Next() {
+		var document UnembeddedDocument
+		doc := &document.Document
+		var sourceID uuid.NullUUID
+		var metadataJSON []byte
+
+		if err := rows.Scan(
+			&doc.ID, &sourceID, &doc.Position, &doc.Content, &metadataJSON, &doc.CreatedAt, &doc.UpdatedAt, &document.Title,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan document row: %w", err)
+		}
+		doc.SourceID = uuidPointer(sourceID)
+
+		// Parse metadata
+		if len(metadataJSON) > 0 {
+			if err := json.Unmarshal(metadataJSON, &doc.Metadata); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+			}
+		}
+
+		documents = append(documents, document)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating document rows: %w", err)
+	}
+
+	return documents, nil
+}
+
+// StoreEmbeddings stores embeddings of the model by document ID, skipping
+// documents deleted in the meantime and documents that already have one
+func (p *PostgresVectorDB) StoreEmbeddings(
+	ctx context.Context,
+	model models.EmbeddingModel,
+	embeddings map[uuid.UUID][]float32,
+) error {
+	if p.db == nil {
+		return fmt.Errorf("database not connected")
+	}
+
+	// Begin transaction
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for documentID, embedding := range embeddings {
+		if err := checkDimensions(model, embedding); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(
+			ctx,
+			`INSERT INTO rag.embeddings (id, document_id, model, model_version, embedding, created_at, updated_at)
+			 SELECT $1, d.id, $3, $4, $5, $6, $6 FROM rag.documents d WHERE d.id = $2
+			 ON CONFLICT (document_id, model, model_version) DO NOTHING`,
+			uuid.New(), documentID, model.Name, model.Version, pgvector.NewVector(embedding), now,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert embedding: %w", err)
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// SetReindexProgress records how many of the documents have an embedding of the model
+func (p *PostgresVectorDB) SetReindexProgress(ctx context.Context, model models.EmbeddingModel, indexed, total int) error {
+	if p.db == nil {
+		return fmt.Errorf("database not connected")
+	}
+
+	_, err := p.db.ExecContext(
+		ctx,
+		`UPDATE rag.embedding_models SET indexed_documents = $3, total_documents = $4, updated_at = NOW()
+		 WHERE model = $1 AND version = $2`,
+		model.Name, model.Version, indexed, total,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update re-index progress: %w", err)
+	}
+
+	return nil
+}
+
+// CreateEmbeddingIndex creates the vector index of the model concurrently, so
+// that documents can be written meanwhile
+func (p *PostgresVectorDB) CreateEmbeddingIndex(ctx context.Context, model models.EmbeddingModel) error {
+	if p.db == nil {
+		return fmt.Errorf("database not connected")
+	}
+
+	if _, err := p.db.ExecContext(ctx, createEmbeddingIndexStatement(model, true)); err != nil {
+		return fmt.Errorf("failed to create embedding index: %w", err)
+	}
+
+	return nil
+}
+
+// ActivateEmbeddingModel atomically makes the model the active model and
+// retires the previous one. Writes of documents are blocked while checking
+// that every document has an embedding of the model.
+func (p *PostgresVectorDB) ActivateEmbeddingModel(ctx context.Context, model models.EmbeddingModel) error {
+	if p.db == nil {
+		return fmt.Errorf("database not connected")
+	}
+
+	// Begin transaction
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "LOCK TABLE rag.documents IN SHARE MODE"); err != nil {
+		return fmt.Errorf("failed to lock documents: %w", err)
+	}
+
+	var missing int
+	err = tx.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*) FROM rag.documents d WHERE NOT EXISTS (
+		   SELECT 1 FROM rag.embeddings e WHERE e.document_id = d.id AND e.model = $1 AND e.model_version = $2
+		 )`,
+		model.Name, model.Version,
+	).Scan(&missing)
+	if err != nil {
+		return fmt.Errorf("failed to count unembedded documents: %w", err)
+	}
+	if missing > 0 {
+		return fmt.Errorf("%w: %d documents", ErrEmbeddingsIncomplete, missing)
+	}
+
+	// Retire the previous model first, as only one model may be active
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE rag.embedding_models SET state = $3, updated_at = NOW()
+		 WHERE state = $4 AND NOT (model = $1 AND version = $2)`,
+		model.Name, model.Version, models.EmbeddingModelRetired, models.EmbeddingModelActive,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to retire embedding model: %w", err)
+	}
+
+	result, err := tx.ExecContext(
+		ctx,
+		`UPDATE rag.embedding_models SET state = $3, activated_at = NOW(), updated_at = NOW()
+		 WHERE model = $1 AND version = $2`,
+		model.Name, model.Version, models.EmbeddingModelActive,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to activate embedding model: %w", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	} else if rowsAffected == 0 {
+		return fmt.Errorf("embedding model %s is not registered", model)
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// PruneEmbeddings deletes the embeddings and indexes of models that are
+// neither active nor indexing, and the registrations of retired models
+func (p *PostgresVectorDB) PruneEmbeddings(ctx context.Context) (int64, error) {
+	if p.db == nil {
+		return 0, fmt.Errorf("database not connected")
+	}
+
+	// Collect the models to drop the indexes of, including unregistered ones
+	rows, err := p.db.QueryContext(
+		ctx,
+		`SELECT DISTINCT e.model, e.model_version FROM rag.embeddings e
+		 WHERE NOT EXISTS (
+		   SELECT 1 FROM rag.embedding_models m
+		   WHERE m.model = e.model AND m.version = e.model_version AND m.state <> $1
+		 )
+		 UNION
+		 SELECT model, version FROM rag.embedding_models WHERE state = $1`,
+		models.EmbeddingModelRetired,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list pruned embedding models: %w", err)
+	}
+	var pruned []models.EmbeddingModel
+	for rows.Next() {
+		var model models.EmbeddingModel
+		if err := rows.Scan(&model.Name, &model.Version); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan embedding model row: %w", err)
+		}
+		pruned = append(pruned, model)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating embedding model rows: %w", err)
+	}
+
+	var deleted int64
+	for _, model := range pruned {
+		result, err := p.db.ExecContext(
+			ctx,
+			"DELETE FROM rag.embeddings WHERE model = $1 AND model_version = $2",
+			model.Name, model.Version,
+		)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete embeddings of %s: %w", model, err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return deleted, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		deleted += rowsAffected
+
+		if _, err := p.db.ExecContext(ctx, "DROP INDEX CONCURRENTLY IF EXISTS rag."+embeddingIndexName(model)); err != nil {
+			return deleted, fmt.Errorf("failed to drop embedding index of %s: %w", model, err)
+		}
+		_, err = p.db.ExecContext(
+			ctx,
+			"DELETE FROM rag.embedding_models WHERE model = $1 AND version = $2 AND state = $3",
+			model.Name, model.Version, models.EmbeddingModelRetired,
+		)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to unregister embedding model %s: %w", model, err)
+		}
+	}
+
+	return deleted, nil
+}
+
+// createEmbeddingIndexStatement returns the statement creating the partial
+// vector index of the model on its embeddings cast to its dimensions
+func createEmbeddingIndexStatement(model models.EmbeddingModel, concurrently bool) string {
+	create := "CREATE INDEX"
+	if concurrently {
+		create += " CONCURRENTLY"
+	}
+	return fmt.Sprintf(
+		`%s IF NOT EXISTS %s ON rag.embeddings
+		 USING ivfflat ((embedding::vector(%d)) vector_cosine_ops) WITH (lists = 100)
+		 WHERE model = %s AND model_version = %s`,
+		create, embeddingIndexName(model), model.Dimensions, pq.QuoteLiteral(model.Name), pq.QuoteLiteral(model.Version),
+	)
+}
+
+// embeddingIndexName returns the name of the vector index of the model, e.g.
+// embeddings_embedding_001_idx. Long names are shortened with a hash.
+func embeddingIndexName(model models.EmbeddingModel) string {
+	name := strings.Trim(indexNamePattern.ReplaceAllString(strings.ToLower(model.String()), "_"), "_")
+	if len(name) > maxIndexNameLength {
+		sum := sha256.Sum256([]byte(model.String()))
+		name = name[:maxIndexNameLength-9] + "_" + hex.EncodeToString(sum[:4])
+	}
+	return "embeddings_" + name + "_idx"
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// TestEmbeddingIndexStoreRequiresConnection tests that re-indexing methods fail before Connect
+func TestEmbeddingIndexStoreRequiresConnection(t *testing.T) {
+	var store EmbeddingIndexStore = &PostgresVectorDB{}
+	ctx := context.Background()
+	model := models.EmbeddingModel{Name: "text-embedding-004", Dimensions: 768}
+
+	if _, err := store.EnsureActiveEmbeddingModel(ctx, model); err == nil {
+		t.Error("Expected error from EnsureActiveEmbeddingModel without connection")
+	}
+	if err := store.StartReindex(ctx, model); err == nil {
+		t.Error("Expected error from StartReindex without connection")
+	}
+	if _, err := store.ListUnembeddedDocuments(ctx, model, 10); err == nil {
+		t.Error("Expected error from ListUnembeddedDocuments without connection")
+	}
+	if err := store.StoreEmbeddings(ctx, model, map[uuid.UUID][]float32{uuid.New(): {0.1}}); err == nil {
+		t.Error("Expected error from StoreEmbeddings without connection")
+	}
+	if err := store.ActivateEmbeddingModel(ctx, model); err == nil {
+		t.Error("Expected error from ActivateEmbeddingModel without connection")
+	}
+	if _, err := store.PruneEmbeddings(ctx); err == nil {
+		t.Error("Expected error from PruneEmbeddings without connection")
+	}
+}
+
+// TestEmbeddingIndexName tests naming the vector indexes of embedding models
+func TestEmbeddingIndexName(t *testing.T) {
+	tests := []struct {
+		model    models.EmbeddingModel
+		expected string
+	}{
+		// The index of the former default model is created by the init scripts
+		{model: models.EmbeddingModel{Name: "embedding-001"}, expected: "embeddings_embedding_001_idx"},
+		{model: models.EmbeddingModel{Name: "BAAI/bge-small-en-v1.5", Version: "2"}, expected: "embeddings_baai_bge_small_en_v1_5_2_idx"},
+	}
+	for _, tt := range tests {
+		if name := embeddingIndexName(tt.model); name != tt.expected {
+			t.Errorf("Expected %s, got %s", tt.expected, name)
+		}
+	}
+
+	// Long names are shortened, keeping them distinct
+	long := embeddingIndexName(models.EmbeddingModel{Name: strings.Repeat("model", 20), Version: "1"})
+	other := embeddingIndexName(models.EmbeddingModel{Name: strings.Repeat("model", 20), Version: "2"})
+	if len(long) > 63 || long == other {
+		t.Errorf("Expected distinct names of at most 63 characters, got %s and %s", long, other)
+	}
+
+	statement := createEmbeddingIndexStatement(models.EmbeddingModel{Name: "embedding-001", Dimensions: 768}, true)
+	for _, expected := range []string{
+		"CREATE INDEX CONCURRENTLY IF NOT EXISTS embeddings_embedding_001_idx",
+		"embedding::vector(768)",
+		"WHERE model = 'embedding-001' AND model_version = ''",
+	} {
+		if !strings.Contains(statement, expected) {
+			t.Errorf("Expected statement to contain %q, got %s", expected, statement)
+		}
+	}
+}
@@ -4,12 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/pgvector/pgvector-go"
 
 	"github.com/yourusername/go-rag/internal/models"
@@ -24,20 +25,47 @@ type VectorDB interface {
 	GetDocument(ctx context.Context, id uuid.UUID) (models.Document, error)
 	ListDocuments(ctx context.Context, limit, offset int) ([]models.Document, error)
 	DeleteDocument(ctx context.Context, id uuid.UUID) error
+	StoreSource(ctx context.Context, source models.Source) error
+	ReplaceSource(ctx context.Context, source models.Source, docs []models.Document, embeddings [][]float32) error
+	GetSource(ctx context.Context, id uuid.UUID) (models.Source, error)
+	FindSourceByURI(ctx context.Context, uri string) (models.Source, error)
+	ListSources(ctx context.Context, limit, offset int) ([]models.Source, error)
+	ListSourceChunks(ctx context.Context, sourceID uuid.UUID) ([]models.Document, error)
+	ListSourceChunksInRange(ctx context.Context, sourceID uuid.UUID, from, to int) ([]models.Document, error)
+	DeleteSource(ctx context.Context, id uuid.UUID) error
+	CorpusVersion(ctx context.Context) (int64, error)
 }
 
+// ErrSourceNotFound is returned when a requested source does not exist
+var ErrSourceNotFound = errors.New("source not found")
+
+// ErrEmbeddingModelInactive is returned when storing or searching embeddings
+// of a model other than the active embedding model
+var ErrEmbeddingModelInactive = errors.New("embedding model is not the active embedding model")
+
 // PostgresVectorDB is a PostgreSQL implementation of VectorDB with pgvector extension
 type PostgresVectorDB struct {
-	connStr    string
-	db         *sql.DB
-	dimensions int
+	connStr string
+	db      *sql.DB
+	// model is the embedding model documents are stored and searched with,
+	// which must be the active model registered in rag.embedding_models
+	model models.EmbeddingModel
 }
 
 // NewPostgresVectorDB creates a new PostgreSQL vector database connection
-func NewPostgresVectorDB(connectionString string, dimensions int) (VectorDB, error) {
+// storing and searching embeddings of the given model. Writes and searches
+// fail with ErrEmbeddingModelInactive while another model is active.
+func NewPostgresVectorDB(connectionString string, model models.EmbeddingModel) (VectorDB, error) {
+	if model.Name == "" {
+		return nil, fmt.Errorf("embedding model is required")
+	}
+	if model.Dimensions <= 0 {
+		return nil, fmt.Errorf("embedding dimensions must be positive, got %d", model.Dimensions)
+	}
+
 	return &PostgresVectorDB{
-		connStr:    connectionString,
-		dimensions: dimensions,
+		connStr: connectionString,
+		model:   model,
 	}, nil
 }
 
@@ -75,18 +103,34 @@ func (p *PostgresVectorDB) StoreDocument(ctx context.Context, doc models.Documen
 	if p.db == nil {
 		return fmt.Errorf("database not connected")
 	}
+	if err := checkDimensions(p.model, embedding); err != nil {
+		return err
+	}
 
 	// Begin transaction
 	tx, err := p.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
+	defer tx.Rollback()
 
+	if err := p.insertDocument(ctx, tx, doc, embedding); err != nil {
+		return err
+	}
+	if err := p.requireActiveModel(ctx, tx); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// insertDocument inserts a document and its embedding within a transaction
+func (p *PostgresVectorDB) insertDocument(ctx context.Context, tx *sql.Tx, doc models.Document, embedding []float32) error {
 	// Convert metadata to JSON
 	metadataJSON, err := json.Marshal(doc.Metadata)
 	if err != nil {
@@ -96,8 +140,9 @@ func (p *PostgresVectorDB) StoreDocument(ctx context.Context, doc models.Documen
 	// Insert document
 	_, err = tx.ExecContext(
 		ctx,
-		"INSERT INTO rag.documents (id, content, metadata, created_at, updated_at) VALUES ($1, $2, $3, $4, $5)",
-		doc.ID, doc.Content, metadataJSON, doc.CreatedAt, doc.UpdatedAt,
+		`INSERT INTO rag.documents (id, source_id, position, content, metadata, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		doc.ID, nullableUUID(doc.SourceID), doc.Position, doc.Content, metadataJSON, doc.CreatedAt, doc.UpdatedAt,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert document: %w", err)
@@ -109,37 +154,46 @@ func (p *PostgresVectorDB) StoreDocument(ctx context.Context, doc models.Documen
 	// Insert embedding
 	_, err = tx.ExecContext(
 		ctx,
-		"INSERT INTO rag.embeddings (id, document_id, embedding, created_at, updated_at) VALUES ($1, $2, $3, $4, $5)",
-		uuid.New(), doc.ID, vector, time.Now(), time.Now(),
+		`INSERT INTO rag.embeddings (id, document_id, model, model_version, embedding, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		uuid.New(), doc.ID, p.model.Name, p.model.Version, vector, time.Now(), time.Now(),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert embedding: %w", err)
 	}
 
-	// Commit transaction
-	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
 	return nil
 }
 
-// FindSimilar finds documents similar to the query vector
+// FindSimilar finds documents similar to the query vector among the
+// embeddings of the database's embedding model
 func (p *PostgresVectorDB) FindSimilar(ctx context.Context, query models.VectorQuery) ([]models.SearchResult, error) {
 	if p.db == nil {
 		return nil, fmt.Errorf("database not connected")
 	}
+	if err := checkDimensions(p.model, query.Vector); err != nil {
+		return nil, err
+	}
+	if err := p.requireActiveModel(ctx, p.db); err != nil {
+		return nil, err
+	}
 
 	// Convert query vector to pgvector
 	queryVector := pgvector.NewVector(query.Vector)
 
-	// Use the similarity search function
+	// Search the nearest embeddings of the model first, selecting their vectors when requested
+	columns := "d.id, d.source_id, d.position, d.content, d.metadata, sr.similarity"
+	if query.IncludeVectors {
+		columns += ", sr.embedding"
+	}
 	rows, err := p.db.QueryContext(
 		ctx,
-		`SELECT d.id, d.content, d.metadata, similarity
-		 FROM rag.search_similar_documents($1, $2, $3) as sr
-		 JOIN rag.documents d ON sr.id = d.id`,
-		queryVector, 0.0, query.Limit,
+		fmt.Sprintf(`SELECT %s
+		 FROM (%s) AS sr
+		 JOIN rag.documents d ON sr.document_id = d.id
+		 WHERE sr.similarity > 0
+		 ORDER BY sr.similarity DESC`, columns, nearestEmbeddingsQuery(p.model)),
+		queryVector, query.Limit,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute similarity search: %w", err)
@@ -149,12 +203,19 @@ func (p *PostgresVectorDB) FindSimilar(ctx context.Context, query models.VectorQ
 	var results []models.SearchResult
 	for rows.Next() {
 		var doc models.Document
+		var sourceID uuid.NullUUID
 		var metadataJSON []byte
 		var similarity float32
+		var vector pgvector.Vector
 
-		if err := rows.Scan(&doc.ID, &doc.Content, &metadataJSON, &similarity); err != nil {
+		dest := []interface{}{&doc.ID, &sourceID, &doc.Position, &doc.Content, &metadataJSON, &similarity}
+		if query.IncludeVectors {
+			dest = append(dest, &vector)
+		}
+		if err := rows.Scan(dest...); err != nil {
 			return nil, fmt.Errorf("failed to scan result row: %w", err)
 		}
+		doc.SourceID = uuidPointer(sourceID)
 
 		// Parse metadata
 		if len(metadataJSON) > 0 {
@@ -166,6 +227,7 @@ func (p *PostgresVectorDB) FindSimilar(ctx context.Context, query models.VectorQ
 		results = append(results, models.SearchResult{
 			Document:   doc,
 			Similarity: similarity,
+			Vector:     vector.Slice(),
 		})
 	}
 
@@ -183,13 +245,15 @@ func (p *PostgresVectorDB) GetDocument(ctx context.Context, id uuid.UUID) (model
 	}
 
 	var doc models.Document
+	var sourceID uuid.NullUUID
 	var metadataJSON []byte
 
 	err := p.db.QueryRowContext(
 		ctx,
-		"SELECT id, content, metadata, created_at, updated_at FROM rag.documents WHERE id = $1",
+		"SELECT id, source_id, position, content, metadata, created_at, updated_at FROM rag.documents WHERE id = $1",
 		id,
-	).Scan(&doc.ID, &doc.Content, &metadataJSON, &doc.CreatedAt, &doc.UpdatedAt)
+	).Scan(&doc.ID, &sourceID, &doc.Position, &doc.Content, &metadataJSON, &doc.CreatedAt, &doc.UpdatedAt)
+	doc.SourceID = uuidPointer(sourceID)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -221,7 +285,8 @@ func (p *PostgresVectorDB) ListDocuments(ctx context.Context, limit, offset int)
 
 	rows, err := p.db.QueryContext(
 		ctx,
-		"SELECT id, content, metadata, created_at, updated_at FROM rag.documents ORDER BY created_at DESC LIMIT $1 OFFSET $2",
+		`SELECT id, source_id, position, content, metadata, created_at, updated_at
+		 FROM rag.documents ORDER BY created_at DESC LIMIT $1 OFFSET $2`,
 		limit, offset,
 	)
 	if err != nil {
@@ -232,11 +297,13 @@ func (p *PostgresVectorDB) ListDocuments(ctx context.Context, limit, offset int)
 	var documents []models.Document
 	for rows.Next() {
 		var doc models.Document
+		var sourceID uuid.NullUUID
 		var metadataJSON []byte
 
-		if err := rows.Scan(&doc.ID, &doc.Content, &metadataJSON, &doc.CreatedAt, &doc.UpdatedAt); err != nil {
+		if err := rows.Scan(&doc.ID, &sourceID, &doc.Position, &doc.Content, &metadataJSON, &doc.CreatedAt, &doc.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan document row: %w", err)
 		}
+		doc.SourceID = uuidPointer(sourceID)
 
 		// Parse metadata
 		if len(metadataJSON) > 0 {
@@ -298,3 +365,360 @@ func (p *PostgresVectorDB) DeleteDocument(ctx context.Context, id uuid.UUID) err
 
 	return nil
 }
+
+// StoreSource stores a source record
+func (p *PostgresVectorDB) StoreSource(ctx context.Context, source models.Source) error {
+	if p.db == nil {
+		return fmt.Errorf("database not connected")
+	}
+
+	// Convert metadata to JSON
+	metadataJSON, err := json.Marshal(source.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	_, err = p.db.ExecContext(
+		ctx,
+		`INSERT INTO rag.sources (id, uri, title, checksum, metadata, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		source.ID, nullableString(source.URI), source.Title, source.Checksum, metadataJSON, source.CreatedAt, source.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert source: %w", err)
+	}
+
+	return nil
+}
+
+// ReplaceSource stores a source together with its documents and their
+// embeddings in a single transaction, replacing the source with the same URI
+// and its documents if one exists. On failure the previous source is kept.
+func (p *PostgresVectorDB) ReplaceSource(
+	ctx context.Context,
+	source models.Source,
+	docs []models.Document,
+	embeddings [][]float32,
+) error {
+	if p.db == nil {
+		return fmt.Errorf("database not connected")
+	}
+	if len(docs) != len(embeddings) {
+		return fmt.Errorf("expected %d embeddings, got %d", len(docs), len(embeddings))
+	}
+	for _, embedding := range embeddings {
+		if err := checkDimensions(p.model, embedding); err != nil {
+			return err
+		}
+	}
+
+	// Convert metadata to JSON
+	metadataJSON, err := json.Marshal(source.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	// Begin transaction
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Delete the previous source (cascade will delete its documents and their embeddings)
+	if source.URI != "" {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM rag.sources WHERE uri = $1", source.URI); err != nil {
+			return fmt.Errorf("failed to delete previous source: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO rag.sources (id, uri, title, checksum, metadata, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		source.ID, nullableString(source.URI), source.Title, source.Checksum, metadataJSON, source.CreatedAt, source.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert source: %w", err)
+	}
+
+	for i, doc := range docs {
+		if err := p.insertDocument(ctx, tx, doc, embeddings[i]); err != nil {
+			return fmt.Errorf("failed to store chunk %d: %w", i, err)
+		}
+	}
+	if err := p.requireActiveModel(ctx, tx); err != nil {
+		return err
+	}
+
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetSource retrieves a source by ID
+func (p *PostgresVectorDB) GetSource(ctx context.Context, id uuid.UUID) (models.Source, error) {
+	return p.getSourceWhere(ctx, "id = $1", id)
+}
+
+// FindSourceByURI retrieves a source by its URI
+func (p *PostgresVectorDB) FindSourceByURI(ctx context.Context, uri string) (models.Source, error) {
+	return p.getSourceWhere(ctx, "uri = $1", uri)
+}
+
+// getSourceWhere retrieves a single source matching the given condition
+func (p *PostgresVectorDB) getSourceWhere(ctx context.Context, condition string, arg interface{}) (models.Source, error) {
+	if p.db == nil {
+		return models.Source{}, fmt.Errorf("database not connected")
+	}
+
+	row := p.db.QueryRowContext(
+		ctx,
+		"SELECT id, uri, title, checksum, metadata, created_at, updated_at FROM rag.sources WHERE "+condition,
+		arg,
+	)
+
+	source, err := scanSource(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.Source{}, ErrSourceNotFound
+		}
+		return models.Source{}, fmt.Errorf("failed to get source: %w", err)
+	}
+
+	return source, nil
+}
+
+// ListSources retrieves a list of sources with pagination
+func (p *PostgresVectorDB) ListSources(ctx context.Context, limit, offset int) ([]models.Source, error) {
+	if p.db == nil {
+		return nil, fmt.Errorf("database not connected")
+	}
+
+	// Set default values if needed
+	if limit <= 0 {
+		limit = 10
+	}
+
+	rows, err := p.db.QueryContext(
+		ctx,
+		`SELECT id, uri, title, checksum, metadata, created_at, updated_at
+		 FROM rag.sources ORDER BY created_at DESC LIMIT $1 OFFSET $2`,
+		limit, offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sources: %w", err)
+	}
+	defer rows.Close()
+
+	var sources []models.Source
+	for rows.Next() {
+		source, err := scanSource(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan source row: %w", err)
+		}
+		sources = append(sources, source)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating source rows: %w", err)
+	}
+
+	return sources, nil
+}
+
+// ListSourceChunks retrieves all documents of a source ordered by position
+func (p *PostgresVectorDB) ListSourceChunks(ctx context.Context, sourceID uuid.UUID) ([]models.Document, error) {
+	return p.listSourceChunks(ctx, sourceID, "", nil)
+}
+
+// ListSourceChunksInRange retrieves the documents of a source with positions
+// between from and to (inclusive) ordered by position
+func (p *PostgresVectorDB) ListSourceChunksInRange(ctx context.Context, sourceID uuid.UUID, from, to int) ([]models.Document, error) {
+	return p.listSourceChunks(ctx, sourceID, " AND position BETWEEN $2 AND $3", []interface{}{from, to})
+}
+
+// listSourceChunks retrieves the documents of a source matching an additional condition
+func (p *PostgresVectorDB) listSourceChunks(
+	ctx context.Context,
+	sourceID uuid.UUID,
+	condition string,
+	args []interface{},
+) ([]models.Document, error) {
+	if p.db == nil {
+		return nil, fmt.Errorf("database not connected")
+	}
+
+	rows, err := p.db.QueryContext(
+		ctx,
+		`SELECT id, position, content, metadata, created_at, updated_at
+		 FROM rag.documents WHERE source_id = $1`+condition+` ORDER BY position`,
+		append([]interface{}{sourceID}, args...)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var documents []models.Document
+	for rows.Next() {
+		doc := models.Document{SourceID: &sourceID}
+		var metadataJSON []byte
+
+		if err := rows.Scan(&doc.ID, &doc.Position, &doc.Content, &metadataJSON, &doc.CreatedAt, &doc.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan document row: %w", err)
+		}
+
+		// Parse metadata
+		if len(metadataJSON) > 0 {
+			if err := json.Unmarshal(metadataJSON, &doc.Metadata); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+			}
+		}
+
+		documents = append(documents, doc)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating document rows: %w", err)
+	}
+
+	return documents, nil
+}
+
+// DeleteSource deletes a source together with all of its documents and embeddings
+func (p *PostgresVectorDB) DeleteSource(ctx context.Context, id uuid.UUID) error {
+	if p.db == nil {
+		return fmt.Errorf("database not connected")
+	}
+
+	// Delete source (cascade will delete documents and their embeddings)
+	result, err := p.db.ExecContext(ctx, "DELETE FROM rag.sources WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete source: %w", err)
+	}
+
+	// Check if any rows were affected
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrSourceNotFound
+	}
+
+	return nil
+}
+
+// CorpusVersion returns a counter that changes whenever documents or
+// embeddings are written, including by other processes
+func (p *PostgresVectorDB) CorpusVersion(ctx context.Context) (int64, error) {
+	if p.db == nil {
+		return 0, fmt.Errorf("database not connected")
+	}
+
+	var version int64
+	if err := p.db.QueryRowContext(ctx, "SELECT version FROM rag.corpus_state").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to get corpus version: %w", err)
+	}
+
+	return version, nil
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// requireActiveModel returns ErrEmbeddingModelInactive unless the model of the
+// database is the active embedding model. Writes check it after inserting
+// documents: activating a model locks the documents table, so a write either
+// commits before the activation checks for documents without embeddings of
+// the new model, or sees the new model active and fails.
+func (p *PostgresVectorDB) requireActiveModel(ctx context.Context, q queryer) error {
+	var active bool
+	err := q.QueryRowContext(
+		ctx,
+		"SELECT state = $3 FROM rag.embedding_models WHERE model = $1 AND version = $2",
+		p.model.Name, p.model.Version, models.EmbeddingModelActive,
+	).Scan(&active)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to get state of embedding model: %w", err)
+	}
+	if !active {
+		return fmt.Errorf("%w: %s", ErrEmbeddingModelInactive, p.model)
+	}
+	return nil
+}
+
+// nearestEmbeddingsQuery returns the query for the $2 embeddings of the model
+// nearest to the vector $1. The model is inlined, so that the planner uses the
+// partial index of the model, and embeddings are cast to the dimensions of the
+// index.
+func nearestEmbeddingsQuery(model models.EmbeddingModel) string {
+	distance := fmt.Sprintf("e.embedding::vector(%d) <=> $1", model.Dimensions)
+	return fmt.Sprintf(`SELECT e.document_id, e.embedding, 1 - (%s) AS similarity
+		 FROM rag.embeddings e
+		 WHERE e.model = %s AND e.model_version = %s
+		 ORDER BY %s
+		 LIMIT $2`, distance, pq.QuoteLiteral(model.Name), pq.QuoteLiteral(model.Version), distance)
+}
+
+// checkDimensions returns an error unless the vector has the dimensions of the model
+func checkDimensions(model models.EmbeddingModel, vector []float32) error {
+	if len(vector) != model.Dimensions {
+		return fmt.Errorf("expected %d dimensions for embedding model %s, got %d", model.Dimensions, model, len(vector))
+	}
+	return nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSource scans a source row selected as id, uri, title, checksum, metadata, created_at, updated_at
+func scanSource(row rowScanner) (models.Source, error) {
+	var source models.Source
+	var uri, title sql.NullString
+	var metadataJSON []byte
+
+	if err := row.Scan(&source.ID, &uri, &title, &source.Checksum, &metadataJSON, &source.CreatedAt, &source.UpdatedAt); err != nil {
+		return models.Source{}, err
+	}
+	source.URI = uri.String
+	source.Title = title.String
+
+	// Parse metadata
+	if len(metadataJSON) > 0 {
+		if err := json.Unmarshal(metadataJSON, &source.Metadata); err != nil {
+			return models.Source{}, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+
+	return source, nil
+}
+
+// nullableUUID converts an optional UUID into a value suitable for a nullable column
+func nullableUUID(id *uuid.UUID) uuid.NullUUID {
+	if id == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: *id, Valid: true}
+}
+
+// uuidPointer converts a nullable UUID column value into an optional UUID
+func uuidPointer(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
+
+// nullableString converts an empty string into NULL
+func nullableString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
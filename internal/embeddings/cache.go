@@ -0,0 +1,236 @@
+package embeddings
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/yourusername/go-rag/internal/database"
+)
+
+// CacheStats counts where the embeddings requested from a CachedEmbeddingService came from
+type CacheStats struct {
+	MemoryHits int64
+	StoreHits  int64
+	Misses     int64
+}
+
+// CachedEmbeddingService is an EmbeddingService decorator that reuses
+// embeddings of texts it has already embedded with the same model and task
+// type. Embeddings are kept in an in-memory LRU and, when a store is set, in a
+// persistent store shared between processes.
+type CachedEmbeddingService struct {
+	next  EmbeddingService
+	model string
+	store database.EmbeddingCacheStore
+
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+
+	memoryHits atomic.Int64
+	storeHits  atomic.Int64
+	misses     atomic.Int64
+}
+
+// lruEntry is an embedding in the LRU
+type lruEntry struct {
+	key       string
+	embedding []float32
+}
+
+// NewCachedEmbeddingService wraps next with a cache of up to capacity
+// embeddings in memory and the optional persistent store. model is the
+// embedding model of next and part of every cache key.
+func NewCachedEmbeddingService(
+	next EmbeddingService,
+	model string,
+	capacity int,
+	store database.EmbeddingCacheStore,
+) *CachedEmbeddingService {
+	return &CachedEmbeddingService{
+		next:     next,
+		model:    model,
+		store:    store,
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// GenerateEmbedding returns the cached embedding of the text or generates it
+func (s *CachedEmbeddingService) GenerateEmbedding(ctx context.Context, text string, options Options) ([]float32, error) {
+	embeddings, err := s.BatchGenerateEmbeddings(ctx, []string{text}, options)
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// BatchGenerateEmbeddings returns the cached embeddings of the texts and
+// generates only the missing ones, in a single batch
+func (s *CachedEmbeddingService) BatchGenerateEmbeddings(ctx context.Context, texts []string, options Options) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("texts cannot be empty")
+	}
+
+	embeddings := make([][]float32, len(texts))
+	keys := make([]string, len(texts))
+	missing := make(map[string][]int)
+	for i, text := range texts {
+		keys[i] = s.cacheKey(options, text)
+		if embedding, ok := s.get(keys[i]); ok {
+			embeddings[i] = embedding
+			s.memoryHits.Add(1)
+			continue
+		}
+		missing[keys[i]] = append(missing[keys[i]], i)
+	}
+	if len(missing) == 0 {
+		return embeddings, nil
+	}
+
+	// Look the remaining texts up in the persistent store
+	if s.store != nil {
+		stored, err := s.store.GetCachedEmbeddings(ctx, mapKeys(missing))
+		if err != nil {
+			log.Printf("Failed to read embedding cache, generating embeddings: %v", err)
+		}
+		for key, embedding := range stored {
+			for _, i := range missing[key] {
+				embeddings[i] = embedding
+			}
+			s.storeHits.Add(int64(len(missing[key])))
+			s.put(key, embedding)
+			delete(missing, key)
+		}
+		if len(missing) == 0 {
+			return embeddings, nil
+		}
+	}
+
+	// Generate each missing text once
+	var pending []string
+	var pendingKeys []string
+	for _, key := range orderedMissingKeys(keys, missing) {
+		pending = append(pending, texts[missing[key][0]])
+		pendingKeys = append(pendingKeys, key)
+	}
+	generated, err := s.next.BatchGenerateEmbeddings(ctx, pending, options)
+	if err != nil {
+		return nil, err
+	}
+	if len(generated) != len(pending) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(pending), len(generated))
+	}
+	s.misses.Add(int64(len(pending)))
+
+	newEmbeddings := make(map[string][]float32, len(pending))
+	for j, key := range pendingKeys {
+		for _, i := range missing[key] {
+			embeddings[i] = generated[j]
+		}
+		s.put(key, generated[j])
+		newEmbeddings[key] = generated[j]
+	}
+
+	if s.store != nil {
+		if err := s.store.StoreCachedEmbeddings(ctx, s.model, newEmbeddings); err != nil {
+			log.Printf("Failed to write embedding cache: %v", err)
+		}
+	}
+
+	return embeddings, nil
+}
+
+// CalculateSimilarity calculates the similarity with the wrapped service
+func (s *CachedEmbeddingService) CalculateSimilarity(vec1, vec2 []float32) float32 {
+	return s.next.CalculateSimilarity(vec1, vec2)
+}
+
+// Stats returns the number of embeddings served from memory, from the store
+// and generated since the service was created
+func (s *CachedEmbeddingService) Stats() CacheStats {
+	return CacheStats{
+		MemoryHits: s.memoryHits.Load(),
+		StoreHits:  s.storeHits.Load(),
+		Misses:     s.misses.Load(),
+	}
+}
+
+// cacheKey hashes the model, the purpose, the title and the text. Texts are
+// trimmed as the embedding services do before embedding them.
+func (s *CachedEmbeddingService) cacheKey(options Options, text string) string {
+	hash := sha256.New()
+	hash.Write([]byte(s.model))
+	hash.Write([]byte{0})
+	hash.Write([]byte(options.Purpose))
+	hash.Write([]byte{0})
+	hash.Write([]byte(options.Title))
+	hash.Write([]byte{0})
+	hash.Write([]byte(strings.TrimSpace(text)))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// get returns the embedding of a key from the LRU, marking it as recently used
+func (s *CachedEmbeddingService) get(key string) ([]float32, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	s.order.MoveToFront(element)
+	return element.Value.(*lruEntry).embedding, true
+}
+
+// put adds an embedding to the LRU, evicting the least recently used ones
+// beyond the capacity
+func (s *CachedEmbeddingService) put(key string, embedding []float32) {
+	if s.capacity <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		element.Value.(*lruEntry).embedding = embedding
+		s.order.MoveToFront(element)
+		return
+	}
+	s.entries[key] = s.order.PushFront(&lruEntry{key: key, embedding: embedding})
+	for s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// mapKeys returns the keys of the missing texts
+func mapKeys(missing map[string][]int) []string {
+	keys := make([]string, 0, len(missing))
+	for key := range missing {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// orderedMissingKeys returns the missing keys in the order of the texts
+func orderedMissingKeys(keys []string, missing map[string][]int) []string {
+	var ordered []string
+	for i, key := range keys {
+		if indexes, ok := missing[key]; ok && indexes[0] == i {
+			ordered = append(ordered, key)
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,183 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// countingEmbeddingService embeds texts as their length and records the
+// texts and options of every batch it is asked for
+type countingEmbeddingService struct {
+	batches [][]string
+	options []Options
+}
+
+func (s *countingEmbeddingService) GenerateEmbedding(ctx context.Context, text string, options Options) ([]float32, error) {
+	embeddings, err := s.BatchGenerateEmbeddings(ctx, []string{text}, options)
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+func (s *countingEmbeddingService) BatchGenerateEmbeddings(ctx context.Context, texts []string, options Options) ([][]float32, error) {
+	s.batches = append(s.batches, texts)
+	s.options = append(s.options, options)
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i] = []float32{float32(len(text))}
+	}
+	return embeddings, nil
+}
+
+func (s *countingEmbeddingService) CalculateSimilarity(vec1, vec2 []float32) float32 {
+	return 1
+}
+
+// memoryEmbeddingStore is an in-memory EmbeddingCacheStore
+type memoryEmbeddingStore struct {
+	embeddings map[string][]float32
+	models     map[string]string
+	err        error
+}
+
+func newMemoryEmbeddingStore() *memoryEmbeddingStore {
+	return &memoryEmbeddingStore{embeddings: make(map[string][]float32), models: make(map[string]string)}
+}
+
+func (s *memoryEmbeddingStore) GetCachedEmbeddings(ctx context.Context, keys []string) (map[string][]float32, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	found := make(map[string][]float32)
+	for _, key := range keys {
+		if embedding, ok := s.embeddings[key]; ok {
+			found[key] = embedding
+		}
+	}
+	return found, nil
+}
+
+func (s *memoryEmbeddingStore) StoreCachedEmbeddings(ctx context.Context, model string, embeddings map[string][]float32) error {
+	if s.err != nil {
+		return s.err
+	}
+	for key, embedding := range embeddings {
+		s.embeddings[key] = embedding
+		s.models[key] = model
+	}
+	return nil
+}
+
+// TestCachedEmbeddingService tests that only texts not embedded before are generated
+func TestCachedEmbeddingService(t *testing.T) {
+	next := &countingEmbeddingService{}
+	service := NewCachedEmbeddingService(next, "model-a", 10, nil)
+	ctx := context.Background()
+	document := Options{Purpose: PurposeDocument, Title: "guide"}
+
+	embeddings, err := service.BatchGenerateEmbeddings(ctx, []string{"a", "bb", "a"}, document)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(embeddings, [][]float32{{1}, {2}, {1}}) {
+		t.Errorf("Unexpected embeddings %v", embeddings)
+	}
+
+	// Surrounding whitespace does not change the embedding
+	if _, err := service.GenerateEmbedding(ctx, " bb\n", document); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := service.BatchGenerateEmbeddings(ctx, []string{"a", "ccc"}, document); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := [][]string{{"a", "bb"}, {"ccc"}}
+	if !reflect.DeepEqual(next.batches, expected) {
+		t.Errorf("Expected generated batches %v, got %v", expected, next.batches)
+	}
+	// Duplicates within a batch are generated once without counting as hits
+	if stats := service.Stats(); stats != (CacheStats{MemoryHits: 2, Misses: 3}) {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	if next.options[0] != document {
+		t.Errorf("Expected the options to be passed on, got %+v", next.options[0])
+	}
+
+	// Queries do not share the embeddings of documents
+	if _, err := service.GenerateEmbedding(ctx, "a", Options{Purpose: PurposeQuery}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(next.batches) != 3 {
+		t.Errorf("Expected the query to be generated, got batches %v", next.batches)
+	}
+
+	// Neither do other models and titles
+	other := NewCachedEmbeddingService(next, "model-b", 10, nil)
+	if service.cacheKey(document, "a") == other.cacheKey(document, "a") ||
+		service.cacheKey(document, "a") == service.cacheKey(Options{Purpose: PurposeDocument}, "a") {
+		t.Errorf("Expected cache keys to depend on the model and the title")
+	}
+}
+
+// TestCachedEmbeddingServiceEviction tests that the least recently used embeddings are evicted
+func TestCachedEmbeddingServiceEviction(t *testing.T) {
+	next := &countingEmbeddingService{}
+	service := NewCachedEmbeddingService(next, "model", 2, nil)
+	ctx := context.Background()
+
+	for _, text := range []string{"a", "bb", "a", "ccc", "a", "bb"} {
+		if _, err := service.GenerateEmbedding(ctx, text, Options{}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	// "bb" was evicted by "ccc" since "a" was used more recently
+	expected := [][]string{{"a"}, {"bb"}, {"ccc"}, {"bb"}}
+	if !reflect.DeepEqual(next.batches, expected) {
+		t.Errorf("Expected generated batches %v, got %v", expected, next.batches)
+	}
+}
+
+// TestCachedEmbeddingServiceStore tests sharing embeddings through the persistent store
+func TestCachedEmbeddingServiceStore(t *testing.T) {
+	store := newMemoryEmbeddingStore()
+	ctx := context.Background()
+
+	first := NewCachedEmbeddingService(&countingEmbeddingService{}, "model", 0, store)
+	if _, err := first.BatchGenerateEmbeddings(ctx, []string{"a", "bb"}, Options{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for key, model := range store.models {
+		if model != "model" {
+			t.Errorf("Expected embedding %s stored for model, got %q", key, model)
+		}
+	}
+
+	// A new process only generates the new chunk
+	next := &countingEmbeddingService{}
+	second := NewCachedEmbeddingService(next, "model", 10, store)
+	embeddings, err := second.BatchGenerateEmbeddings(ctx, []string{"a", "bb", "dddd"}, Options{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(embeddings, [][]float32{{1}, {2}, {4}}) {
+		t.Errorf("Unexpected embeddings %v", embeddings)
+	}
+	if !reflect.DeepEqual(next.batches, [][]string{{"dddd"}}) {
+		t.Errorf("Expected only the new text to be generated, got %v", next.batches)
+	}
+	if stats := second.Stats(); stats != (CacheStats{StoreHits: 2, Misses: 1}) {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	// Store failures fall back to generating the embeddings
+	store.err = errors.New("connection lost")
+	third := NewCachedEmbeddingService(&countingEmbeddingService{}, "model", 10, store)
+	if _, err := third.GenerateEmbedding(ctx, "a", Options{}); err != nil {
+		t.Errorf("Expected store failures to be ignored, got %v", err)
+	}
+}
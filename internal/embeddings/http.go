@@ -0,0 +1,89 @@
+package embeddings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxBatchSize is the number of texts sent in one request to providers that
+// embed batches; it matches the default client batch limit of TEI
+const maxBatchSize = 32
+
+// postJSON sends request as JSON to url and decodes the JSON response into
+// response. apiKey is sent as a bearer token when it is set.
+func postJSON(ctx context.Context, client *http.Client, url, apiKey string, request, response interface{}) error {
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.Unmarshal(body, response); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	return nil
+}
+
+// batchEmbed embeds texts in batches of at most maxBatchSize with embed,
+// rejecting empty texts and trimming the others
+func batchEmbed(ctx context.Context, texts []string, embed func(ctx context.Context, batch []string) ([][]float32, error)) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("texts cannot be empty")
+	}
+
+	trimmed := make([]string, len(texts))
+	for i, text := range texts {
+		if text == "" {
+			return nil, fmt.Errorf("text cannot be empty")
+		}
+		trimmed[i] = strings.TrimSpace(text)
+	}
+
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(trimmed); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(trimmed) {
+			end = len(trimmed)
+		}
+
+		batch, err := embed(ctx, trimmed[start:end])
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) != end-start {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", end-start, len(batch))
+		}
+		embeddings = append(embeddings, batch...)
+	}
+
+	return embeddings, nil
+}
@@ -0,0 +1,114 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// localCharNGramSize is the length of the character n-grams hashed by the local provider
+const localCharNGramSize = 3
+
+// localCharNGramWeight is the weight of character n-grams relative to words
+const localCharNGramWeight = 0.5
+
+// LocalEmbeddingService is an implementation of EmbeddingService that runs
+// fully offline. It hashes the words, word bigrams and character trigrams of a
+// text into a vector of the configured dimensions (the hashing trick), so
+// texts sharing words or word parts are similar. The embeddings are
+// deterministic but far less semantic than those of a model; the provider is
+// meant for development and tests.
+type LocalEmbeddingService struct {
+	dimensions int
+}
+
+// NewLocalEmbeddingService creates a new local embedding service producing
+// vectors of the given dimensions
+func NewLocalEmbeddingService(dimensions int) (*LocalEmbeddingService, error) {
+	if dimensions <= 0 {
+		return nil, fmt.Errorf("embedding dimensions must be positive, got %d", dimensions)
+	}
+	return &LocalEmbeddingService{dimensions: dimensions}, nil
+}
+
+// GenerateEmbedding generates an L2-normalized embedding vector for the given text
+func (s *LocalEmbeddingService) GenerateEmbedding(ctx context.Context, text string, options Options) ([]float32, error) {
+	if text == "" {
+		return nil, fmt.Errorf("text cannot be empty")
+	}
+
+	vector := make([]float64, s.dimensions)
+	words := localWords(text)
+	for i, word := range words {
+		s.addFeature(vector, "w:"+word, 1)
+		if i > 0 {
+			s.addFeature(vector, "b:"+words[i-1]+" "+word, 1)
+		}
+
+		padded := []rune("<" + word + ">")
+		for j := 0; j+localCharNGramSize <= len(padded); j++ {
+			s.addFeature(vector, "c:"+string(padded[j:j+localCharNGramSize]), localCharNGramWeight)
+		}
+	}
+
+	var norm float64
+	for _, value := range vector {
+		norm += value * value
+	}
+	norm = math.Sqrt(norm)
+
+	embedding := make([]float32, s.dimensions)
+	for i, value := range vector {
+		if norm > 0 {
+			embedding[i] = float32(value / norm)
+		}
+	}
+
+	return embedding, nil
+}
+
+// BatchGenerateEmbeddings generates embedding vectors for multiple texts
+func (s *LocalEmbeddingService) BatchGenerateEmbeddings(ctx context.Context, texts []string, options Options) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("texts cannot be empty")
+	}
+
+	embeddings := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		embedding, err := s.GenerateEmbedding(ctx, text, options)
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, embedding)
+	}
+
+	return embeddings, nil
+}
+
+// CalculateSimilarity calculates cosine similarity between two vectors
+func (s *LocalEmbeddingService) CalculateSimilarity(vec1, vec2 []float32) float32 {
+	return cosineSimilarity(vec1, vec2)
+}
+
+// addFeature adds the weight of a feature to the dimension it hashes to. A
+// second hash bit picks the sign, so that collisions cancel out on average.
+func (s *LocalEmbeddingService) addFeature(vector []float64, feature string, weight float64) {
+	hash := fnv.New64a()
+	hash.Write([]byte(feature))
+	sum := hash.Sum64()
+
+	if sum>>63 == 1 {
+		weight = -weight
+	}
+	vector[sum%uint64(len(vector))] += weight
+}
+
+// localWords splits text into lowercase words of letters and digits
+func localWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,78 @@
+package embeddings
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// TestLocalGenerateEmbedding tests the offline feature hashing embeddings
+func TestLocalGenerateEmbedding(t *testing.T) {
+	service, err := NewLocalEmbeddingService(256)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	ctx := context.Background()
+
+	embed := func(text string) []float32 {
+		embedding, err := service.GenerateEmbedding(ctx, text, Options{Purpose: PurposeQuery})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return embedding
+	}
+
+	query := embed("How do I install Go on Linux?")
+	if len(query) != 256 {
+		t.Fatalf("Expected 256 dimensions, got %d", len(query))
+	}
+
+	var norm float64
+	for _, value := range query {
+		norm += float64(value * value)
+	}
+	if math.Abs(norm-1) > 1e-5 {
+		t.Errorf("Expected a unit vector, got norm %f", math.Sqrt(norm))
+	}
+
+	// Embeddings are deterministic and ignore case and punctuation
+	again := embed("how do i install go on linux")
+	if similarity := service.CalculateSimilarity(query, again); similarity < 0.9999 {
+		t.Errorf("Expected identical embeddings, got similarity %f", similarity)
+	}
+
+	// Texts sharing words are closer than unrelated texts
+	related := embed("Installing Go on Linux requires extracting the archive")
+	unrelated := embed("The quarterly budget was approved by the board")
+	if service.CalculateSimilarity(query, related) <= service.CalculateSimilarity(query, unrelated) {
+		t.Errorf("Expected the related text to be more similar than the unrelated text")
+	}
+
+	// Texts without words embed as zero vectors
+	if zero := embed("?!"); service.CalculateSimilarity(zero, zero) != 0 {
+		t.Errorf("Expected a zero vector, got %v", zero)
+	}
+
+	if _, err := service.GenerateEmbedding(ctx, "", Options{}); err == nil {
+		t.Error("Expected an error for empty text")
+	}
+}
+
+// TestLocalBatchGenerateEmbeddings tests batches match single embeddings
+func TestLocalBatchGenerateEmbeddings(t *testing.T) {
+	service, _ := NewLocalEmbeddingService(64)
+	ctx := context.Background()
+
+	embeddings, err := service.BatchGenerateEmbeddings(ctx, []string{"first text", "second text"}, Options{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	single, _ := service.GenerateEmbedding(ctx, "second text", Options{})
+	if len(embeddings) != 2 || service.CalculateSimilarity(embeddings[1], single) < 0.9999 {
+		t.Errorf("Expected batch embeddings to match single embeddings")
+	}
+
+	if _, err := NewLocalEmbeddingService(0); err == nil {
+		t.Error("Expected an error for zero dimensions")
+	}
+}
@@ -0,0 +1,82 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yourusername/go-rag/internal/config"
+)
+
+// OllamaEmbeddingRequest represents a request to the Ollama /api/embed endpoint
+type OllamaEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// OllamaEmbeddingResponse represents a response from the Ollama /api/embed endpoint
+type OllamaEmbeddingResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// OllamaEmbeddingService is an implementation of EmbeddingService using an
+// Ollama server. Ollama has no task types, so the purpose of texts is ignored.
+type OllamaEmbeddingService struct {
+	baseURL    string
+	model      string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewOllamaEmbeddingService creates a new embedding service for the Ollama
+// server at the configured base URL, e.g. http://localhost:11434
+func NewOllamaEmbeddingService(cfg config.EmbeddingProviderConfig) (*OllamaEmbeddingService, error) {
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("Ollama base URL is required")
+	}
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("Ollama embedding model is required")
+	}
+
+	return &OllamaEmbeddingService{
+		baseURL: strings.TrimRight(cfg.BaseURL, "/"),
+		model:   cfg.Model,
+		apiKey:  cfg.APIKey,
+		httpClient: &http.Client{
+			// Ollama loads the model on the first request
+			Timeout: 2 * time.Minute,
+		},
+	}, nil
+}
+
+// GenerateEmbedding generates an embedding vector for the given text
+func (s *OllamaEmbeddingService) GenerateEmbedding(ctx context.Context, text string, options Options) ([]float32, error) {
+	embeddings, err := s.BatchGenerateEmbeddings(ctx, []string{text}, options)
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// BatchGenerateEmbeddings generates embedding vectors for multiple texts, in
+// as few requests as possible
+func (s *OllamaEmbeddingService) BatchGenerateEmbeddings(ctx context.Context, texts []string, options Options) ([][]float32, error) {
+	return batchEmbed(ctx, texts, s.embed)
+}
+
+// CalculateSimilarity calculates cosine similarity between two vectors
+func (s *OllamaEmbeddingService) CalculateSimilarity(vec1, vec2 []float32) float32 {
+	return cosineSimilarity(vec1, vec2)
+}
+
+// embed embeds a batch of texts in a single request
+func (s *OllamaEmbeddingService) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var response OllamaEmbeddingResponse
+	request := OllamaEmbeddingRequest{Model: s.model, Input: texts}
+	if err := postJSON(ctx, s.httpClient, s.baseURL+"/api/embed", s.apiKey, request, &response); err != nil {
+		return nil, err
+	}
+	return response.Embeddings, nil
+}
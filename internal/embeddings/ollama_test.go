@@ -0,0 +1,94 @@
+package embeddings
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yourusername/go-rag/internal/config"
+)
+
+// TestOllamaBatchGenerateEmbeddings tests embedding batches with an Ollama server
+func TestOllamaBatchGenerateEmbeddings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embed" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+
+		var request OllamaEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		if request.Model != "nomic-embed-text" {
+			t.Errorf("Expected model nomic-embed-text, got %s", request.Model)
+		}
+
+		var response OllamaEmbeddingResponse
+		for _, text := range request.Input {
+			response.Embeddings = append(response.Embeddings, []float32{float32(len(text)), 1})
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	service, err := NewOllamaEmbeddingService(config.EmbeddingProviderConfig{BaseURL: server.URL, Model: "nomic-embed-text"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	embeddings, err := service.BatchGenerateEmbeddings(context.Background(), []string{"a", "bb", "ccc"}, Options{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(embeddings) != 3 || embeddings[2][0] != 3 {
+		t.Errorf("Expected embeddings in the order of the texts, got %v", embeddings)
+	}
+
+	embedding, err := service.GenerateEmbedding(context.Background(), "dddd", Options{Purpose: PurposeQuery})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(embedding) != 2 || embedding[0] != 4 {
+		t.Errorf("Expected [4 1], got %v", embedding)
+	}
+
+	if _, err := service.GenerateEmbedding(context.Background(), "", Options{}); err == nil {
+		t.Error("Expected an error for empty text")
+	}
+}
+
+// TestOllamaGenerateEmbeddingErrors tests rejecting failed and incomplete responses
+func TestOllamaGenerateEmbeddingErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{name: "Model not found", status: http.StatusNotFound, body: `{"error":"model not found"}`},
+		{name: "Missing embeddings", status: http.StatusOK, body: `{"embeddings":[]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if auth := r.Header.Get("Authorization"); auth != "Bearer proxy-key" {
+					t.Errorf("Expected bearer token, got %q", auth)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			service, _ := NewOllamaEmbeddingService(config.EmbeddingProviderConfig{
+				BaseURL: server.URL,
+				Model:   "nomic-embed-text",
+				APIKey:  "proxy-key",
+			})
+			if _, err := service.GenerateEmbedding(context.Background(), "text", Options{}); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yourusername/go-rag/internal/config"
+)
+
+// OpenAIEmbeddingRequest represents a request to an OpenAI-compatible /embeddings endpoint
+type OpenAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+	// Dimensions shortens the embeddings of models supporting it
+	Dimensions int `json:"dimensions,omitempty"`
+}
+
+// OpenAIEmbeddingResponse represents a response from an OpenAI-compatible /embeddings endpoint
+type OpenAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// OpenAIEmbeddingService is an implementation of EmbeddingService using an
+// OpenAI-compatible embeddings API, as served by OpenAI, vLLM, LocalAI or TEI.
+// The API has no task types, so the purpose of texts is ignored.
+type OpenAIEmbeddingService struct {
+	baseURL    string
+	model      string
+	apiKey     string
+	dimensions int
+	// sendDimensions requests embeddings of the dimensions from the API
+	sendDimensions bool
+	httpClient     *http.Client
+}
+
+// NewOpenAIEmbeddingService creates a new embedding service for the
+// OpenAI-compatible API at the configured base URL, e.g. https://api.openai.com/v1,
+// returning embeddings of the given dimensions
+func NewOpenAIEmbeddingService(cfg config.EmbeddingProviderConfig, dimensions int) (*OpenAIEmbeddingService, error) {
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("OpenAI embedding base URL is required")
+	}
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("OpenAI embedding model is required")
+	}
+	if dimensions <= 0 {
+		return nil, fmt.Errorf("embedding dimensions must be positive, got %d", dimensions)
+	}
+
+	return &OpenAIEmbeddingService{
+		baseURL:        strings.TrimRight(cfg.BaseURL, "/"),
+		model:          cfg.Model,
+		apiKey:         cfg.APIKey,
+		dimensions:     dimensions,
+		sendDimensions: cfg.SendDimensions,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}, nil
+}
+
+// GenerateEmbedding generates an embedding vector for the given text
+func (s *OpenAIEmbeddingService) GenerateEmbedding(ctx context.Context, text string, options Options) ([]float32, error) {
+	embeddings, err := s.BatchGenerateEmbeddings(ctx, []string{text}, options)
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// BatchGenerateEmbeddings generates embedding vectors for multiple texts, in
+// as few requests as possible
+func (s *OpenAIEmbeddingService) BatchGenerateEmbeddings(ctx context.Context, texts []string, options Options) ([][]float32, error) {
+	return batchEmbed(ctx, texts, s.embed)
+}
+
+// CalculateSimilarity calculates cosine similarity between two vectors
+func (s *OpenAIEmbeddingService) CalculateSimilarity(vec1, vec2 []float32) float32 {
+	return cosineSimilarity(vec1, vec2)
+}
+
+// embed embeds a batch of texts in a single request
+func (s *OpenAIEmbeddingService) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var response OpenAIEmbeddingResponse
+	request := OpenAIEmbeddingRequest{Model: s.model, Input: texts}
+	if s.sendDimensions {
+		request.Dimensions = s.dimensions
+	}
+	if err := postJSON(ctx, s.httpClient, s.baseURL+"/embeddings", s.apiKey, request, &response); err != nil {
+		return nil, err
+	}
+
+	// Embeddings are placed by their index, as the order of data is not guaranteed
+	embeddings := make([][]float32, len(texts))
+	for _, data := range response.Data {
+		if data.Index < 0 || data.Index >= len(texts) || embeddings[data.Index] != nil {
+			return nil, fmt.Errorf("unexpected embedding for text %d", data.Index)
+		}
+		embeddings[data.Index] = data.Embedding
+	}
+	for i, embedding := range embeddings {
+		if embedding == nil {
+			return nil, fmt.Errorf("missing embedding for text %d", i)
+		}
+		if len(embedding) != s.dimensions {
+			return nil, fmt.Errorf("model %s returned %d dimensions, but EMBEDDING_DIMENSIONS is %d", s.model, len(embedding), s.dimensions)
+		}
+	}
+
+	return embeddings, nil
+}
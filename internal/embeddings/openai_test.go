@@ -0,0 +1,125 @@
+package embeddings
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/go-rag/internal/config"
+)
+
+// TestOpenAIBatchGenerateEmbeddings tests embedding batches with an OpenAI-compatible server
+func TestOpenAIBatchGenerateEmbeddings(t *testing.T) {
+	var batches [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer test-key" {
+			t.Errorf("Expected bearer token, got %q", auth)
+		}
+
+		var request OpenAIEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		if request.Model != "test-model" {
+			t.Errorf("Expected model test-model, got %s", request.Model)
+		}
+		if request.Dimensions != 1 {
+			t.Errorf("Expected 1 requested dimension, got %d", request.Dimensions)
+		}
+		batches = append(batches, request.Input)
+
+		// Return the embeddings in reverse order, identified by their index
+		var response OpenAIEmbeddingResponse
+		for i := len(request.Input) - 1; i >= 0; i-- {
+			response.Data = append(response.Data, struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			}{Index: i, Embedding: []float32{float32(len(request.Input[i]))}})
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	service, err := NewOpenAIEmbeddingService(config.EmbeddingProviderConfig{
+		BaseURL:        server.URL + "/v1/",
+		Model:          "test-model",
+		APIKey:         "test-key",
+		SendDimensions: true,
+	}, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	texts := make([]string, maxBatchSize+2)
+	for i := range texts {
+		texts[i] = strings.Repeat("a", i+1) + " "
+	}
+
+	embeddings, err := service.BatchGenerateEmbeddings(context.Background(), texts, Options{Purpose: PurposeDocument})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(batches) != 2 || len(batches[0]) != maxBatchSize || len(batches[1]) != 2 {
+		t.Fatalf("Expected batches of %d and 2 texts, got %d batches", maxBatchSize, len(batches))
+	}
+	if batches[0][0] != "a" {
+		t.Errorf("Expected trimmed text, got %q", batches[0][0])
+	}
+	for i, embedding := range embeddings {
+		if len(embedding) != 1 || embedding[0] != float32(i+1) {
+			t.Errorf("Expected embedding %d to be [%d], got %v", i, i+1, embedding)
+		}
+	}
+}
+
+// TestOpenAIGenerateEmbeddingErrors tests rejecting failed and incomplete responses
+func TestOpenAIGenerateEmbeddingErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{name: "API error", status: http.StatusUnauthorized, body: `{"error":{"message":"invalid key"}}`},
+		{name: "Missing embedding", status: http.StatusOK, body: `{"data":[]}`},
+		{name: "Unknown index", status: http.StatusOK, body: `{"data":[{"index":3,"embedding":[1]}]}`},
+		{name: "Wrong dimensions", status: http.StatusOK, body: `{"data":[{"index":0,"embedding":[1,2]}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "" {
+					t.Errorf("Expected no authorization without an API key")
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			service, _ := NewOpenAIEmbeddingService(config.EmbeddingProviderConfig{BaseURL: server.URL, Model: "test-model"}, 1)
+			if _, err := service.GenerateEmbedding(context.Background(), "text", Options{}); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+// TestNewOpenAIEmbeddingService tests validating the provider configuration
+func TestNewOpenAIEmbeddingService(t *testing.T) {
+	if _, err := NewOpenAIEmbeddingService(config.EmbeddingProviderConfig{Model: "test-model"}, 768); err == nil {
+		t.Error("Expected an error without a base URL")
+	}
+	if _, err := NewOpenAIEmbeddingService(config.EmbeddingProviderConfig{BaseURL: "http://localhost"}, 768); err == nil {
+		t.Error("Expected an error without a model")
+	}
+	if _, err := NewOpenAIEmbeddingService(config.EmbeddingProviderConfig{BaseURL: "http://localhost", Model: "test-model"}, 0); err == nil {
+		t.Error("Expected an error without dimensions")
+	}
+}
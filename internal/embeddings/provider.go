@@ -0,0 +1,31 @@
+package embeddings
+
+import (
+	"fmt"
+
+	"github.com/yourusername/go-rag/internal/config"
+)
+
+// Embedding providers selected by configuration
+const (
+	ProviderGemini = "gemini"
+	ProviderOpenAI = "openai"
+	ProviderOllama = "ollama"
+	ProviderLocal  = "local"
+)
+
+// NewEmbeddingService creates the embedding service of the configured provider
+func NewEmbeddingService(cfg *config.Config) (EmbeddingService, error) {
+	switch cfg.Embeddings.Provider {
+	case "", ProviderGemini:
+		return NewGeminiEmbeddingService(&cfg.Gemini)
+	case ProviderOpenAI:
+		return NewOpenAIEmbeddingService(cfg.Embeddings.OpenAI, cfg.Embeddings.Dimensions)
+	case ProviderOllama:
+		return NewOllamaEmbeddingService(cfg.Embeddings.Ollama)
+	case ProviderLocal:
+		return NewLocalEmbeddingService(cfg.Embeddings.Dimensions)
+	default:
+		return nil, fmt.Errorf("unknown embedding provider %q", cfg.Embeddings.Provider)
+	}
+}
@@ -0,0 +1,51 @@
+package embeddings
+
+import (
+	"testing"
+
+	"github.com/yourusername/go-rag/internal/config"
+)
+
+// TestNewEmbeddingService tests selecting the embedding provider by configuration
+func TestNewEmbeddingService(t *testing.T) {
+	cfg := &config.Config{
+		Gemini: config.GeminiConfig{APIKey: "test-key", EmbeddingModel: "embedding-001"},
+		Embeddings: config.EmbeddingsConfig{
+			Dimensions: 768,
+			OpenAI:     config.EmbeddingProviderConfig{BaseURL: "http://localhost:8000/v1", Model: "bge-small"},
+			Ollama:     config.EmbeddingProviderConfig{BaseURL: "http://localhost:11434", Model: "nomic-embed-text"},
+		},
+	}
+
+	tests := []struct {
+		provider string
+		model    string
+		check    func(service EmbeddingService) bool
+	}{
+		{provider: "", model: "embedding-001", check: func(s EmbeddingService) bool { _, ok := s.(*GeminiEmbeddingService); return ok }},
+		{provider: ProviderGemini, model: "embedding-001", check: func(s EmbeddingService) bool { _, ok := s.(*GeminiEmbeddingService); return ok }},
+		{provider: ProviderOpenAI, model: "bge-small", check: func(s EmbeddingService) bool { _, ok := s.(*OpenAIEmbeddingService); return ok }},
+		{provider: ProviderOllama, model: "nomic-embed-text", check: func(s EmbeddingService) bool { _, ok := s.(*OllamaEmbeddingService); return ok }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			cfg.Embeddings.Provider = tt.provider
+			service, err := NewEmbeddingService(cfg)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !tt.check(service) {
+				t.Errorf("Unexpected service %T for provider %q", service, tt.provider)
+			}
+			if model := cfg.EmbeddingModel().Name; model != tt.model {
+				t.Errorf("Expected model %s, got %s", tt.model, model)
+			}
+		})
+	}
+
+	cfg.Embeddings.Provider = "word2vec"
+	if _, err := NewEmbeddingService(cfg); err == nil {
+		t.Error("Expected an error for an unknown provider")
+	}
+}
@@ -14,6 +14,9 @@ import (
 	"github.com/yourusername/go-rag/internal/config"
 )
 
+// geminiAPIBaseURL is the base URL of the Gemini API
+const geminiAPIBaseURL = "https://generativelanguage.googleapis.com/v1"
+
 // GeminiEmbeddingRequest represents a request to the Gemini Embedding API
 type GeminiEmbeddingRequest struct {
 	Content struct {
@@ -21,6 +24,8 @@ type GeminiEmbeddingRequest struct {
 			Text string `json:"text"`
 		} `json:"parts"`
 	} `json:"content"`
+	TaskType string `json:"taskType,omitempty"`
+	Title    string `json:"title,omitempty"`
 }
 
 // GeminiEmbeddingResponse represents a response from the Gemini Embedding API
@@ -30,10 +35,52 @@ type GeminiEmbeddingResponse struct {
 	} `json:"embedding"`
 }
 
+// GeminiModelEmbeddingRequest is a request of a batch, naming the model it is for
+type GeminiModelEmbeddingRequest struct {
+	Model string `json:"model"`
+	GeminiEmbeddingRequest
+}
+
+// GeminiBatchEmbeddingRequest represents a request to the Gemini batch embedding API
+type GeminiBatchEmbeddingRequest struct {
+	Requests []GeminiModelEmbeddingRequest `json:"requests"`
+}
+
+// GeminiBatchEmbeddingResponse represents a response from the Gemini batch
+// embedding API, with the embeddings in the order of the requests
+type GeminiBatchEmbeddingResponse struct {
+	Embeddings []struct {
+		Values []float32 `json:"values"`
+	} `json:"embeddings"`
+}
+
+// Purpose tells the embedding model what an embedding is used for, so that
+// it can optimize the embedding for the task. The values are Gemini task types.
+type Purpose string
+
+const (
+	// PurposeUnspecified leaves the task to the model default
+	PurposeUnspecified Purpose = ""
+	// PurposeDocument embeds text that is stored and retrieved
+	PurposeDocument Purpose = "RETRIEVAL_DOCUMENT"
+	// PurposeQuery embeds search queries run against stored documents
+	PurposeQuery Purpose = "RETRIEVAL_QUERY"
+	// PurposeSimilarity embeds texts that are compared with each other
+	PurposeSimilarity Purpose = "SEMANTIC_SIMILARITY"
+)
+
+// Options describe the texts to embed
+type Options struct {
+	Purpose Purpose
+	// Title is the title of the document the texts belong to; it is only
+	// used for documents
+	Title string
+}
+
 // EmbeddingService provides functionality for generating and working with embeddings
 type EmbeddingService interface {
-	GenerateEmbedding(ctx context.Context, text string) ([]float32, error)
-	BatchGenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error)
+	GenerateEmbedding(ctx context.Context, text string, options Options) ([]float32, error)
+	BatchGenerateEmbeddings(ctx context.Context, texts []string, options Options) ([][]float32, error)
 	CalculateSimilarity(vec1, vec2 []float32) float32
 }
 
@@ -42,6 +89,7 @@ type GeminiEmbeddingService struct {
 	apiKey         string
 	embeddingModel string
 	httpClient     *http.Client
+	baseURL        string
 }
 
 // NewGeminiEmbeddingService creates a new embedding service using Google's Gemini API
@@ -56,11 +104,12 @@ func NewGeminiEmbeddingService(cfg *config.GeminiConfig) (EmbeddingService, erro
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		baseURL: geminiAPIBaseURL,
 	}, nil
 }
 
 // GenerateEmbedding generates an embedding vector for the given text
-func (s *GeminiEmbeddingService) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+func (s *GeminiEmbeddingService) GenerateEmbedding(ctx context.Context, text string, options Options) ([]float32, error) {
 	if text == "" {
 		return nil, fmt.Errorf("text cannot be empty")
 	}
@@ -69,12 +118,7 @@ func (s *GeminiEmbeddingService) GenerateEmbedding(ctx context.Context, text str
 	text = strings.TrimSpace(text)
 
 	// Create request body
-	reqBody := GeminiEmbeddingRequest{}
-	reqBody.Content.Parts = []struct {
-		Text string `json:"text"`
-	}{
-		{Text: text},
-	}
+	reqBody := newGeminiEmbeddingRequest(text, options)
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
@@ -82,8 +126,7 @@ func (s *GeminiEmbeddingService) GenerateEmbedding(ctx context.Context, text str
 	}
 
 	// Create HTTP request
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1/models/%s:embedContent?key=%s",
-		s.embeddingModel, s.apiKey)
+	url := fmt.Sprintf("%s/models/%s:embedContent?key=%s", s.baseURL, s.embeddingModel, s.apiKey)
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
@@ -119,28 +162,60 @@ func (s *GeminiEmbeddingService) GenerateEmbedding(ctx context.Context, text str
 	return embResponse.Embedding.Values, nil
 }
 
-// BatchGenerateEmbeddings generates embedding vectors for multiple texts
-func (s *GeminiEmbeddingService) BatchGenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
-	if len(texts) == 0 {
-		return nil, fmt.Errorf("texts cannot be empty")
-	}
+// BatchGenerateEmbeddings generates embedding vectors for multiple texts with
+// the batch endpoint, in as few requests as possible
+func (s *GeminiEmbeddingService) BatchGenerateEmbeddings(ctx context.Context, texts []string, options Options) ([][]float32, error) {
+	return batchEmbed(ctx, texts, func(ctx context.Context, batch []string) ([][]float32, error) {
+		return s.embedBatch(ctx, batch, options)
+	})
+}
 
-	// Process each text sequentially
-	// This could be optimized with concurrent requests in a production system
-	var embeddings [][]float32
+// embedBatch embeds a batch of texts in a single request
+func (s *GeminiEmbeddingService) embedBatch(ctx context.Context, texts []string, options Options) ([][]float32, error) {
+	var request GeminiBatchEmbeddingRequest
 	for _, text := range texts {
-		embedding, err := s.GenerateEmbedding(ctx, text)
-		if err != nil {
-			return nil, err
-		}
-		embeddings = append(embeddings, embedding)
+		request.Requests = append(request.Requests, GeminiModelEmbeddingRequest{
+			Model:                  "models/" + s.embeddingModel,
+			GeminiEmbeddingRequest: newGeminiEmbeddingRequest(text, options),
+		})
+	}
+
+	// The API key is passed in the URL as for single embeddings
+	var response GeminiBatchEmbeddingResponse
+	url := fmt.Sprintf("%s/models/%s:batchEmbedContents?key=%s", s.baseURL, s.embeddingModel, s.apiKey)
+	if err := postJSON(ctx, s.httpClient, url, "", request, &response); err != nil {
+		return nil, err
 	}
 
+	embeddings := make([][]float32, 0, len(response.Embeddings))
+	for _, embedding := range response.Embeddings {
+		embeddings = append(embeddings, embedding.Values)
+	}
 	return embeddings, nil
 }
 
+// newGeminiEmbeddingRequest creates the request embedding a text for the purpose of options
+func newGeminiEmbeddingRequest(text string, options Options) GeminiEmbeddingRequest {
+	request := GeminiEmbeddingRequest{}
+	request.Content.Parts = []struct {
+		Text string `json:"text"`
+	}{
+		{Text: text},
+	}
+	request.TaskType = string(options.Purpose)
+	if options.Purpose == PurposeDocument {
+		request.Title = options.Title
+	}
+	return request
+}
+
 // CalculateSimilarity calculates cosine similarity between two vectors
 func (s *GeminiEmbeddingService) CalculateSimilarity(vec1, vec2 []float32) float32 {
+	return cosineSimilarity(vec1, vec2)
+}
+
+// cosineSimilarity calculates cosine similarity between two vectors
+func cosineSimilarity(vec1, vec2 []float32) float32 {
 	if len(vec1) != len(vec2) {
 		return 0
 	}
@@ -2,7 +2,10 @@ package embeddings
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/yourusername/go-rag/internal/config"
@@ -127,9 +130,113 @@ func TestGenerateEmbeddingEmptyText(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	_, err := service.GenerateEmbedding(ctx, "")
+	_, err := service.GenerateEmbedding(ctx, "", Options{})
 
 	if err == nil {
 		t.Errorf("Expected error for empty text, got nil")
 	}
 }
+
+// TestGenerateEmbeddingTaskType tests sending the purpose and title to Gemini
+func TestGenerateEmbeddingTaskType(t *testing.T) {
+	var requests []GeminiEmbeddingRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/test-model:embedContent" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		var request GeminiEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		requests = append(requests, request)
+		w.Write([]byte(`{"embedding":{"values":[0.5,0.5]}}`))
+	}))
+	defer server.Close()
+
+	service := &GeminiEmbeddingService{
+		apiKey:         "test-key",
+		embeddingModel: "test-model",
+		httpClient:     server.Client(),
+		baseURL:        server.URL,
+	}
+	ctx := context.Background()
+
+	embedding, err := service.GenerateEmbedding(ctx, "chunk", Options{Purpose: PurposeDocument, Title: "Guide"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(embedding) != 2 {
+		t.Errorf("Expected 2 dimensions, got %v", embedding)
+	}
+
+	// Titles are only sent for documents
+	if _, err := service.GenerateEmbedding(ctx, "question?", Options{Purpose: PurposeQuery, Title: "Guide"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := service.GenerateEmbedding(ctx, "text", Options{}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []struct{ taskType, title string }{
+		{"RETRIEVAL_DOCUMENT", "Guide"},
+		{"RETRIEVAL_QUERY", ""},
+		{"", ""},
+	}
+	for i, e := range expected {
+		if requests[i].TaskType != e.taskType || requests[i].Title != e.title {
+			t.Errorf("Request %d: expected task type %q and title %q, got %q and %q",
+				i, e.taskType, e.title, requests[i].TaskType, requests[i].Title)
+		}
+	}
+}
+
+// TestBatchGenerateEmbeddingsGemini tests embedding texts with the batch endpoint
+func TestBatchGenerateEmbeddingsGemini(t *testing.T) {
+	var requests []GeminiBatchEmbeddingRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/test-model:batchEmbedContents" || r.URL.Query().Get("key") != "test-key" {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+		var request GeminiBatchEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		requests = append(requests, request)
+
+		var response GeminiBatchEmbeddingResponse
+		for i := range request.Requests {
+			response.Embeddings = append(response.Embeddings, struct {
+				Values []float32 `json:"values"`
+			}{Values: []float32{float32(i), 1}})
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	service := &GeminiEmbeddingService{
+		apiKey:         "test-key",
+		embeddingModel: "test-model",
+		httpClient:     server.Client(),
+		baseURL:        server.URL,
+	}
+
+	texts := make([]string, maxBatchSize+3)
+	for i := range texts {
+		texts[i] = fmt.Sprintf("text %d", i)
+	}
+	embeddings, err := service.BatchGenerateEmbeddings(context.Background(), texts, Options{Purpose: PurposeSimilarity})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(requests) != 2 || len(requests[0].Requests) != maxBatchSize || len(requests[1].Requests) != 3 {
+		t.Fatalf("Expected batches of %d and 3 texts, got %d requests", maxBatchSize, len(requests))
+	}
+	first := requests[0].Requests[0]
+	if first.Model != "models/test-model" || first.TaskType != "SEMANTIC_SIMILARITY" || first.Content.Parts[0].Text != "text 0" {
+		t.Errorf("Unexpected request %+v", first)
+	}
+	if len(embeddings) != len(texts) || embeddings[maxBatchSize+1][0] != 1 {
+		t.Errorf("Expected embeddings in the order of the texts, got %v", embeddings)
+	}
+}
@@ -0,0 +1,108 @@
+package eval
+
+/*
+This package evaluates retrieval and answer quality offline.
+
+Key responsibilities:
+- Load datasets of questions with expected sources and answers
+- Run the questions through the RAG service and compute ranking metrics
+- Optionally judge answers with a language model
+- Compare a run with a previous one to gate configuration changes
+*/
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// Case is a single question of an evaluation dataset
+type Case struct {
+	ID       string `json:"id"`
+	Question string `json:"question"`
+	// ExpectedSources are file names or path suffixes of the sources that
+	// answer the question
+	ExpectedSources []string `json:"expected_sources,omitempty"`
+	// ExpectedDocuments are IDs of the chunks that answer the question
+	ExpectedDocuments []uuid.UUID `json:"expected_documents,omitempty"`
+	// ExpectedAnswer is a reference answer reported next to the generated one
+	ExpectedAnswer string `json:"expected_answer,omitempty"`
+}
+
+// expectedCount returns the number of distinct expected items of the case
+func (c Case) expectedCount() int {
+	return len(c.ExpectedSources) + len(c.ExpectedDocuments)
+}
+
+// match returns the key of the expected item the document matches, or false
+// when it matches none. Sources match the file name or the end of the file path.
+func (c Case) match(doc models.Document) (string, bool) {
+	for _, id := range c.ExpectedDocuments {
+		if doc.ID == id {
+			return "document:" + id.String(), true
+		}
+	}
+
+	fileName, _ := doc.Metadata["file_name"].(string)
+	filePath, _ := doc.Metadata["file_path"].(string)
+	for _, source := range c.ExpectedSources {
+		if source == fileName || (filePath != "" && strings.HasSuffix(filepath.ToSlash(filePath), source)) {
+			return "source:" + source, true
+		}
+	}
+
+	return "", false
+}
+
+// LoadDataset reads an evaluation dataset with one JSON case per line. Blank
+// lines are skipped and cases without an ID are numbered by line.
+func LoadDataset(path string) ([]Case, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset: %w", err)
+	}
+	defer file.Close()
+
+	var cases []Case
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var c Case
+		if err := json.Unmarshal([]byte(text), &c); err != nil {
+			return nil, fmt.Errorf("failed to parse dataset line %d: %w", line, err)
+		}
+		if c.Question == "" {
+			return nil, fmt.Errorf("dataset line %d has no question", line)
+		}
+		if c.expectedCount() == 0 {
+			return nil, fmt.Errorf("dataset line %d has no expected sources or documents", line)
+		}
+		if c.ID == "" {
+			c.ID = fmt.Sprintf("line-%d", line)
+		}
+		cases = append(cases, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dataset: %w", err)
+	}
+
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("dataset is empty")
+	}
+
+	return cases, nil
+}
@@ -0,0 +1,61 @@
+package eval
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeDataset writes dataset content to a temporary file
+func writeDataset(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dataset.jsonl")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write dataset: %v", err)
+	}
+	return path
+}
+
+// TestLoadDataset tests reading a JSONL dataset
+func TestLoadDataset(t *testing.T) {
+	path := writeDataset(t, `{"id":"install","question":"How do I install Go?","expected_sources":["install.md"],"expected_answer":"Extract the tarball."}
+
+{"question":"What is a goroutine?","expected_documents":["4f0c5b7e-4d7a-4d0e-9a51-2b1d9f4f6c11"]}
+`)
+
+	cases, err := LoadDataset(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(cases) != 2 {
+		t.Fatalf("Expected 2 cases, got %d", len(cases))
+	}
+	if cases[0].ID != "install" || cases[0].ExpectedAnswer != "Extract the tarball." {
+		t.Errorf("Unexpected first case %+v", cases[0])
+	}
+	if cases[1].ID != "line-3" || len(cases[1].ExpectedDocuments) != 1 {
+		t.Errorf("Expected the second case to be numbered by line, got %+v", cases[1])
+	}
+}
+
+// TestLoadDatasetInvalid tests that invalid datasets are rejected
+func TestLoadDatasetInvalid(t *testing.T) {
+	tests := map[string]string{
+		"Malformed JSON": `{"question":`,
+		"No question":    `{"expected_sources":["a.md"]}`,
+		"No expectation": `{"question":"Why?"}`,
+		"Empty dataset":  "\n\n",
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadDataset(writeDataset(t, content)); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+
+	if _, err := LoadDataset(filepath.Join(t.TempDir(), "missing.jsonl")); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
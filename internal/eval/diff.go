@@ -0,0 +1,150 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+)
+
+// MetricDelta compares a summary metric of two runs
+type MetricDelta struct {
+	Name     string  `json:"name"`
+	Baseline float64 `json:"baseline"`
+	Current  float64 `json:"current"`
+	Delta    float64 `json:"delta"`
+	// HigherIsBetter is false for latencies
+	HigherIsBetter bool `json:"higher_is_better"`
+}
+
+// Regressed reports whether the metric got worse by more than tolerance.
+// Latencies are compared relative to the baseline.
+func (m MetricDelta) Regressed(tolerance float64) bool {
+	if m.HigherIsBetter {
+		return m.Delta < -tolerance
+	}
+	if m.Baseline == 0 {
+		return false
+	}
+	return m.Delta/m.Baseline > tolerance
+}
+
+// CaseDelta reports a case whose ranking metrics changed between two runs
+type CaseDelta struct {
+	ID         string  `json:"id"`
+	Question   string  `json:"question"`
+	RecallFrom float64 `json:"recall_from"`
+	RecallTo   float64 `json:"recall_to"`
+	NDCGFrom   float64 `json:"ndcg_from"`
+	NDCGTo     float64 `json:"ndcg_to"`
+}
+
+// Diff compares a run with a previous one
+type Diff struct {
+	Metrics []MetricDelta `json:"metrics"`
+	// Regressions and Improvements list the cases present in both runs whose
+	// nDCG got worse or better
+	Regressions  []CaseDelta `json:"regressions"`
+	Improvements []CaseDelta `json:"improvements"`
+}
+
+// Compare computes the differences between a baseline run and the current run
+func Compare(baseline, current *Report) *Diff {
+	diff := &Diff{}
+
+	add := func(name string, from, to float64, higherIsBetter bool) {
+		diff.Metrics = append(diff.Metrics, MetricDelta{
+			Name:           name,
+			Baseline:       from,
+			Current:        to,
+			Delta:          to - from,
+			HigherIsBetter: higherIsBetter,
+		})
+	}
+	add("recall@k", baseline.Summary.RecallAtK, current.Summary.RecallAtK, true)
+	add("mrr", baseline.Summary.MRR, current.Summary.MRR, true)
+	add("ndcg", baseline.Summary.NDCG, current.Summary.NDCG, true)
+	if baseline.Summary.Faithfulness != nil && current.Summary.Faithfulness != nil {
+		add("faithfulness", *baseline.Summary.Faithfulness, *current.Summary.Faithfulness, true)
+		add("answer_relevance", *baseline.Summary.AnswerRelevance, *current.Summary.AnswerRelevance, true)
+	}
+	add("retrieval_p95_ms", baseline.Summary.RetrievalLatency.P95, current.Summary.RetrievalLatency.P95, false)
+	if baseline.Summary.AnswerLatency != nil && current.Summary.AnswerLatency != nil {
+		add("answer_p95_ms", baseline.Summary.AnswerLatency.P95, current.Summary.AnswerLatency.P95, false)
+	}
+
+	previous := make(map[string]CaseResult, len(baseline.Cases))
+	for _, c := range baseline.Cases {
+		previous[c.ID] = c
+	}
+	for _, c := range current.Cases {
+		before, ok := previous[c.ID]
+		if !ok || before.NDCG == c.NDCG {
+			continue
+		}
+		delta := CaseDelta{
+			ID:         c.ID,
+			Question:   c.Question,
+			RecallFrom: before.Recall,
+			RecallTo:   c.Recall,
+			NDCGFrom:   before.NDCG,
+			NDCGTo:     c.NDCG,
+		}
+		if c.NDCG < before.NDCG {
+			diff.Regressions = append(diff.Regressions, delta)
+		} else {
+			diff.Improvements = append(diff.Improvements, delta)
+		}
+	}
+
+	return diff
+}
+
+// Regressed returns the quality metrics that dropped by more than tolerance
+// and the latencies that grew by more than latencyTolerance, relative to the
+// baseline. A negative latencyTolerance ignores latencies.
+func (d *Diff) Regressed(tolerance, latencyTolerance float64) []MetricDelta {
+	var regressed []MetricDelta
+	for _, m := range d.Metrics {
+		if m.HigherIsBetter && m.Regressed(tolerance) {
+			regressed = append(regressed, m)
+		}
+		if !m.HigherIsBetter && latencyTolerance >= 0 && m.Regressed(latencyTolerance) {
+			regressed = append(regressed, m)
+		}
+	}
+	return regressed
+}
+
+// WriteText writes a human-readable summary of the report
+func (r *Report) WriteText(w io.Writer) {
+	s := r.Summary
+	fmt.Fprintf(w, "Cases: %d (failed: %d), k=%d\n", s.Cases, s.Failed, r.Options.K)
+	fmt.Fprintf(w, "Recall@k: %.4f\n", s.RecallAtK)
+	fmt.Fprintf(w, "MRR:      %.4f\n", s.MRR)
+	fmt.Fprintf(w, "nDCG@k:   %.4f\n", s.NDCG)
+	if s.Faithfulness != nil {
+		fmt.Fprintf(w, "Faithfulness:     %.4f\n", *s.Faithfulness)
+		fmt.Fprintf(w, "Answer relevance: %.4f\n", *s.AnswerRelevance)
+	}
+	fmt.Fprintf(w, "Retrieval latency: mean %.1fms, p50 %.1fms, p95 %.1fms, max %.1fms\n",
+		s.RetrievalLatency.Mean, s.RetrievalLatency.P50, s.RetrievalLatency.P95, s.RetrievalLatency.Max)
+	if s.AnswerLatency != nil {
+		fmt.Fprintf(w, "Answer latency:    mean %.1fms, p50 %.1fms, p95 %.1fms, max %.1fms\n",
+			s.AnswerLatency.Mean, s.AnswerLatency.P50, s.AnswerLatency.P95, s.AnswerLatency.Max)
+	}
+}
+
+// WriteText writes a human-readable comparison with the baseline
+func (d *Diff) WriteText(w io.Writer) {
+	fmt.Fprintln(w, "Compared with baseline:")
+	for _, m := range d.Metrics {
+		fmt.Fprintf(w, "  %-18s %10.4f -> %10.4f (%+.4f)\n", m.Name, m.Baseline, m.Current, m.Delta)
+	}
+	for _, c := range d.Regressions {
+		fmt.Fprintf(w, "  regressed %s: ndcg %.4f -> %.4f, recall %.4f -> %.4f (%s)\n",
+			c.ID, c.NDCGFrom, c.NDCGTo, c.RecallFrom, c.RecallTo, c.Question)
+	}
+	for _, c := range d.Improvements {
+		fmt.Fprintf(w, "  improved  %s: ndcg %.4f -> %.4f, recall %.4f -> %.4f (%s)\n",
+			c.ID, c.NDCGFrom, c.NDCGTo, c.RecallFrom, c.RecallTo, c.Question)
+	}
+}
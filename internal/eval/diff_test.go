@@ -0,0 +1,58 @@
+package eval
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestCompare tests comparing a run with a baseline
+func TestCompare(t *testing.T) {
+	baseline := &Report{
+		Summary: Summary{RecallAtK: 0.8, MRR: 0.7, NDCG: 0.75, RetrievalLatency: LatencyStats{P95: 100}},
+		Cases: []CaseResult{
+			{ID: "1", RankingMetrics: RankingMetrics{Recall: 1, NDCG: 1}},
+			{ID: "2", RankingMetrics: RankingMetrics{Recall: 0, NDCG: 0}},
+			{ID: "3", RankingMetrics: RankingMetrics{Recall: 1, NDCG: 0.5}},
+		},
+	}
+	current := &Report{
+		Summary: Summary{RecallAtK: 0.75, MRR: 0.72, NDCG: 0.75, RetrievalLatency: LatencyStats{P95: 150}},
+		Cases: []CaseResult{
+			{ID: "1", RankingMetrics: RankingMetrics{Recall: 0, NDCG: 0}},
+			{ID: "2", RankingMetrics: RankingMetrics{Recall: 1, NDCG: 0.6}},
+			{ID: "3", RankingMetrics: RankingMetrics{Recall: 1, NDCG: 0.5}},
+			{ID: "4", RankingMetrics: RankingMetrics{Recall: 1, NDCG: 1}},
+		},
+	}
+
+	diff := Compare(baseline, current)
+
+	if len(diff.Regressions) != 1 || diff.Regressions[0].ID != "1" {
+		t.Errorf("Expected case 1 to regress, got %+v", diff.Regressions)
+	}
+	if len(diff.Improvements) != 1 || diff.Improvements[0].ID != "2" {
+		t.Errorf("Expected case 2 to improve, got %+v", diff.Improvements)
+	}
+
+	// Recall dropped by 0.05
+	regressed := diff.Regressed(0.01, -1)
+	if len(regressed) != 1 || regressed[0].Name != "recall@k" {
+		t.Errorf("Expected recall to regress, got %+v", regressed)
+	}
+	if regressed := diff.Regressed(0.1, -1); len(regressed) != 0 {
+		t.Errorf("Expected no regression within tolerance, got %+v", regressed)
+	}
+
+	// The p95 latency grew by 50%
+	regressed = diff.Regressed(0.1, 0.2)
+	if len(regressed) != 1 || regressed[0].Name != "retrieval_p95_ms" {
+		t.Errorf("Expected latency to regress, got %+v", regressed)
+	}
+
+	var out bytes.Buffer
+	diff.WriteText(&out)
+	if !strings.Contains(out.String(), "recall@k") || !strings.Contains(out.String(), "regressed 1") {
+		t.Errorf("Unexpected text output %q", out.String())
+	}
+}
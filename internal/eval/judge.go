@@ -0,0 +1,99 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/yourusername/go-rag/internal/models"
+	"github.com/yourusername/go-rag/internal/service"
+)
+
+// judgePrompt asks the model to grade an answer against its context
+const judgePrompt = `You are grading the answer of a question answering system.
+
+Faithfulness: is every statement of the answer supported by the context? 0 means unsupported, 10 means fully supported.
+Answer relevance: does the answer address the question? 0 means unrelated, 10 means it fully answers the question.
+
+Return only a JSON object like {"faithfulness": 7, "answer_relevance": 9}.
+
+Question: %s
+
+Context:
+%s
+Answer: %s`
+
+// JudgeScores are the LLM-judged scores of an answer between 0 and 1
+type JudgeScores struct {
+	Faithfulness    float64 `json:"faithfulness"`
+	AnswerRelevance float64 `json:"answer_relevance"`
+}
+
+// Judge grades generated answers
+type Judge interface {
+	Judge(ctx context.Context, question, answer string, documents []models.Document) (JudgeScores, error)
+}
+
+// LLMJudge grades answers with a language model
+type LLMJudge struct {
+	generator service.Generator
+}
+
+// NewLLMJudge creates a new judge using the generator
+func NewLLMJudge(generator service.Generator) *LLMJudge {
+	return &LLMJudge{generator: generator}
+}
+
+// Judge grades the faithfulness of the answer to the documents and its relevance to the question
+func (j *LLMJudge) Judge(ctx context.Context, question, answer string, documents []models.Document) (JudgeScores, error) {
+	var sources strings.Builder
+	for i, doc := range documents {
+		sources.WriteString(fmt.Sprintf("[%d] %s\n\n", i+1, doc.Content))
+	}
+
+	temperature := 0.0
+	options := models.GenerationOptions{Temperature: &temperature}
+	graded, err := j.generator.Generate(ctx, nil, fmt.Sprintf(judgePrompt, question, sources.String(), answer), options)
+	if err != nil {
+		return JudgeScores{}, fmt.Errorf("failed to grade answer: %w", err)
+	}
+
+	return parseJudgeScores(graded)
+}
+
+// parseJudgeScores extracts the 0-10 grades of a judge response and scales them to [0, 1]
+func parseJudgeScores(text string) (JudgeScores, error) {
+	// Models often wrap JSON in a code fence
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return JudgeScores{}, fmt.Errorf("no grades in judge response %q", text)
+	}
+
+	var grades struct {
+		Faithfulness    *float64 `json:"faithfulness"`
+		AnswerRelevance *float64 `json:"answer_relevance"`
+	}
+	if err := json.Unmarshal([]byte(text[start:end+1]), &grades); err != nil {
+		return JudgeScores{}, fmt.Errorf("failed to parse judge response: %w", err)
+	}
+	if grades.Faithfulness == nil || grades.AnswerRelevance == nil {
+		return JudgeScores{}, fmt.Errorf("incomplete grades in judge response %q", text)
+	}
+
+	return JudgeScores{
+		Faithfulness:    clampGrade(*grades.Faithfulness) / 10,
+		AnswerRelevance: clampGrade(*grades.AnswerRelevance) / 10,
+	}, nil
+}
+
+// clampGrade limits a grade to the 0-10 scale
+func clampGrade(grade float64) float64 {
+	if grade < 0 {
+		return 0
+	}
+	if grade > 10 {
+		return 10
+	}
+	return grade
+}
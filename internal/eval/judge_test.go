@@ -0,0 +1,48 @@
+package eval
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// MockGenerator is a mock implementation of the service.Generator interface
+type MockGenerator struct {
+	GenerateFunc func(ctx context.Context, history []models.ChatMessage, prompt string, options models.GenerationOptions) (string, error)
+}
+
+func (m *MockGenerator) Generate(ctx context.Context, history []models.ChatMessage, prompt string, options models.GenerationOptions) (string, error) {
+	return m.GenerateFunc(ctx, history, prompt, options)
+}
+
+// TestLLMJudge tests grading an answer with the generator
+func TestLLMJudge(t *testing.T) {
+	generator := &MockGenerator{
+		GenerateFunc: func(ctx context.Context, history []models.ChatMessage, prompt string, options models.GenerationOptions) (string, error) {
+			if !strings.Contains(prompt, "[1] Extract the tarball.") || !strings.Contains(prompt, "Answer: Use the tarball.") {
+				t.Errorf("Expected context and answer in prompt, got %q", prompt)
+			}
+			return "```json\n{\"faithfulness\": 8, \"answer_relevance\": 12}\n```", nil
+		},
+	}
+
+	scores, err := NewLLMJudge(generator).Judge(context.Background(), "How to install?", "Use the tarball.",
+		[]models.Document{models.NewDocument("Extract the tarball.", nil)})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if scores.Faithfulness != 0.8 || scores.AnswerRelevance != 1 {
+		t.Errorf("Expected scores 0.8 and 1, got %+v", scores)
+	}
+}
+
+// TestParseJudgeScoresInvalid tests that responses without grades are rejected
+func TestParseJudgeScoresInvalid(t *testing.T) {
+	for _, text := range []string{"The answer is good.", `{"faithfulness": 5}`, `{"faithfulness": "high"}`} {
+		if _, err := parseJudgeScores(text); err == nil {
+			t.Errorf("Expected an error for %q", text)
+		}
+	}
+}
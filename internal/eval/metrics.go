@@ -0,0 +1,116 @@
+package eval
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// RankingMetrics are the retrieval metrics of a single case
+type RankingMetrics struct {
+	Recall         float64 `json:"recall"`
+	ReciprocalRank float64 `json:"reciprocal_rank"`
+	NDCG           float64 `json:"ndcg"`
+	// Hits are the 1-based ranks of the results that matched a new expected item
+	Hits []int `json:"hits"`
+}
+
+// rankResults computes recall@k, reciprocal rank and nDCG@k of the results
+// with binary relevance. A result is relevant when it matches an expected item
+// that no higher ranked result matched, so several chunks of one expected
+// source count once.
+func rankResults(c Case, results []models.SearchResult, k int) RankingMetrics {
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+
+	metrics := RankingMetrics{Hits: []int{}}
+	found := make(map[string]bool)
+	var dcg float64
+	for i, result := range results {
+		key, ok := c.match(result.Document)
+		if !ok || found[key] {
+			continue
+		}
+		found[key] = true
+
+		rank := i + 1
+		metrics.Hits = append(metrics.Hits, rank)
+		if metrics.ReciprocalRank == 0 {
+			metrics.ReciprocalRank = 1 / float64(rank)
+		}
+		dcg += 1 / math.Log2(float64(rank)+1)
+	}
+
+	expected := c.expectedCount()
+	if expected == 0 {
+		return metrics
+	}
+	metrics.Recall = float64(len(found)) / float64(expected)
+
+	// The ideal ranking places every expected item at the top
+	ideal := expected
+	if k > 0 && ideal > k {
+		ideal = k
+	}
+	var idcg float64
+	for rank := 1; rank <= ideal; rank++ {
+		idcg += 1 / math.Log2(float64(rank)+1)
+	}
+	metrics.NDCG = dcg / idcg
+
+	return metrics
+}
+
+// LatencyStats summarizes latencies in milliseconds
+type LatencyStats struct {
+	Mean float64 `json:"mean_ms"`
+	P50  float64 `json:"p50_ms"`
+	P95  float64 `json:"p95_ms"`
+	Max  float64 `json:"max_ms"`
+}
+
+// latencyStats computes the statistics of the durations
+func latencyStats(durations []time.Duration) LatencyStats {
+	if len(durations) == 0 {
+		return LatencyStats{}
+	}
+
+	ms := make([]float64, len(durations))
+	var sum float64
+	for i, d := range durations {
+		ms[i] = durationMs(d)
+		sum += ms[i]
+	}
+	sort.Float64s(ms)
+
+	return LatencyStats{
+		Mean: sum / float64(len(ms)),
+		P50:  percentile(ms, 50),
+		P95:  percentile(ms, 95),
+		Max:  ms[len(ms)-1],
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// mean returns the average of the values, or zero without values
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
@@ -0,0 +1,89 @@
+package eval
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// fileResult returns a search result for a chunk of the named file
+func fileResult(fileName string) models.SearchResult {
+	return models.SearchResult{
+		Document: models.NewDocument("chunk of "+fileName, map[string]interface{}{
+			"file_name": fileName,
+			"file_path": "data/samples/" + fileName,
+		}),
+	}
+}
+
+// TestRankResults tests recall, reciprocal rank and nDCG with binary relevance
+func TestRankResults(t *testing.T) {
+	expectedDoc := models.NewDocument("expected chunk", nil)
+	c := Case{
+		ExpectedSources:   []string{"install.md", "samples/faq.md"},
+		ExpectedDocuments: []uuid.UUID{expectedDoc.ID},
+	}
+
+	results := []models.SearchResult{
+		fileResult("other.md"),
+		fileResult("install.md"),
+		fileResult("install.md"), // A second chunk of the same source counts once
+		{Document: expectedDoc},
+		fileResult("unrelated.md"),
+	}
+
+	metrics := rankResults(c, results, 5)
+
+	if math.Abs(metrics.Recall-2.0/3) > 1e-9 {
+		t.Errorf("Expected recall 2/3, got %f", metrics.Recall)
+	}
+	if metrics.ReciprocalRank != 0.5 {
+		t.Errorf("Expected reciprocal rank 0.5, got %f", metrics.ReciprocalRank)
+	}
+	if len(metrics.Hits) != 2 || metrics.Hits[0] != 2 || metrics.Hits[1] != 4 {
+		t.Errorf("Expected hits at ranks 2 and 4, got %v", metrics.Hits)
+	}
+
+	dcg := 1/math.Log2(3) + 1/math.Log2(5)
+	idcg := 1 + 1/math.Log2(3) + 1/math.Log2(4)
+	if math.Abs(metrics.NDCG-dcg/idcg) > 1e-9 {
+		t.Errorf("Expected nDCG %f, got %f", dcg/idcg, metrics.NDCG)
+	}
+
+	// Only the top k results count
+	metrics = rankResults(c, results, 1)
+	if metrics.Recall != 0 || metrics.ReciprocalRank != 0 || metrics.NDCG != 0 {
+		t.Errorf("Expected no hits at k=1, got %+v", metrics)
+	}
+}
+
+// TestRankResultsPerfect tests that a perfect ranking scores one
+func TestRankResultsPerfect(t *testing.T) {
+	c := Case{ExpectedSources: []string{"a.md", "b.md"}}
+	metrics := rankResults(c, []models.SearchResult{fileResult("b.md"), fileResult("a.md")}, 5)
+
+	if metrics.Recall != 1 || metrics.ReciprocalRank != 1 || math.Abs(metrics.NDCG-1) > 1e-9 {
+		t.Errorf("Expected perfect metrics, got %+v", metrics)
+	}
+}
+
+// TestLatencyStats tests summarizing latencies
+func TestLatencyStats(t *testing.T) {
+	var durations []time.Duration
+	for i := 1; i <= 20; i++ {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+
+	stats := latencyStats(durations)
+	if stats.Mean != 10.5 || stats.P50 != 10 || stats.P95 != 19 || stats.Max != 20 {
+		t.Errorf("Unexpected latency stats %+v", stats)
+	}
+
+	if stats := latencyStats(nil); stats != (LatencyStats{}) {
+		t.Errorf("Expected empty stats, got %+v", stats)
+	}
+}
@@ -0,0 +1,246 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yourusername/go-rag/internal/models"
+	"github.com/yourusername/go-rag/internal/service"
+)
+
+// Options configures an evaluation run
+type Options struct {
+	// K is the number of retrieved documents the metrics are computed for
+	K int `json:"k"`
+	// Query holds the retrieval and generation options applied to every
+	// question; its query and limit are set per case
+	Query models.RAGQuery `json:"query"`
+	// Answer also generates an answer for every question
+	Answer bool `json:"answer"`
+	// Judge grades the generated answers when set
+	Judge Judge `json:"-"`
+	// Label describes the evaluated configuration in the report
+	Label string `json:"label,omitempty"`
+}
+
+// Report is the result of an evaluation run
+type Report struct {
+	Label     string       `json:"label,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	Options   Options      `json:"options"`
+	Summary   Summary      `json:"summary"`
+	Cases     []CaseResult `json:"cases"`
+}
+
+// Summary aggregates the metrics of all cases
+type Summary struct {
+	Cases  int `json:"cases"`
+	Failed int `json:"failed"`
+	// RecallAtK, MRR and NDCG average the ranking metrics at K
+	RecallAtK        float64       `json:"recall_at_k"`
+	MRR              float64       `json:"mrr"`
+	NDCG             float64       `json:"ndcg"`
+	RetrievalLatency LatencyStats  `json:"retrieval_latency"`
+	AnswerLatency    *LatencyStats `json:"answer_latency,omitempty"`
+	// Faithfulness and AnswerRelevance average the judged scores
+	Faithfulness    *float64 `json:"faithfulness,omitempty"`
+	AnswerRelevance *float64 `json:"answer_relevance,omitempty"`
+}
+
+// CaseResult is the evaluation of a single case
+type CaseResult struct {
+	ID       string `json:"id"`
+	Question string `json:"question"`
+	RankingMetrics
+	Retrieved      []RetrievedDocument `json:"retrieved"`
+	RetrievalMs    float64             `json:"retrieval_ms"`
+	Answer         string              `json:"answer,omitempty"`
+	ExpectedAnswer string              `json:"expected_answer,omitempty"`
+	AnswerMs       float64             `json:"answer_ms,omitempty"`
+	Judge          *JudgeScores        `json:"judge,omitempty"`
+	// Error is set when the case could not be evaluated; its metrics are zero
+	Error string `json:"error,omitempty"`
+}
+
+// RetrievedDocument describes a retrieved document of a case
+type RetrievedDocument struct {
+	DocumentID uuid.UUID `json:"document_id"`
+	FileName   string    `json:"file_name,omitempty"`
+	Similarity float32   `json:"similarity"`
+	Relevant   bool      `json:"relevant"`
+}
+
+// Runner runs evaluation datasets through a RAG service
+type Runner struct {
+	service service.RAGService
+	options Options
+}
+
+// NewRunner creates a new evaluation runner
+func NewRunner(ragService service.RAGService, options Options) *Runner {
+	if options.K <= 0 {
+		options.K = 5
+	}
+	if options.Judge != nil {
+		options.Answer = true
+	}
+	return &Runner{service: ragService, options: options}
+}
+
+// Run evaluates every case sequentially so that latencies are not distorted
+// by concurrent requests. Failing cases are reported and count as misses.
+func (r *Runner) Run(ctx context.Context, cases []Case) (*Report, error) {
+	report := &Report{
+		Label:     r.options.Label,
+		CreatedAt: time.Now().UTC(),
+		Options:   r.options,
+	}
+
+	var retrievalLatencies, answerLatencies []time.Duration
+	var faithfulness, answerRelevance []float64
+	for _, c := range cases {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		result, retrievalLatency, answerLatency := r.runCase(ctx, c)
+		if result.Error != "" {
+			report.Summary.Failed++
+			log.Printf("Case %s failed: %s", c.ID, result.Error)
+		} else {
+			retrievalLatencies = append(retrievalLatencies, retrievalLatency)
+			if r.options.Answer {
+				answerLatencies = append(answerLatencies, answerLatency)
+			}
+		}
+		if result.Judge != nil {
+			faithfulness = append(faithfulness, result.Judge.Faithfulness)
+			answerRelevance = append(answerRelevance, result.Judge.AnswerRelevance)
+		}
+
+		report.Summary.RecallAtK += result.Recall
+		report.Summary.MRR += result.ReciprocalRank
+		report.Summary.NDCG += result.NDCG
+		report.Cases = append(report.Cases, result)
+	}
+
+	summary := &report.Summary
+	summary.Cases = len(cases)
+	if summary.Cases > 0 {
+		summary.RecallAtK /= float64(summary.Cases)
+		summary.MRR /= float64(summary.Cases)
+		summary.NDCG /= float64(summary.Cases)
+	}
+	summary.RetrievalLatency = latencyStats(retrievalLatencies)
+	if r.options.Answer {
+		stats := latencyStats(answerLatencies)
+		summary.AnswerLatency = &stats
+	}
+	if len(faithfulness) > 0 {
+		f, a := mean(faithfulness), mean(answerRelevance)
+		summary.Faithfulness, summary.AnswerRelevance = &f, &a
+	}
+
+	return report, nil
+}
+
+// runCase retrieves documents for a case through the retrieval stages of the
+// query pipeline, computes its metrics and optionally answers and judges it
+func (r *Runner) runCase(ctx context.Context, c Case) (CaseResult, time.Duration, time.Duration) {
+	result := CaseResult{
+		ID:             c.ID,
+		Question:       c.Question,
+		ExpectedAnswer: c.ExpectedAnswer,
+		Retrieved:      []RetrievedDocument{},
+	}
+	result.Hits = []int{}
+
+	// Rank the documents the query pipeline selects, so that multi-query
+	// retrieval and reranking are reflected in the metrics
+	query := r.options.Query
+	query.Query = c.Question
+	query.Limit = r.options.K
+	start := time.Now()
+	results, err := r.service.Retrieve(ctx, query)
+	retrievalLatency := time.Since(start)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to retrieve documents: %v", err)
+		return result, 0, 0
+	}
+	result.RetrievalMs = durationMs(retrievalLatency)
+
+	result.RankingMetrics = rankResults(c, results, r.options.K)
+	for _, res := range results {
+		_, relevant := c.match(res.Document)
+		fileName, _ := res.Document.Metadata["file_name"].(string)
+		result.Retrieved = append(result.Retrieved, RetrievedDocument{
+			DocumentID: res.Document.ID,
+			FileName:   fileName,
+			Similarity: res.Similarity,
+			Relevant:   relevant,
+		})
+	}
+
+	if !r.options.Answer {
+		return result, retrievalLatency, 0
+	}
+
+	start = time.Now()
+	response, err := r.service.QueryWithOptions(ctx, query)
+	answerLatency := time.Since(start)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to answer: %v", err)
+		return result, retrievalLatency, answerLatency
+	}
+	result.Answer = response.Answer
+	result.AnswerMs = durationMs(answerLatency)
+
+	if r.options.Judge != nil {
+		scores, err := r.options.Judge.Judge(ctx, c.Question, response.Answer, response.Documents)
+		if err != nil {
+			// A failed grade leaves the case out of the judged averages
+			log.Printf("Failed to judge case %s: %v", c.ID, err)
+		} else {
+			result.Judge = &scores
+		}
+	}
+
+	return result, retrievalLatency, answerLatency
+}
+
+// Save writes the report as indented JSON
+func (r *Report) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// LoadReport reads a report written by Save
+func LoadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse report: %w", err)
+	}
+	return &report, nil
+}
+
+// durationMs converts a duration to fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,126 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/yourusername/go-rag/internal/models"
+	"github.com/yourusername/go-rag/internal/service"
+)
+
+// MockRAGService mocks the RAGService methods used by the runner; other
+// methods panic through the embedded nil interface
+type MockRAGService struct {
+	service.RAGService
+	RetrieveFunc         func(ctx context.Context, request models.RAGQuery) ([]models.SearchResult, error)
+	QueryWithOptionsFunc func(ctx context.Context, request models.RAGQuery) (*models.RAGResponse, error)
+}
+
+func (m *MockRAGService) Retrieve(ctx context.Context, request models.RAGQuery) ([]models.SearchResult, error) {
+	return m.RetrieveFunc(ctx, request)
+}
+
+func (m *MockRAGService) QueryWithOptions(ctx context.Context, request models.RAGQuery) (*models.RAGResponse, error) {
+	return m.QueryWithOptionsFunc(ctx, request)
+}
+
+// MockJudge returns fixed scores
+type MockJudge struct {
+	scores JudgeScores
+}
+
+func (m *MockJudge) Judge(ctx context.Context, question, answer string, documents []models.Document) (JudgeScores, error) {
+	return m.scores, nil
+}
+
+// TestRunner tests evaluating a dataset with answers and judging
+func TestRunner(t *testing.T) {
+	mockService := &MockRAGService{
+		RetrieveFunc: func(ctx context.Context, request models.RAGQuery) ([]models.SearchResult, error) {
+			if request.Limit != 3 || !request.MMR || request.MultiQuery != 2 {
+				t.Errorf("Expected k=3 with MMR and multi-query, got %+v", request)
+			}
+			switch request.Query {
+			case "hit":
+				return []models.SearchResult{fileResult("a.md"), fileResult("b.md")}, nil
+			case "miss":
+				return []models.SearchResult{fileResult("b.md")}, nil
+			}
+			return nil, errors.New("search failed")
+		},
+		QueryWithOptionsFunc: func(ctx context.Context, request models.RAGQuery) (*models.RAGResponse, error) {
+			if request.Limit != 3 || request.Template != "concise" {
+				t.Errorf("Unexpected query %+v", request)
+			}
+			return &models.RAGResponse{Answer: "answer to " + request.Query}, nil
+		},
+	}
+
+	runner := NewRunner(mockService, Options{
+		K:     3,
+		Query: models.RAGQuery{RetrievalOptions: models.RetrievalOptions{MMR: true}, MultiQuery: 2, Template: "concise"},
+		Judge: &MockJudge{scores: JudgeScores{Faithfulness: 0.5, AnswerRelevance: 1}},
+	})
+
+	report, err := runner.Run(context.Background(), []Case{
+		{ID: "1", Question: "hit", ExpectedSources: []string{"a.md"}},
+		{ID: "2", Question: "miss", ExpectedSources: []string{"a.md"}},
+		{ID: "3", Question: "error", ExpectedSources: []string{"a.md"}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	summary := report.Summary
+	if summary.Cases != 3 || summary.Failed != 1 {
+		t.Errorf("Expected 3 cases with 1 failure, got %+v", summary)
+	}
+	if summary.RecallAtK != 1.0/3 || summary.MRR != 1.0/3 {
+		t.Errorf("Expected failed cases to count as misses, got %+v", summary)
+	}
+	if summary.AnswerLatency == nil || summary.Faithfulness == nil || *summary.Faithfulness != 0.5 {
+		t.Errorf("Expected answer latency and judged scores, got %+v", summary)
+	}
+
+	first := report.Cases[0]
+	if first.Answer != "answer to hit" || len(first.Retrieved) != 2 || !first.Retrieved[0].Relevant || first.Retrieved[1].Relevant {
+		t.Errorf("Unexpected first case %+v", first)
+	}
+	if report.Cases[2].Error == "" {
+		t.Errorf("Expected the failing case to report its error")
+	}
+
+	// Reports survive a round trip through a file
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := report.Save(path); err != nil {
+		t.Fatalf("Failed to save report: %v", err)
+	}
+	loaded, err := LoadReport(path)
+	if err != nil {
+		t.Fatalf("Failed to load report: %v", err)
+	}
+	if loaded.Summary.RecallAtK != summary.RecallAtK || len(loaded.Cases) != 3 || loaded.Options.K != 3 {
+		t.Errorf("Unexpected loaded report %+v", loaded.Summary)
+	}
+}
+
+// TestRunnerRetrievalOnly tests that no answers are generated by default
+func TestRunnerRetrievalOnly(t *testing.T) {
+	mockService := &MockRAGService{
+		RetrieveFunc: func(ctx context.Context, request models.RAGQuery) ([]models.SearchResult, error) {
+			return []models.SearchResult{fileResult("a.md")}, nil
+		},
+	}
+
+	report, err := NewRunner(mockService, Options{}).Run(context.Background(), []Case{
+		{ID: "1", Question: "q", ExpectedSources: []string{"a.md"}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.Options.K != 5 || report.Summary.AnswerLatency != nil || report.Cases[0].Answer != "" {
+		t.Errorf("Expected a retrieval-only run with k=5, got %+v", report.Summary)
+	}
+}
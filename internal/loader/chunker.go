@@ -3,6 +3,7 @@ package loader
 import (
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 // ChunkingStrategy defines different approaches for chunking text
@@ -13,45 +14,130 @@ const (
 	ByParagraph ChunkingStrategy = "paragraph"
 	// BySentence chunks text by sentences
 	BySentence ChunkingStrategy = "sentence"
-	// ByFixedSize chunks text by a fixed number of characters
+	// ByFixedSize chunks text by a fixed number of characters or tokens
 	ByFixedSize ChunkingStrategy = "fixed_size"
+	// BySemantic chunks text at topic shifts detected with sentence embeddings
+	BySemantic ChunkingStrategy = "semantic"
+	// ByRecursive chunks text by recursively applying increasingly fine separators
+	ByRecursive ChunkingStrategy = "recursive"
+	// ByCode chunks source code along top-level declarations
+	ByCode ChunkingStrategy = "code"
 )
 
 // ChunkingOptions defines options for text chunking
 type ChunkingOptions struct {
 	// Strategy determines how to chunk the text
 	Strategy ChunkingStrategy
-	// MaxChunkSize is the maximum size of a chunk in characters/tokens
+	// MaxChunkSize is the maximum size of a chunk in SizeUnit
 	MaxChunkSize int
-	// ChunkOverlap is the number of characters/tokens that overlap between chunks
+	// ChunkOverlap is the size of the overlap between chunks in SizeUnit
 	ChunkOverlap int
+	// SizeUnit determines whether sizes are measured in characters or tokens
+	SizeUnit SizeUnit
+	// Tokenizer is used to count tokens; defaults to an ApproximateTokenizer
+	Tokenizer Tokenizer
+	// MaxInputTokens is the embedding model's input limit in tokens.
+	// Chunks exceeding it are split further. Zero disables the guard.
+	MaxInputTokens int
+	// MinChunkSize is the minimum size of a semantic chunk in SizeUnit
+	MinChunkSize int
+	// SemanticPercentile is the percentile of adjacent sentence similarities
+	// below which the semantic strategy starts a new chunk
+	SemanticPercentile float64
+	// Separators is the ordered list of separators used by the recursive
+	// strategy, from coarsest to finest; defaults to DefaultSeparators
+	Separators []string
 }
 
 // DefaultChunkingOptions returns the default chunking options
 func DefaultChunkingOptions() ChunkingOptions {
 	return ChunkingOptions{
-		Strategy:     ByParagraph,
-		MaxChunkSize: 1000,
-		ChunkOverlap: 100,
+		Strategy:           ByParagraph,
+		MaxChunkSize:       1000,
+		ChunkOverlap:       100,
+		SizeUnit:           SizeInCharacters,
+		MaxInputTokens:     2048,
+		MinChunkSize:       200,
+		SemanticPercentile: defaultSemanticPercentile,
 	}
 }
 
-// ChunkText splits text into chunks based on the specified strategy
+// tokenizer returns the tokenizer used to count model tokens
+func (o ChunkingOptions) tokenizer() Tokenizer {
+	if o.Tokenizer != nil {
+		return o.Tokenizer
+	}
+	return NewApproximateTokenizer()
+}
+
+// separators returns the separators used by the recursive strategy
+func (o ChunkingOptions) separators() []string {
+	if len(o.Separators) > 0 {
+		return o.Separators
+	}
+	return DefaultSeparators()
+}
+
+// sizeTokenizer returns the tokenizer used to measure chunk sizes
+func (o ChunkingOptions) sizeTokenizer() Tokenizer {
+	if o.SizeUnit == SizeInTokens {
+		return o.tokenizer()
+	}
+	return CharacterTokenizer{}
+}
+
+// ChunkText splits text into chunks based on the specified strategy.
+// The semantic strategy needs an embedding service and is handled by
+// ChunkTextSemantic; ChunkText falls back to sentence chunking for it.
 func ChunkText(text string, options ChunkingOptions) []string {
+	// Replace invalid byte sequences so every chunk is valid UTF-8
+	text = strings.ToValidUTF8(text, string(utf8.RuneError))
+
+	tok := options.sizeTokenizer()
+
+	var chunks []string
 	switch options.Strategy {
 	case ByParagraph:
-		return chunkByParagraph(text, options.MaxChunkSize, options.ChunkOverlap)
-	case BySentence:
-		return chunkBySentence(text, options.MaxChunkSize, options.ChunkOverlap)
+		chunks = chunkByParagraph(text, options.MaxChunkSize, options.ChunkOverlap, tok)
+	case BySentence, BySemantic:
+		chunks = chunkBySentence(text, options.MaxChunkSize, options.ChunkOverlap, tok)
 	case ByFixedSize:
-		return chunkByFixedSize(text, options.MaxChunkSize, options.ChunkOverlap)
+		chunks = chunkByFixedSize(text, options.MaxChunkSize, options.ChunkOverlap, tok)
+	case ByRecursive:
+		chunks = chunkRecursive(text, options.separators(), options.MaxChunkSize, options.ChunkOverlap, tok)
+	case ByCode:
+		chunks = []string{}
+		for _, chunk := range ChunkCode(text, "", options) {
+			chunks = append(chunks, chunk.Content)
+		}
 	default:
-		return chunkByParagraph(text, options.MaxChunkSize, options.ChunkOverlap)
+		chunks = chunkByParagraph(text, options.MaxChunkSize, options.ChunkOverlap, tok)
+	}
+
+	return enforceTokenLimit(chunks, options.MaxInputTokens, options.tokenizer())
+}
+
+// enforceTokenLimit splits any chunk exceeding the model input limit so that
+// the embedding model never silently truncates it
+func enforceTokenLimit(chunks []string, maxTokens int, tok Tokenizer) []string {
+	if maxTokens <= 0 {
+		return chunks
+	}
+
+	var result []string
+	for _, chunk := range chunks {
+		if tok.CountTokens(chunk) <= maxTokens {
+			result = append(result, chunk)
+			continue
+		}
+		result = append(result, chunkByFixedSize(chunk, maxTokens, 0, tok)...)
 	}
+
+	return result
 }
 
 // chunkByParagraph splits text into chunks by paragraphs
-func chunkByParagraph(text string, maxSize, overlap int) []string {
+func chunkByParagraph(text string, maxSize, overlap int, tok Tokenizer) []string {
 	// Split text by double newlines which typically indicate paragraphs
 	paragraphs := strings.Split(text, "\n\n")
 
@@ -70,16 +156,16 @@ func chunkByParagraph(text string, maxSize, overlap int) []string {
 	}
 
 	// If each paragraph is smaller than maxSize, return individual paragraphs
-	if allParagraphsUnderMaxSize(cleanParagraphs, maxSize) {
+	if allParagraphsUnderMaxSize(cleanParagraphs, maxSize, tok) {
 		return cleanParagraphs
 	}
 
 	// Otherwise, use fixed size chunking with paragraph boundaries preserved where possible
-	return chunkPreservingParagraphs(cleanParagraphs, maxSize, overlap)
+	return chunkPreservingParagraphs(cleanParagraphs, maxSize, overlap, tok)
 }
 
 // chunkBySentence splits text into chunks by sentences
-func chunkBySentence(text string, maxSize, overlap int) []string {
+func chunkBySentence(text string, maxSize, overlap int, tok Tokenizer) []string {
 	// Simple sentence splitting by common punctuation followed by space
 	sentences := splitIntoSentences(text)
 
@@ -97,11 +183,11 @@ func chunkBySentence(text string, maxSize, overlap int) []string {
 		return []string{}
 	}
 
-	return combineItemsIntoChunks(cleanSentences, maxSize, overlap)
+	return combineItemsIntoChunks(cleanSentences, maxSize, overlap, tok)
 }
 
-// chunkByFixedSize splits text into chunks of fixed size
-func chunkByFixedSize(text string, maxSize, overlap int) []string {
+// chunkByFixedSize splits text into chunks of a fixed number of tokens
+func chunkByFixedSize(text string, maxSize, overlap int, tok Tokenizer) []string {
 	var chunks []string
 	text = strings.TrimSpace(text)
 
@@ -109,29 +195,33 @@ func chunkByFixedSize(text string, maxSize, overlap int) []string {
 		return chunks
 	}
 
-	if len(text) <= maxSize {
+	spans := tok.Tokenize(text)
+	if maxSize <= 0 || len(spans) <= maxSize {
 		return []string{text}
 	}
 
+	// Make sure the window always advances
+	step := maxSize - overlap
+	if step <= 0 {
+		step = maxSize
+	}
+
 	// Sliding window with overlap
-	for i := 0; i < len(text); i += maxSize - overlap {
+	for i := 0; i < len(spans); i += step {
 		end := i + maxSize
-		if end > len(text) {
-			end = len(text)
+		if end > len(spans) {
+			end = len(spans)
 		}
 
-		// Don't create tiny chunks at the end
-		if end-i < maxSize/4 && len(chunks) > 0 {
-			// Extend the previous chunk
-			lastIdx := len(chunks) - 1
-			chunks[lastIdx] = chunks[lastIdx] + " " + text[i:end]
-			break
-		}
+		// Token spans always start and end on character boundaries. A short
+		// tail is kept as its own chunk, as maxSize may be a hard input limit.
+		chunk := strings.TrimSpace(text[spans[i].Start:spans[end-1].End])
 
-		chunk := text[i:end]
-		chunks = append(chunks, chunk)
+		if chunk != "" {
+			chunks = append(chunks, chunk)
+		}
 
-		if end == len(text) {
+		if end == len(spans) {
 			break
 		}
 	}
@@ -140,28 +230,28 @@ func chunkByFixedSize(text string, maxSize, overlap int) []string {
 }
 
 // chunkPreservingParagraphs combines paragraphs into chunks while preserving paragraph boundaries
-func chunkPreservingParagraphs(paragraphs []string, maxSize, overlap int) []string {
-	return combineItemsIntoChunks(paragraphs, maxSize, overlap)
+func chunkPreservingParagraphs(paragraphs []string, maxSize, overlap int, tok Tokenizer) []string {
+	return combineItemsIntoChunks(paragraphs, maxSize, overlap, tok)
 }
 
 // combineItemsIntoChunks combines text items (paragraphs, sentences) into chunks
-func combineItemsIntoChunks(items []string, maxSize, overlap int) []string {
+func combineItemsIntoChunks(items []string, maxSize, overlap int, tok Tokenizer) []string {
 	var chunks []string
 	var currentChunk string
 
 	for _, item := range items {
 		// If adding this item exceeds maxSize and we already have content
-		if len(currentChunk) > 0 && len(currentChunk)+1+len(item) > maxSize {
+		if len(currentChunk) > 0 && tok.CountTokens(currentChunk+" "+item) > maxSize {
 			// Store current chunk
 			chunks = append(chunks, strings.TrimSpace(currentChunk))
 
 			// Start new chunk with overlap if possible
-			if overlap > 0 && len(currentChunk) > overlap {
+			if overlap > 0 && tok.CountTokens(currentChunk) > overlap {
 				// Try to find a clean break point for overlap
 				words := strings.Fields(currentChunk)
 				if len(words) > 3 { // Need at least a few words for sensible overlap
-					// Take approximately the last 'overlap' characters worth of words
-					overlapText := getOverlapText(words, overlap)
+					// Take approximately the last 'overlap' tokens worth of words
+					overlapText := getOverlapText(words, overlap, tok)
 					currentChunk = overlapText + " " + item
 				} else {
 					currentChunk = item
@@ -178,9 +268,9 @@ func combineItemsIntoChunks(items []string, maxSize, overlap int) []string {
 		}
 
 		// Handle case where a single item is larger than maxSize
-		if len(currentChunk) > maxSize {
+		if tok.CountTokens(currentChunk) > maxSize {
 			// Chunk it by fixed size
-			fixedChunks := chunkByFixedSize(currentChunk, maxSize, overlap)
+			fixedChunks := chunkByFixedSize(currentChunk, maxSize, overlap, tok)
 
 			// Add all but the last fixed chunk
 			if len(fixedChunks) > 1 {
@@ -204,15 +294,15 @@ func combineItemsIntoChunks(items []string, maxSize, overlap int) []string {
 	return chunks
 }
 
-// getOverlapText returns approximately 'overlap' characters from the end of the word list
-func getOverlapText(words []string, overlapChars int) string {
+// getOverlapText returns approximately 'overlap' tokens from the end of the word list
+func getOverlapText(words []string, overlapSize int, tok Tokenizer) string {
 	total := 0
 	startIdx := len(words)
 
 	// Count backwards from the end to find where to start the overlap
 	for i := len(words) - 1; i >= 0; i-- {
-		total += len(words[i]) + 1 // +1 for space
-		if total >= overlapChars {
+		total += tok.CountTokens(words[i] + " ") // Include the separating space
+		if total >= overlapSize {
 			startIdx = i
 			break
 		}
@@ -231,20 +321,31 @@ func splitIntoSentences(text string) []string {
 	var sentences []string
 	var currentSentence strings.Builder
 
+	// Work on runes so that look-ahead never lands inside a multi-byte character
+	runes := []rune(text)
+
 	// Simple state to avoid splitting on periods in abbreviations, numbers, etc.
 	inAbbreviation := false
 
-	for i, r := range text {
+	for i, r := range runes {
 		currentSentence.WriteRune(r)
 
+		// Full-width terminators used in CJK text end a sentence without a following space
+		if isFullWidthTerminator(r) {
+			sentences = append(sentences, currentSentence.String())
+			currentSentence.Reset()
+			inAbbreviation = false
+			continue
+		}
+
 		// Check for potential end of sentence
 		if r == '.' || r == '!' || r == '?' {
 			// Look ahead to see if this is truly the end of a sentence
-			if i+1 < len(text) {
-				next := rune(text[i+1])
+			if i+1 < len(runes) {
+				next := runes[i+1]
 
 				// If followed by space and uppercase letter, likely a sentence boundary
-				if unicode.IsSpace(next) && i+2 < len(text) && unicode.IsUpper(rune(text[i+2])) {
+				if unicode.IsSpace(next) && i+2 < len(runes) && unicode.IsUpper(runes[i+2]) {
 					sentences = append(sentences, currentSentence.String())
 					currentSentence.Reset()
 					inAbbreviation = false
@@ -257,15 +358,11 @@ func splitIntoSentences(text string) []string {
 					currentSentence.Reset()
 					continue
 				}
-			} else if i+1 == len(text) {
-				// End of text, add final sentence
-				sentences = append(sentences, currentSentence.String())
-				break
 			}
 		}
 
 		// Track potential abbreviations
-		if unicode.IsLetter(r) && i+1 < len(text) && text[i+1] == '.' {
+		if unicode.IsLetter(r) && i+1 < len(runes) && runes[i+1] == '.' {
 			inAbbreviation = true
 		} else if unicode.IsSpace(r) {
 			inAbbreviation = false
@@ -280,10 +377,29 @@ func splitIntoSentences(text string) []string {
 	return sentences
 }
 
+// SplitSentences splits text into trimmed, non-empty sentences. Lines are
+// split separately so that list items without punctuation stay apart.
+func SplitSentences(text string) []string {
+	var sentences []string
+	for _, line := range strings.Split(text, "\n") {
+		for _, sentence := range splitIntoSentences(line) {
+			if sentence = strings.TrimSpace(sentence); sentence != "" {
+				sentences = append(sentences, sentence)
+			}
+		}
+	}
+	return sentences
+}
+
+// isFullWidthTerminator reports whether r is a CJK sentence terminator
+func isFullWidthTerminator(r rune) bool {
+	return r == '。' || r == '！' || r == '？'
+}
+
 // allParagraphsUnderMaxSize checks if all paragraphs are smaller than maxSize
-func allParagraphsUnderMaxSize(paragraphs []string, maxSize int) bool {
+func allParagraphsUnderMaxSize(paragraphs []string, maxSize int, tok Tokenizer) bool {
 	for _, p := range paragraphs {
-		if len(p) > maxSize {
+		if tok.CountTokens(p) > maxSize {
 			return false
 		}
 	}
@@ -1,8 +1,10 @@
 package loader
 
 import (
+	"math/rand"
 	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 func TestChunkText(t *testing.T) {
@@ -105,7 +107,7 @@ Paragraph 2. Another test paragraph.
 Paragraph 3. The final test paragraph for chunking.`
 
 	// Test with large max size
-	chunks := chunkByParagraph(text, 1000, 0)
+	chunks := chunkByParagraph(text, 1000, 0, CharacterTokenizer{})
 
 	// At least one chunk should be returned
 	if len(chunks) < 1 {
@@ -113,7 +115,7 @@ Paragraph 3. The final test paragraph for chunking.`
 	}
 
 	// Test with small max size that forces combining
-	smallChunks := chunkByParagraph(text, 20, 0)
+	smallChunks := chunkByParagraph(text, 20, 0, CharacterTokenizer{})
 	if len(smallChunks) < 1 {
 		t.Errorf("Expected at least one chunk for small max size, got %d", len(smallChunks))
 	}
@@ -130,7 +132,7 @@ func TestChunkBySentence(t *testing.T) {
 	// Text with sentences
 	text := "This is sentence one. This is sentence two! Is this sentence three? Yes, this is sentence four."
 
-	chunks := chunkBySentence(text, 1000, 0)
+	chunks := chunkBySentence(text, 1000, 0, CharacterTokenizer{})
 
 	// At least one chunk should be returned
 	if len(chunks) < 1 {
@@ -138,7 +140,7 @@ func TestChunkBySentence(t *testing.T) {
 	}
 
 	// Test with small max size
-	smallChunks := chunkBySentence(text, 15, 0)
+	smallChunks := chunkBySentence(text, 15, 0, CharacterTokenizer{})
 	if len(smallChunks) < 1 {
 		t.Errorf("Expected at least one chunk for small max size, got %d", len(smallChunks))
 	}
@@ -150,7 +152,7 @@ func TestChunkByFixedSize(t *testing.T) {
 	maxSize := 10
 	overlap := 2
 
-	chunks := chunkByFixedSize(text, maxSize, overlap)
+	chunks := chunkByFixedSize(text, maxSize, overlap, CharacterTokenizer{})
 
 	// We should get at least one chunk
 	if len(chunks) < 1 {
@@ -165,14 +167,14 @@ func TestChunkByFixedSize(t *testing.T) {
 	}
 
 	// Test empty text
-	emptyChunks := chunkByFixedSize("", maxSize, overlap)
+	emptyChunks := chunkByFixedSize("", maxSize, overlap, CharacterTokenizer{})
 	if len(emptyChunks) != 0 {
 		t.Errorf("Expected 0 chunks for empty text, got %d", len(emptyChunks))
 	}
 
 	// Test text smaller than max size
 	smallText := "small"
-	smallChunks := chunkByFixedSize(smallText, maxSize, overlap)
+	smallChunks := chunkByFixedSize(smallText, maxSize, overlap, CharacterTokenizer{})
 	if len(smallChunks) != 1 {
 		t.Errorf("Expected 1 chunk for text smaller than max size, got %d", len(smallChunks))
 	}
@@ -186,17 +188,180 @@ func TestAllParagraphsUnderMaxSize(t *testing.T) {
 	}
 
 	// All paragraphs should be under 100 chars
-	if !allParagraphsUnderMaxSize(paragraphs, 100) {
+	if !allParagraphsUnderMaxSize(paragraphs, 100, CharacterTokenizer{}) {
 		t.Error("Expected all paragraphs to be under 100 chars, but they weren't")
 	}
 
 	// The third paragraph should exceed 30 chars
-	if allParagraphsUnderMaxSize(paragraphs, 30) {
+	if allParagraphsUnderMaxSize(paragraphs, 30, CharacterTokenizer{}) {
 		t.Error("Expected at least one paragraph to exceed 30 chars, but none did")
 	}
 
 	// Empty paragraphs list should return true
-	if !allParagraphsUnderMaxSize([]string{}, 10) {
+	if !allParagraphsUnderMaxSize([]string{}, 10, CharacterTokenizer{}) {
 		t.Error("Expected empty paragraph list to return true")
 	}
 }
+
+func TestSplitIntoSentencesMultiByte(t *testing.T) {
+	testCases := []struct {
+		name     string
+		text     string
+		expected int
+	}{
+		{
+			name:     "cyrillic",
+			text:     "Это первое предложение. Это второе! Правда? Да.",
+			expected: 4,
+		},
+		{
+			name:     "cyrillic abbreviation",
+			text:     "Он родился в 1990 г. в Москве.",
+			expected: 1,
+		},
+		{
+			name:     "full-width terminators",
+			text:     "これは文です。これも文です！本当？",
+			expected: 3,
+		},
+		{
+			name:     "emoji before boundary",
+			text:     "Go is fun 🎉. Rust is too 🦀.",
+			expected: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sentences := splitIntoSentences(tc.text)
+			if len(sentences) != tc.expected {
+				t.Errorf("Expected %d sentences, got %d: %q", tc.expected, len(sentences), sentences)
+			}
+			if strings.Join(sentences, "") != tc.text {
+				t.Errorf("Sentences do not reassemble into the original text: %q", sentences)
+			}
+		})
+	}
+}
+
+func TestChunkByFixedSizeMultiByte(t *testing.T) {
+	text := strings.Repeat("Привет, мир! 你好世界 👩‍👩‍👧 ", 10)
+
+	chunks := chunkByFixedSize(text, 7, 2, CharacterTokenizer{})
+	if len(chunks) < 2 {
+		t.Fatalf("Expected multiple chunks, got %d", len(chunks))
+	}
+
+	for i, chunk := range chunks {
+		if !utf8.ValidString(chunk) {
+			t.Errorf("Chunk %d is not valid UTF-8: %q", i, chunk)
+		}
+		if strings.Contains(chunk, "👩\u200d👩") && !strings.Contains(chunk, "👩‍👩‍👧") {
+			t.Errorf("Chunk %d splits an emoji sequence: %q", i, chunk)
+		}
+	}
+}
+
+// chunkingStrategies lists every strategy exercised by the property tests
+var chunkingStrategies = []ChunkingStrategy{ByParagraph, BySentence, ByFixedSize, ByRecursive, ByCode}
+
+// checkChunkProperties verifies that chunks are valid UTF-8 and, when there
+// is no overlap, that together they contain all non-whitespace content of text
+func checkChunkProperties(t *testing.T, text string, options ChunkingOptions, chunks []string) {
+	t.Helper()
+
+	for i, chunk := range chunks {
+		if !utf8.ValidString(chunk) {
+			t.Fatalf("Chunk %d is not valid UTF-8: %q (options %+v)", i, chunk, options)
+		}
+		if strings.TrimSpace(chunk) == "" {
+			t.Fatalf("Chunk %d is empty (options %+v)", i, options)
+		}
+	}
+
+	if options.ChunkOverlap == 0 {
+		want := stripSpace(strings.ToValidUTF8(text, string(utf8.RuneError)))
+		got := stripSpace(strings.Join(chunks, ""))
+		if got != want {
+			t.Fatalf("Content lost with options %+v:\nwant %q\ngot  %q", options, want, got)
+		}
+	}
+}
+
+// stripSpace removes all whitespace from s
+func stripSpace(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
+
+// randomText builds text from a mix of scripts, emoji, punctuation and whitespace
+func randomText(rng *rand.Rand) string {
+	pieces := []string{
+		"Привет", "мир", "Это", "тест", "г.", "你好", "世界", "。", "😀", "👍🏽",
+		"🇷🇺", "👩‍👩‍👧", "e\u0301", "hello", "World", ".", "!", "?", ",", " ", " ",
+		"\n", "\n\n", "\t", "Ünïcödé", "١٢٣",
+	}
+
+	var sb strings.Builder
+	for n := rng.Intn(200); n > 0; n-- {
+		sb.WriteString(pieces[rng.Intn(len(pieces))])
+	}
+	return sb.String()
+}
+
+func TestChunkTextProperties(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+
+	for i := 0; i < 500; i++ {
+		text := randomText(rng)
+		options := ChunkingOptions{
+			Strategy:     chunkingStrategies[rng.Intn(len(chunkingStrategies))],
+			MaxChunkSize: 1 + rng.Intn(60),
+			SizeUnit:     []SizeUnit{SizeInCharacters, SizeInTokens}[rng.Intn(2)],
+		}
+		if rng.Intn(2) == 0 {
+			options.ChunkOverlap = rng.Intn(options.MaxChunkSize)
+		}
+
+		checkChunkProperties(t, text, options, ChunkText(text, options))
+	}
+}
+
+func FuzzChunkText(f *testing.F) {
+	f.Add("Это первое предложение. Это второе!\n\nНовый абзац 你好。", 10, 0)
+	f.Add("👩‍👩‍👧 e\u0301 🇷🇺 hello world", 3, 1)
+	f.Add("\xff\xfe invalid bytes", 5, 0)
+
+	f.Fuzz(func(t *testing.T, text string, maxSize, overlap int) {
+		if maxSize < 1 || maxSize > 200 || overlap < 0 || overlap >= maxSize {
+			t.Skip()
+		}
+
+		for _, strategy := range chunkingStrategies {
+			for _, unit := range []SizeUnit{SizeInCharacters, SizeInTokens} {
+				options := ChunkingOptions{
+					Strategy:     strategy,
+					MaxChunkSize: maxSize,
+					ChunkOverlap: overlap,
+					SizeUnit:     unit,
+				}
+				checkChunkProperties(t, text, options, ChunkText(text, options))
+			}
+		}
+	})
+}
+
+// TestSplitSentences tests splitting text into trimmed sentences and lines
+func TestSplitSentences(t *testing.T) {
+	text := "Go was designed at Google. It compiles fast!\n- Goroutines\n\n- Channels"
+	expected := []string{"Go was designed at Google.", "It compiles fast!", "- Goroutines", "- Channels"}
+
+	sentences := SplitSentences(text)
+	if len(sentences) != len(expected) {
+		t.Fatalf("Expected %d sentences, got %d: %q", len(expected), len(sentences), sentences)
+	}
+	for i, sentence := range sentences {
+		if sentence != expected[i] {
+			t.Errorf("Sentence %d: expected %q, got %q", i, expected[i], sentence)
+		}
+	}
+}
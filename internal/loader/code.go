@@ -0,0 +1,476 @@
+package loader
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// CodeChunk represents a chunk of source code together with the symbol it contains
+type CodeChunk struct {
+	Content string
+	// Symbol is the name of the declared symbol, empty for unnamed blocks
+	Symbol string
+	// Kind is the kind of declaration (function, method, type, class, ...)
+	Kind string
+	// StartLine and EndLine are the 1-based, inclusive line range of the chunk
+	StartLine int
+	EndLine   int
+}
+
+// Metadata returns the chunk's symbol information as document metadata
+func (c CodeChunk) Metadata() map[string]interface{} {
+	meta := map[string]interface{}{
+		"symbol_kind": c.Kind,
+		"start_line":  c.StartLine,
+		"end_line":    c.EndLine,
+	}
+	if c.Symbol != "" {
+		meta["symbol_name"] = c.Symbol
+	}
+	return meta
+}
+
+// codeLanguages maps source file extensions to language names
+var codeLanguages = map[string]string{
+	".go":    "go",
+	".py":    "python",
+	".js":    "javascript",
+	".jsx":   "javascript",
+	".mjs":   "javascript",
+	".ts":    "typescript",
+	".tsx":   "typescript",
+	".java":  "java",
+	".kt":    "kotlin",
+	".scala": "scala",
+	".c":     "c",
+	".h":     "c",
+	".cc":    "cpp",
+	".cpp":   "cpp",
+	".hpp":   "cpp",
+	".cs":    "csharp",
+	".rs":    "rust",
+	".swift": "swift",
+	".php":   "php",
+}
+
+// CodeLanguage returns the language of a source file based on its extension,
+// or an empty string if the extension is not a supported source file
+func CodeLanguage(path string) string {
+	return codeLanguages[strings.ToLower(filepath.Ext(path))]
+}
+
+// ChunkCode splits source code into chunks along top-level declarations.
+// Go code is split using go/parser; Python is split by indentation and other
+// languages by brace nesting. language is a value returned by CodeLanguage;
+// unknown languages are treated as brace-delimited.
+func ChunkCode(source, language string, options ChunkingOptions) []CodeChunk {
+	source = strings.ToValidUTF8(source, string(utf8.RuneError))
+	if strings.TrimSpace(source) == "" {
+		return []CodeChunk{}
+	}
+
+	var segments []CodeChunk
+	switch language {
+	case "go":
+		segments = segmentGoCode(source)
+		if segments == nil {
+			// Fall back to heuristics for code that does not parse
+			segments = segmentBraceCode(source)
+		}
+	case "python":
+		segments = segmentIndentedCode(source)
+	default:
+		segments = segmentBraceCode(source)
+	}
+
+	fits := func(content string) bool {
+		if options.sizeTokenizer().CountTokens(content) > options.MaxChunkSize {
+			return false
+		}
+		return options.MaxInputTokens <= 0 || options.tokenizer().CountTokens(content) <= options.MaxInputTokens
+	}
+
+	// Cut lines that do not fit on their own, such as minified code, by tokens
+	split := func(line string) []string {
+		pieces := chunkByFixedSize(line, options.MaxChunkSize, 0, options.sizeTokenizer())
+		return enforceTokenLimit(pieces, options.MaxInputTokens, options.tokenizer())
+	}
+
+	segments = mergeUnnamedSegments(segments, fits)
+
+	// Split declarations that are too large along line boundaries
+	var chunks []CodeChunk
+	for _, segment := range segments {
+		if fits(segment.Content) {
+			chunks = append(chunks, trimCodeChunk(segment))
+			continue
+		}
+		for _, piece := range splitCodeByLines(segment, fits, split) {
+			chunks = append(chunks, trimCodeChunk(piece))
+		}
+	}
+
+	// Drop chunks that contain nothing but whitespace
+	var result []CodeChunk
+	for _, chunk := range chunks {
+		if chunk.Content != "" {
+			result = append(result, chunk)
+		}
+	}
+
+	return result
+}
+
+// segmentGoCode splits Go source into the package preamble and one segment per
+// top-level declaration. It returns nil if the source cannot be parsed.
+func segmentGoCode(source string) []CodeChunk {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", source, parser.ParseComments)
+	if err != nil {
+		return nil
+	}
+
+	type boundary struct {
+		offset int
+		symbol string
+		kind   string
+	}
+
+	// The preamble holds the package clause and imports
+	boundaries := []boundary{{offset: 0, symbol: file.Name.Name, kind: "package"}}
+	for _, decl := range file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			continue
+		}
+
+		start := decl.Pos()
+		var symbol, kind string
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+			symbol, kind = d.Name.Name, "function"
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				symbol = receiverTypeName(d.Recv.List[0].Type) + "." + d.Name.Name
+				kind = "method"
+			}
+		case *ast.GenDecl:
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+			symbol, kind = genDeclSymbol(d), strings.ToLower(d.Tok.String())
+		}
+
+		// Move the boundary to the start of the line
+		offset := fset.Position(start).Offset
+		for offset > 0 && source[offset-1] != '\n' {
+			offset--
+		}
+
+		boundaries = append(boundaries, boundary{offset: offset, symbol: symbol, kind: kind})
+	}
+
+	// Each segment runs until the next declaration starts
+	var segments []CodeChunk
+	for i, b := range boundaries {
+		end := len(source)
+		if i+1 < len(boundaries) {
+			end = boundaries[i+1].offset
+		}
+		if end <= b.offset {
+			continue
+		}
+		segments = append(segments, CodeChunk{
+			Content:   source[b.offset:end],
+			Symbol:    b.symbol,
+			Kind:      b.kind,
+			StartLine: strings.Count(source[:b.offset], "\n") + 1,
+		})
+	}
+
+	return segments
+}
+
+// receiverTypeName returns the type name of a method receiver
+func receiverTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverTypeName(t.X)
+	case *ast.IndexExpr:
+		return receiverTypeName(t.X)
+	case *ast.IndexListExpr:
+		return receiverTypeName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// genDeclSymbol returns the names declared by a const, var or type declaration
+func genDeclSymbol(decl *ast.GenDecl) string {
+	var names []string
+	for _, spec := range decl.Specs {
+		switch s := spec.(type) {
+		case *ast.TypeSpec:
+			names = append(names, s.Name.Name)
+		case *ast.ValueSpec:
+			for _, name := range s.Names {
+				names = append(names, name.Name)
+			}
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// symbolPatterns recognize declarations in languages without a dedicated parser
+var symbolPatterns = []struct {
+	pattern *regexp.Regexp
+	kind    string
+}{
+	{regexp.MustCompile(`^(?:async\s+)?def\s+(\w+)`), "function"},
+	{regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*(\w+)`), "function"},
+	{regexp.MustCompile(`^(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?(?:unsafe\s+)?fn\s+(\w+)`), "function"},
+	{regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:public\s+|private\s+|protected\s+|internal\s+)?(?:abstract\s+|final\s+|sealed\s+|data\s+|static\s+)*class\s+(\w+)`), "class"},
+	{regexp.MustCompile(`^(?:export\s+)?(?:public\s+)?interface\s+(\w+)`), "interface"},
+	{regexp.MustCompile(`^(?:pub(?:\([^)]*\))?\s+)?trait\s+(\w+)`), "trait"},
+	{regexp.MustCompile(`^(?:pub(?:\([^)]*\))?\s+)?(?:typedef\s+)?struct\s+(\w+)`), "struct"},
+	{regexp.MustCompile(`^(?:export\s+)?(?:pub(?:\([^)]*\))?\s+)?(?:const\s+)?enum\s+(\w+)`), "enum"},
+	{regexp.MustCompile(`^impl(?:<[^>]*>)?\s+(?:\w+\s+for\s+)?(\w+)`), "impl"},
+	{regexp.MustCompile(`^(?:export\s+)?type\s+(\w+)`), "type"},
+	{regexp.MustCompile(`^(?:export\s+)?(?:const|let|var)\s+(\w+)`), "variable"},
+	{regexp.MustCompile(`^(?:[\w<>\[\]*&:,]+\s+)+\**(\w+)\s*\([^;]*$`), "function"},
+}
+
+// detectSymbol returns the symbol name and kind declared on the first
+// non-comment line of a code segment
+func detectSymbol(content string) (string, string) {
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || isCodeCommentLine(trimmed) {
+			continue
+		}
+		for _, sp := range symbolPatterns {
+			if m := sp.pattern.FindStringSubmatch(trimmed); m != nil {
+				return m[1], sp.kind
+			}
+		}
+		return "", "block"
+	}
+	return "", "block"
+}
+
+// isCodeCommentLine reports whether a trimmed line is a comment or an
+// annotation that belongs to the following declaration
+func isCodeCommentLine(trimmed string) bool {
+	for _, prefix := range []string{"//", "/*", "*", "#", "@"} {
+		if strings.HasPrefix(trimmed, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// segmentBraceCode splits brace-delimited source at top-level statements that
+// follow a blank line or the end of a block
+func segmentBraceCode(source string) []CodeChunk {
+	lines := strings.SplitAfter(source, "\n")
+
+	var starts []int
+	depth := 0
+	canStart := true
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		if depth == 0 && trimmed != "" && canStart {
+			starts = append(starts, commentBlockStart(lines, i))
+			canStart = false
+		}
+
+		depth += braceDelta(line)
+		if depth < 0 {
+			depth = 0
+		}
+
+		// A new segment may start after a blank line or once a block closed
+		if depth == 0 && (trimmed == "" || strings.HasPrefix(trimmed, "}")) {
+			canStart = true
+		}
+	}
+
+	return segmentsFromLineStarts(lines, starts)
+}
+
+// segmentIndentedCode splits indentation-delimited source (Python) at
+// non-indented lines
+func segmentIndentedCode(source string) []CodeChunk {
+	lines := strings.SplitAfter(source, "\n")
+
+	var starts []int
+	open := 0
+	continued := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		topLevel := trimmed != "" && line[0] != ' ' && line[0] != '\t'
+		if topLevel && open == 0 && !continued && !isCodeCommentLine(trimmed) {
+			start := commentBlockStart(lines, i)
+			if len(starts) == 0 || starts[len(starts)-1] < start {
+				starts = append(starts, start)
+			}
+		}
+
+		// Track bracketed and backslash continuations spanning lines
+		open += strings.Count(line, "(") + strings.Count(line, "[") + strings.Count(line, "{")
+		open -= strings.Count(line, ")") + strings.Count(line, "]") + strings.Count(line, "}")
+		if open < 0 {
+			open = 0
+		}
+		continued = strings.HasSuffix(strings.TrimRight(line, "\r\n"), "\\")
+	}
+
+	return segmentsFromLineStarts(lines, starts)
+}
+
+// commentBlockStart walks back from line i over contiguous comment and
+// annotation lines so that they stay with the declaration they describe
+func commentBlockStart(lines []string, i int) int {
+	start := i
+	for start > 0 {
+		prev := strings.TrimSpace(lines[start-1])
+		if prev == "" || !isCodeCommentLine(prev) {
+			break
+		}
+		start--
+	}
+	return start
+}
+
+// braceDelta returns the change in brace depth caused by a line, ignoring
+// braces inside string literals and line comments
+func braceDelta(line string) int {
+	delta := 0
+	var quote rune
+	escaped := false
+	prev := rune(0)
+	for _, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if r == '\\' {
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '/' && prev == '/':
+			return delta
+		case r == '"' || r == '\'' || r == '`':
+			quote = r
+		case r == '{':
+			delta++
+		case r == '}':
+			delta--
+		}
+		prev = r
+	}
+	return delta
+}
+
+// segmentsFromLineStarts builds segments that begin at the given line indexes
+func segmentsFromLineStarts(lines []string, starts []int) []CodeChunk {
+	// Leading lines before the first declaration form their own segment
+	if len(starts) == 0 || starts[0] != 0 {
+		starts = append([]int{0}, starts...)
+	}
+
+	var segments []CodeChunk
+	for i, start := range starts {
+		end := len(lines)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		if end <= start {
+			continue
+		}
+
+		content := strings.Join(lines[start:end], "")
+		symbol, kind := detectSymbol(content)
+		segments = append(segments, CodeChunk{
+			Content:   content,
+			Symbol:    symbol,
+			Kind:      kind,
+			StartLine: start + 1,
+		})
+	}
+
+	return segments
+}
+
+// mergeUnnamedSegments merges consecutive segments that declare no symbol
+// (imports, top-level statements) as long as the result still fits
+func mergeUnnamedSegments(segments []CodeChunk, fits func(string) bool) []CodeChunk {
+	var merged []CodeChunk
+	for _, segment := range segments {
+		if n := len(merged); n > 0 && segment.Symbol == "" && merged[n-1].Symbol == "" {
+			if combined := merged[n-1].Content + segment.Content; fits(combined) {
+				merged[n-1].Content = combined
+				continue
+			}
+		}
+		merged = append(merged, segment)
+	}
+	return merged
+}
+
+// splitCodeByLines splits a segment into pieces that fit, along line boundaries.
+// Lines that do not fit on their own are cut into pieces of their own by split.
+func splitCodeByLines(segment CodeChunk, fits func(string) bool, split func(string) []string) []CodeChunk {
+	lines := strings.SplitAfter(segment.Content, "\n")
+
+	var pieces []CodeChunk
+	current := CodeChunk{Symbol: segment.Symbol, Kind: segment.Kind, StartLine: segment.StartLine}
+	for i, line := range lines {
+		if current.Content != "" && !fits(current.Content+line) {
+			pieces = append(pieces, current)
+			current = CodeChunk{Symbol: segment.Symbol, Kind: segment.Kind, StartLine: segment.StartLine + i}
+		}
+		if !fits(line) {
+			for _, part := range split(line) {
+				pieces = append(pieces, CodeChunk{Content: part, Symbol: segment.Symbol, Kind: segment.Kind, StartLine: segment.StartLine + i})
+			}
+			current = CodeChunk{Symbol: segment.Symbol, Kind: segment.Kind, StartLine: segment.StartLine + i + 1}
+			continue
+		}
+		current.Content += line
+	}
+	if current.Content != "" {
+		pieces = append(pieces, current)
+	}
+
+	return pieces
+}
+
+// trimCodeChunk trims surrounding blank lines and computes the chunk's end line
+func trimCodeChunk(chunk CodeChunk) CodeChunk {
+	// Skip leading blank lines, keeping the line count accurate
+	content := chunk.Content
+	for {
+		idx := strings.IndexByte(content, '\n')
+		if idx < 0 || strings.TrimSpace(content[:idx]) != "" {
+			break
+		}
+		content = content[idx+1:]
+		chunk.StartLine++
+	}
+
+	chunk.Content = strings.TrimRight(content, " \t\r\n")
+	chunk.EndLine = chunk.StartLine + strings.Count(chunk.Content, "\n")
+
+	return chunk
+}
@@ -0,0 +1,204 @@
+package loader
+
+import (
+	"strings"
+	"testing"
+)
+
+// codeOptions returns chunking options large enough to keep every declaration whole
+func codeOptions() ChunkingOptions {
+	return ChunkingOptions{Strategy: ByCode, MaxChunkSize: 2000}
+}
+
+func TestChunkCodeGo(t *testing.T) {
+	source := `// Package demo is a demo.
+package demo
+
+import "fmt"
+
+// Greeter greets people.
+type Greeter struct {
+	Name string
+}
+
+// Greet prints a greeting.
+func (g *Greeter) Greet() {
+	fmt.Println("Hello,", g.Name)
+}
+
+const (
+	A = 1
+	B = 2
+)
+
+func main() {
+	(&Greeter{Name: "Go"}).Greet()
+}
+`
+
+	chunks := ChunkCode(source, "go", codeOptions())
+
+	expected := []struct {
+		symbol    string
+		kind      string
+		startLine int
+		endLine   int
+		prefix    string
+	}{
+		{symbol: "demo", kind: "package", startLine: 1, endLine: 4, prefix: "// Package demo"},
+		{symbol: "Greeter", kind: "type", startLine: 6, endLine: 9, prefix: "// Greeter greets"},
+		{symbol: "Greeter.Greet", kind: "method", startLine: 11, endLine: 14, prefix: "// Greet prints"},
+		{symbol: "A, B", kind: "const", startLine: 16, endLine: 19, prefix: "const ("},
+		{symbol: "main", kind: "function", startLine: 21, endLine: 23, prefix: "func main()"},
+	}
+
+	if len(chunks) != len(expected) {
+		t.Fatalf("Expected %d chunks, got %d: %+v", len(expected), len(chunks), chunks)
+	}
+
+	for i, want := range expected {
+		got := chunks[i]
+		if got.Symbol != want.symbol || got.Kind != want.kind {
+			t.Errorf("Chunk %d: expected %s %q, got %s %q", i, want.kind, want.symbol, got.Kind, got.Symbol)
+		}
+		if got.StartLine != want.startLine || got.EndLine != want.endLine {
+			t.Errorf("Chunk %d: expected lines %d-%d, got %d-%d", i, want.startLine, want.endLine, got.StartLine, got.EndLine)
+		}
+		if !strings.HasPrefix(got.Content, want.prefix) {
+			t.Errorf("Chunk %d: expected content to start with %q, got %q", i, want.prefix, got.Content)
+		}
+	}
+}
+
+func TestChunkCodeGoInvalidFallsBack(t *testing.T) {
+	source := "func broken( {\n\treturn\n}\n\nfunc other() {\n}\n"
+
+	chunks := ChunkCode(source, "go", codeOptions())
+	if len(chunks) != 2 {
+		t.Fatalf("Expected 2 chunks from brace heuristics, got %d: %+v", len(chunks), chunks)
+	}
+	if chunks[1].Symbol != "other" || chunks[1].StartLine != 5 {
+		t.Errorf("Unexpected second chunk %+v", chunks[1])
+	}
+}
+
+func TestChunkCodePython(t *testing.T) {
+	source := `import os
+import sys
+
+CONSTANT = (
+    1,
+    2,
+)
+
+
+@decorator
+def handler(event):
+    if event:
+        return 1
+
+    return 0
+
+
+# A class comment
+class Service:
+    def run(self):
+        pass
+`
+
+	chunks := ChunkCode(source, "python", codeOptions())
+
+	var symbols []string
+	for _, chunk := range chunks {
+		symbols = append(symbols, chunk.Kind+":"+chunk.Symbol)
+	}
+
+	expected := []string{"block:", "function:handler", "class:Service"}
+	if strings.Join(symbols, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected %v, got %v", expected, symbols)
+	}
+
+	if !strings.HasPrefix(chunks[1].Content, "@decorator") || chunks[1].StartLine != 10 || chunks[1].EndLine != 15 {
+		t.Errorf("Unexpected handler chunk %+v", chunks[1])
+	}
+	if !strings.HasPrefix(chunks[2].Content, "# A class comment") {
+		t.Errorf("Expected class chunk to include its comment, got %q", chunks[2].Content)
+	}
+}
+
+func TestChunkCodeTypeScript(t *testing.T) {
+	source := `import { x } from "./x";
+
+/** Adds numbers. */
+export function add(a: number, b: number): number {
+  const s = "{";
+  return a + b;
+}
+
+export class Calculator {
+  total = 0;
+}
+export interface Shape {
+  area(): number;
+}
+`
+
+	chunks := ChunkCode(source, "typescript", codeOptions())
+
+	var symbols []string
+	for _, chunk := range chunks {
+		symbols = append(symbols, chunk.Kind+":"+chunk.Symbol)
+	}
+
+	expected := []string{"block:", "function:add", "class:Calculator", "interface:Shape"}
+	if strings.Join(symbols, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected %v, got %v", expected, symbols)
+	}
+	if chunks[1].StartLine != 3 || chunks[1].EndLine != 7 {
+		t.Errorf("Expected add to span lines 3-7, got %d-%d", chunks[1].StartLine, chunks[1].EndLine)
+	}
+}
+
+func TestChunkCodeSplitsLargeDeclarations(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("package big\n\nfunc Big() {\n")
+	for i := 0; i < 50; i++ {
+		sb.WriteString("\tprintln(\"line\")\n")
+	}
+	sb.WriteString("}\n")
+
+	options := ChunkingOptions{Strategy: ByCode, MaxChunkSize: 200}
+	chunks := ChunkCode(sb.String(), "go", options)
+	if len(chunks) < 3 {
+		t.Fatalf("Expected the large function to be split, got %d chunks", len(chunks))
+	}
+
+	nextLine := chunks[1].StartLine
+	for i, chunk := range chunks[1:] {
+		if len(chunk.Content) > options.MaxChunkSize {
+			t.Errorf("Chunk %d size %d exceeds max size", i+1, len(chunk.Content))
+		}
+		if chunk.Symbol != "Big" {
+			t.Errorf("Chunk %d: expected symbol Big, got %q", i+1, chunk.Symbol)
+		}
+		if chunk.StartLine != nextLine {
+			t.Errorf("Chunk %d: expected to start at line %d, got %d", i+1, nextLine, chunk.StartLine)
+		}
+		nextLine = chunk.EndLine + 1
+	}
+}
+
+func TestCodeLanguage(t *testing.T) {
+	testCases := map[string]string{
+		"main.go":       "go",
+		"script.PY":     "python",
+		"app/index.tsx": "typescript",
+		"README.md":     "",
+	}
+
+	for path, expected := range testCases {
+		if got := CodeLanguage(path); got != expected {
+			t.Errorf("CodeLanguage(%q): expected %q, got %q", path, expected, got)
+		}
+	}
+}
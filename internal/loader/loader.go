@@ -2,6 +2,9 @@ package loader
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -38,6 +41,7 @@ type DocumentLoader struct {
 	db               database.VectorDB
 	embeddingService embeddings.EmbeddingService
 	chunkingOptions  ChunkingOptions
+	forceReindex     bool
 }
 
 // NewDocumentLoader creates a new document loader
@@ -58,6 +62,11 @@ func NewDocumentLoader(
 	}
 }
 
+// SetForceReindex makes the loader re-index files even if their content is unchanged
+func (l *DocumentLoader) SetForceReindex(force bool) {
+	l.forceReindex = force
+}
+
 // LoadFromFile loads documents from a file
 func (l *DocumentLoader) LoadFromFile(ctx context.Context, path string, metadata map[string]interface{}) error {
 	// Check file exists
@@ -111,9 +120,10 @@ func (l *DocumentLoader) loadFromDirectory(ctx context.Context, dirPath string,
 			return nil
 		}
 
-		// Check if file is a text file
+		// Check if file is a text file, or a source file when chunking code
 		ext := strings.ToLower(filepath.Ext(path))
-		if ext == ".txt" || ext == ".md" {
+		isCode := l.chunkingOptions.Strategy == ByCode && CodeLanguage(path) != ""
+		if ext == ".txt" || ext == ".md" || isCode {
 			// Create metadata for this file
 			fileMeta := l.createFileMetadata(path, metadata)
 
@@ -129,42 +139,142 @@ func (l *DocumentLoader) loadFromDirectory(ctx context.Context, dirPath string,
 	})
 }
 
-// ProcessDocument processes a document text, chunks it, generates embeddings, and stores in the database
+// ProcessDocument processes a document text, chunks it, generates embeddings, and stores in the database.
+// The document is registered as a source; a previously loaded version of the same file is replaced
+// only once all chunks are embedded, so a failed load keeps the previous version searchable.
 func (l *DocumentLoader) ProcessDocument(ctx context.Context, content string, metadata map[string]interface{}) error {
 	// Skip empty documents
 	if strings.TrimSpace(content) == "" {
 		return fmt.Errorf("empty document content")
 	}
 
+	// Create the source of the chunks
+	source, unchanged, err := l.newSource(ctx, content, metadata)
+	if err != nil {
+		return err
+	}
+	if unchanged {
+		log.Printf("Source %s is unchanged, skipping", source.URI)
+		return nil
+	}
+
 	// Chunk the document
-	chunks := ChunkText(content, l.chunkingOptions)
+	chunks, err := l.chunkDocument(ctx, content, metadata)
+	if err != nil {
+		return fmt.Errorf("failed to chunk document: %w", err)
+	}
 
 	// Log chunking result
 	log.Printf("Document chunked into %d parts", len(chunks))
 
-	// Process each chunk
+	// Embed each chunk
+	docs := make([]models.Document, 0, len(chunks))
+	vectors := make([][]float32, 0, len(chunks))
 	for i, chunk := range chunks {
 		// Create chunk-specific metadata
 		chunkMeta := l.createChunkMetadata(i, len(chunks), metadata)
+		for k, v := range chunk.metadata {
+			chunkMeta[k] = v
+		}
 
-		// Generate embedding for the chunk
-		embedding, err := l.embeddingService.GenerateEmbedding(ctx, chunk)
+		// Generate embedding for the chunk, titled with its source
+		embedding, err := l.embeddingService.GenerateEmbedding(ctx, chunk.content, embeddings.Options{
+			Purpose: embeddings.PurposeDocument,
+			Title:   source.Title,
+		})
 		if err != nil {
 			return fmt.Errorf("failed to generate embedding for chunk %d: %w", i, err)
 		}
 
-		// Create document model
-		doc := models.NewDocument(chunk, chunkMeta)
+		docs = append(docs, models.NewSourceDocument(source.ID, i, chunk.content, chunkMeta))
+		vectors = append(vectors, embedding)
+	}
+
+	// Store the source with its chunks, replacing the previous version
+	if err := l.db.ReplaceSource(ctx, source, docs, vectors); err != nil {
+		return fmt.Errorf("failed to store source: %w", err)
+	}
+
+	log.Printf("Stored %d chunks", len(docs))
 
-		// Store document and embedding
-		if err := l.db.StoreDocument(ctx, doc, embedding); err != nil {
-			return fmt.Errorf("failed to store chunk %d: %w", i, err)
+	return nil
+}
+
+// newSource creates the source record for a document. If a source with the
+// same URI exists and its checksum matches and re-indexing is not forced, the
+// existing source is returned as unchanged.
+func (l *DocumentLoader) newSource(
+	ctx context.Context,
+	content string,
+	metadata map[string]interface{},
+) (models.Source, bool, error) {
+	sum := sha256.Sum256([]byte(content))
+	checksum := hex.EncodeToString(sum[:])
+
+	uri, _ := metadata["file_path"].(string)
+	title, ok := metadata["title"].(string)
+	if !ok {
+		title, _ = metadata["file_name"].(string)
+	}
+
+	if uri != "" {
+		existing, err := l.db.FindSourceByURI(ctx, uri)
+		switch {
+		case err == nil && existing.Checksum == checksum && !l.forceReindex:
+			return existing, true, nil
+		case err == nil:
+			log.Printf("Re-indexing source %s", uri)
+		case !errors.Is(err, database.ErrSourceNotFound):
+			return models.Source{}, false, fmt.Errorf("failed to look up source: %w", err)
 		}
+	}
+
+	return models.NewSource(uri, title, checksum, metadata), false, nil
+}
+
+// documentChunk is a chunk of a document together with chunk-specific metadata
+type documentChunk struct {
+	content  string
+	metadata map[string]interface{}
+}
 
-		log.Printf("Stored chunk %d/%d", i+1, len(chunks))
+// chunkDocument splits content into chunks using the configured strategy
+func (l *DocumentLoader) chunkDocument(
+	ctx context.Context,
+	content string,
+	metadata map[string]interface{},
+) ([]documentChunk, error) {
+	var texts []string
+	switch l.chunkingOptions.Strategy {
+	case ByCode:
+		// Detect the language from the file path when available
+		filePath, _ := metadata["file_path"].(string)
+		language := CodeLanguage(filePath)
+
+		var chunks []documentChunk
+		for _, chunk := range ChunkCode(content, language, l.chunkingOptions) {
+			chunkMeta := chunk.Metadata()
+			if language != "" {
+				chunkMeta["language"] = language
+			}
+			chunks = append(chunks, documentChunk{content: chunk.Content, metadata: chunkMeta})
+		}
+		return chunks, nil
+	case BySemantic:
+		var err error
+		texts, err = ChunkTextSemantic(ctx, content, l.chunkingOptions, l.embeddingService)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		texts = ChunkText(content, l.chunkingOptions)
 	}
 
-	return nil
+	chunks := make([]documentChunk, 0, len(texts))
+	for _, text := range texts {
+		chunks = append(chunks, documentChunk{content: text})
+	}
+	return chunks, nil
 }
 
 // createFileMetadata creates metadata for a file
@@ -0,0 +1,164 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/go-rag/internal/database"
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// memorySourceDB keeps sources and their chunks in memory. Methods the loader
+// does not use are left to the embedded interface and panic when called.
+type memorySourceDB struct {
+	database.VectorDB
+	sources map[string]models.Source
+	chunks  map[string][]models.Document
+}
+
+func newMemorySourceDB() *memorySourceDB {
+	return &memorySourceDB{
+		sources: make(map[string]models.Source),
+		chunks:  make(map[string][]models.Document),
+	}
+}
+
+func (m *memorySourceDB) FindSourceByURI(ctx context.Context, uri string) (models.Source, error) {
+	source, ok := m.sources[uri]
+	if !ok {
+		return models.Source{}, database.ErrSourceNotFound
+	}
+	return source, nil
+}
+
+func (m *memorySourceDB) ReplaceSource(ctx context.Context, source models.Source, docs []models.Document, embeddings [][]float32) error {
+	if len(docs) != len(embeddings) {
+		return errors.New("documents and embeddings differ in number")
+	}
+	m.sources[source.URI] = source
+	m.chunks[source.URI] = docs
+	return nil
+}
+
+// TestProcessDocumentKeepsPreviousVersionOnFailure tests that a changed file
+// whose chunks cannot be embedded keeps its previously loaded chunks
+func TestProcessDocumentKeepsPreviousVersionOnFailure(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "guide.txt")
+	if err := os.WriteFile(path, []byte("The first version of the guide."), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	db := newMemorySourceDB()
+	service := &topicEmbeddingService{keywords: []string{"guide"}}
+	loader := NewDocumentLoader(db, service, DefaultChunkingOptions())
+
+	if err := loader.LoadFromFile(ctx, path, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	previous := db.sources[path]
+	previousChunks := db.chunks[path]
+	if len(previousChunks) == 0 {
+		t.Fatal("Expected chunks of the first version")
+	}
+
+	// Change the file and fail embedding its chunks
+	if err := os.WriteFile(path, []byte("The second version of the guide."), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	service.err = errors.New("quota exceeded")
+
+	if err := loader.LoadFromFile(ctx, path, nil); err == nil {
+		t.Fatal("Expected error when embedding fails")
+	}
+	if db.sources[path].ID != previous.ID {
+		t.Errorf("Expected source %s to be kept, got %s", previous.ID, db.sources[path].ID)
+	}
+	if len(db.chunks[path]) != len(previousChunks) || db.chunks[path][0].Content != previousChunks[0].Content {
+		t.Errorf("Expected the chunks of the first version to be kept, got %v", db.chunks[path])
+	}
+
+	// Loading again once embedding works replaces the previous version
+	service.err = nil
+	if err := loader.LoadFromFile(ctx, path, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if db.sources[path].ID == previous.ID {
+		t.Error("Expected the source to be replaced")
+	}
+	if chunks := db.chunks[path]; len(chunks) != 1 || chunks[0].Content != "The second version of the guide." {
+		t.Errorf("Expected the chunk of the second version, got %v", chunks)
+	}
+	if chunks := db.chunks[path]; *chunks[0].SourceID != db.sources[path].ID {
+		t.Error("Expected the chunk to belong to the new source")
+	}
+}
+
+// TestProcessDocumentSkipsUnchangedSource tests that unchanged files are not embedded again
+func TestProcessDocumentSkipsUnchangedSource(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "guide.txt")
+	if err := os.WriteFile(path, []byte("The guide."), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	db := newMemorySourceDB()
+	service := &topicEmbeddingService{keywords: []string{"guide"}}
+	loader := NewDocumentLoader(db, service, DefaultChunkingOptions())
+
+	if err := loader.LoadFromFile(ctx, path, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	source := db.sources[path]
+
+	// Embedding would fail, so loading only succeeds if the file is skipped
+	service.err = errors.New("quota exceeded")
+	if err := loader.LoadFromFile(ctx, path, nil); err != nil {
+		t.Fatalf("Expected unchanged file to be skipped, got %v", err)
+	}
+	if db.sources[path].ID != source.ID {
+		t.Error("Expected the source to be kept")
+	}
+
+	// Forcing re-indexing embeds the file again
+	loader.SetForceReindex(true)
+	if err := loader.LoadFromFile(ctx, path, nil); err == nil {
+		t.Error("Expected forced re-indexing to embed the file")
+	}
+}
+
+// TestProcessDocumentSplitsOversizedCodeLine tests that a minified line of code
+// is split into chunks within the chunk size and the embedding input limit
+func TestProcessDocumentSplitsOversizedCodeLine(t *testing.T) {
+	db := newMemorySourceDB()
+	options := DefaultChunkingOptions()
+	options.Strategy = ByCode
+	loader := NewDocumentLoader(db, &topicEmbeddingService{keywords: []string{"count"}}, options)
+
+	path := "/src/app.min.js"
+	content := "function minified(){" + strings.Repeat("count=count+1;", 1300) + "}\n"
+	if err := loader.ProcessDocument(context.Background(), content, map[string]interface{}{"file_path": path}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	chunks := db.chunks[path]
+	if len(chunks) < 2 {
+		t.Fatalf("Expected the line to be split, got %d chunks", len(chunks))
+	}
+	tok := options.tokenizer()
+	for i, chunk := range chunks {
+		if len(chunk.Content) > options.MaxChunkSize {
+			t.Errorf("Chunk %d has %d characters, more than %d", i, len(chunk.Content), options.MaxChunkSize)
+		}
+		if tokens := tok.CountTokens(chunk.Content); tokens > options.MaxInputTokens {
+			t.Errorf("Chunk %d has %d tokens, more than %d", i, tokens, options.MaxInputTokens)
+		}
+		if chunk.Metadata["symbol_name"] != "minified" || chunk.Metadata["start_line"] != 1 {
+			t.Errorf("Expected chunk %d to keep the symbol and line, got %v", i, chunk.Metadata)
+		}
+	}
+}
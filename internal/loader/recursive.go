@@ -0,0 +1,101 @@
+package loader
+
+import (
+	"strings"
+)
+
+// DefaultSeparators returns the default separators used by the recursive strategy,
+// ordered from the coarsest unit (sections) to the finest (words)
+func DefaultSeparators() []string {
+	return []string{"\n\n\n", "\n\n", "\n", ". ", " "}
+}
+
+// chunkRecursive splits text with the coarsest separator that occurs in it and
+// only descends to finer separators for pieces that are still larger than
+// maxSize, so that the largest possible semantic units are preserved
+func chunkRecursive(text string, separators []string, maxSize, overlap int, tok Tokenizer) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return []string{}
+	}
+
+	if maxSize <= 0 || tok.CountTokens(text) <= maxSize {
+		return []string{text}
+	}
+
+	for i, sep := range separators {
+		if sep == "" || !strings.Contains(text, sep) {
+			continue
+		}
+
+		// Split while keeping each separator at the end of its piece
+		var pieces []string
+		for _, piece := range strings.SplitAfter(text, sep) {
+			piece = strings.TrimSpace(piece)
+			if piece == "" {
+				continue
+			}
+
+			// Descend to finer separators only when the piece is still too large
+			if tok.CountTokens(piece) > maxSize {
+				pieces = append(pieces, chunkRecursive(piece, separators[i+1:], maxSize, overlap, tok)...)
+			} else {
+				pieces = append(pieces, piece)
+			}
+		}
+
+		return mergeSplits(pieces, separatorJoiner(sep), maxSize, overlap, tok)
+	}
+
+	// No separator left to split on
+	return chunkByFixedSize(text, maxSize, overlap, tok)
+}
+
+// mergeSplits combines pieces no larger than maxSize into chunks of at most
+// maxSize, carrying an overlap from the previous chunk only where it fits
+func mergeSplits(pieces []string, joiner string, maxSize, overlap int, tok Tokenizer) []string {
+	var chunks []string
+	var currentChunk string
+
+	for _, piece := range pieces {
+		if currentChunk == "" {
+			currentChunk = piece
+			continue
+		}
+
+		if candidate := currentChunk + joiner + piece; tok.CountTokens(candidate) <= maxSize {
+			currentChunk = candidate
+			continue
+		}
+
+		chunks = append(chunks, currentChunk)
+
+		// Start the next chunk with an overlap from the previous one if possible
+		currentChunk = piece
+		if overlap > 0 {
+			words := strings.Fields(chunks[len(chunks)-1])
+			if len(words) > 3 {
+				withOverlap := getOverlapText(words, overlap, tok) + " " + piece
+				if tok.CountTokens(withOverlap) <= maxSize {
+					currentChunk = withOverlap
+				}
+			}
+		}
+	}
+
+	if currentChunk != "" {
+		chunks = append(chunks, currentChunk)
+	}
+
+	return chunks
+}
+
+// separatorJoiner returns the string used to rejoin pieces split on sep.
+// Whitespace separators are restored as-is; others remain attached to their
+// pieces, so the pieces are joined with a single space.
+func separatorJoiner(sep string) string {
+	if strings.TrimSpace(sep) == "" {
+		return sep
+	}
+	return " "
+}
@@ -0,0 +1,87 @@
+package loader
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChunkRecursive(t *testing.T) {
+	text := "First paragraph line one.\nFirst paragraph line two.\n\n" +
+		"Second paragraph is short.\n\n" +
+		"Third paragraph has a very long sentence that keeps going. And another sentence follows it here."
+
+	chunks := chunkRecursive(text, DefaultSeparators(), 60, 0, CharacterTokenizer{})
+
+	expected := []string{
+		"First paragraph line one.\nFirst paragraph line two.",
+		"Second paragraph is short.",
+		"Third paragraph has a very long sentence that keeps going.",
+		"And another sentence follows it here.",
+	}
+	if len(chunks) != len(expected) {
+		t.Fatalf("Expected %d chunks, got %d: %q", len(expected), len(chunks), chunks)
+	}
+	for i := range expected {
+		if chunks[i] != expected[i] {
+			t.Errorf("Chunk %d: expected %q, got %q", i, expected[i], chunks[i])
+		}
+	}
+}
+
+func TestChunkRecursiveKeepsLargeUnits(t *testing.T) {
+	text := "Short one.\n\nShort two.\n\nShort three."
+
+	// Everything fits into a single chunk, so nothing is split
+	chunks := chunkRecursive(text, DefaultSeparators(), 1000, 0, CharacterTokenizer{})
+	if len(chunks) != 1 || chunks[0] != text {
+		t.Errorf("Expected the whole text as one chunk, got %q", chunks)
+	}
+
+	// Paragraphs are merged up to the max size instead of being split further
+	chunks = chunkRecursive(text, DefaultSeparators(), 25, 0, CharacterTokenizer{})
+	if len(chunks) != 2 || chunks[0] != "Short one.\n\nShort two." {
+		t.Errorf("Expected two paragraphs merged into the first chunk, got %q", chunks)
+	}
+}
+
+func TestChunkRecursiveCustomSeparators(t *testing.T) {
+	text := "a|b|c;d|e|f"
+
+	chunks := chunkRecursive(text, []string{";", "|"}, 6, 0, CharacterTokenizer{})
+	if len(chunks) != 2 || chunks[0] != "a|b|c;" || chunks[1] != "d|e|f" {
+		t.Errorf("Unexpected chunks %q", chunks)
+	}
+
+	// Without any matching separator fixed size chunking is used
+	chunks = chunkRecursive("abcdefghij", []string{";"}, 5, 0, CharacterTokenizer{})
+	if len(chunks) != 2 || chunks[0] != "abcde" {
+		t.Errorf("Expected fixed size fallback, got %q", chunks)
+	}
+
+	// The fallback keeps a short tail as its own chunk within the max size
+	chunks = chunkRecursive("abcdefghijk", []string{";"}, 5, 0, CharacterTokenizer{})
+	if len(chunks) != 3 || chunks[1] != "fghij" || chunks[2] != "k" {
+		t.Errorf("Expected the tail as its own chunk, got %q", chunks)
+	}
+}
+
+func TestChunkRecursiveRespectsMaxSize(t *testing.T) {
+	text := strings.Repeat("Lorem ipsum dolor sit amet, consectetur adipiscing elit.\nSed do eiusmod tempor.\n\n", 20)
+
+	for _, overlap := range []int{0, 20} {
+		chunks := ChunkText(text, ChunkingOptions{
+			Strategy:     ByRecursive,
+			MaxChunkSize: 100,
+			ChunkOverlap: overlap,
+		})
+
+		if len(chunks) < 2 {
+			t.Fatalf("Expected multiple chunks, got %d", len(chunks))
+		}
+		for i, chunk := range chunks {
+			if len(chunk) > 100 {
+				t.Errorf("Overlap %d: chunk %d size %d exceeds max size", overlap, i, len(chunk))
+			}
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yourusername/go-rag/internal/database"
+	"github.com/yourusername/go-rag/internal/embeddings"
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// defaultReindexBatchSize is the number of documents embedded at once
+const defaultReindexBatchSize = 100
+
+// maxActivationAttempts limits how often the reindexer catches up with
+// documents written while it was running before giving up on activation
+const maxActivationAttempts = 5
+
+// ReindexReport summarizes a re-indexing run
+type ReindexReport struct {
+	Model models.EmbeddingModel
+	// Embedded is the number of documents embedded by this run
+	Embedded int
+	// Total is the number of documents when the run finished
+	Total     int
+	Activated bool
+	Duration  time.Duration
+}
+
+// Reindexer embeds all documents with an embedding model next to the
+// embeddings of the active model, which keep serving queries meanwhile, and
+// activates the model once every document has an embedding of it
+type Reindexer struct {
+	store            database.EmbeddingIndexStore
+	embeddingService embeddings.EmbeddingService
+	model            models.EmbeddingModel
+	batchSize        int
+	activate         bool
+}
+
+// NewReindexer creates a new reindexer embedding documents with the model of the embedding service
+func NewReindexer(
+	store database.EmbeddingIndexStore,
+	embeddingService embeddings.EmbeddingService,
+	model models.EmbeddingModel,
+) *Reindexer {
+	return &Reindexer{
+		store:            store,
+		embeddingService: embeddingService,
+		model:            model,
+		batchSize:        defaultReindexBatchSize,
+		activate:         true,
+	}
+}
+
+// SetBatchSize sets the number of documents embedded at once
+func (r *Reindexer) SetBatchSize(batchSize int) {
+	if batchSize > 0 {
+		r.batchSize = batchSize
+	}
+}
+
+// SetActivate sets whether the model is activated once all documents are embedded
+func (r *Reindexer) SetActivate(activate bool) {
+	r.activate = activate
+}
+
+// Run embeds the documents without an embedding of the model, reporting the
+// progress, and activates the model. Documents written during the run are
+// embedded before activating, which is retried until none are left. Running
+// again after a failure resumes where the previous run stopped.
+func (r *Reindexer) Run(ctx context.Context) (ReindexReport, error) {
+	startTime := time.Now()
+	report := ReindexReport{Model: r.model}
+
+	if err := r.store.StartReindex(ctx, r.model); err != nil {
+		return report, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		embedded, total, err := r.embedMissing(ctx)
+		report.Embedded += embedded
+		report.Total = total
+		if err != nil {
+			return report, err
+		}
+		if !r.activate {
+			break
+		}
+
+		if err := r.store.CreateEmbeddingIndex(ctx, r.model); err != nil {
+			return report, err
+		}
+		err = r.store.ActivateEmbeddingModel(ctx, r.model)
+		if errors.Is(err, database.ErrEmbeddingsIncomplete) && attempt < maxActivationAttempts {
+			log.Printf("Documents were written while re-indexing, catching up: %v", err)
+			continue
+		}
+		if err != nil {
+			return report, fmt.Errorf("failed to activate embedding model %s: %w", r.model, err)
+		}
+
+		report.Activated = true
+		break
+	}
+
+	report.Duration = time.Since(startTime)
+	return report, nil
+}
+
+// embedMissing embeds the documents without an embedding of the model in
+// batches and returns the number of embedded documents and the total number
+// of documents
+func (r *Reindexer) embedMissing(ctx context.Context) (int, int, error) {
+	missing, total, err := r.store.CountUnembeddedDocuments(ctx, r.model)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	embedded := 0
+	for {
+		r.reportProgress(ctx, total-missing+embedded, total)
+
+		documents, err := r.store.ListUnembeddedDocuments(ctx, r.model, r.batchSize)
+		if err != nil {
+			return embedded, total, err
+		}
+		if len(documents) == 0 {
+			return embedded, total, nil
+		}
+
+		if err := r.embedBatch(ctx, documents); err != nil {
+			return embedded, total, err
+		}
+		embedded += len(documents)
+
+		// Documents written meanwhile count towards the total
+		if embedded > missing {
+			total += embedded - missing
+			missing = embedded
+		}
+	}
+}
+
+// embedBatch embeds documents and stores their embeddings. Documents are
+// embedded together with the other documents of the same title, as the title
+// is part of the embedding options.
+func (r *Reindexer) embedBatch(ctx context.Context, documents []database.UnembeddedDocument) error {
+	var titles []string
+	byTitle := make(map[string][]models.Document)
+	for _, document := range documents {
+		if _, ok := byTitle[document.Title]; !ok {
+			titles = append(titles, document.Title)
+		}
+		byTitle[document.Title] = append(byTitle[document.Title], document.Document)
+	}
+
+	vectors := make(map[uuid.UUID][]float32, len(documents))
+	for _, title := range titles {
+		docs := byTitle[title]
+		texts := make([]string, len(docs))
+		for i, doc := range docs {
+			texts[i] = doc.Content
+		}
+
+		batch, err := r.embeddingService.BatchGenerateEmbeddings(ctx, texts, embeddings.Options{
+			Purpose: embeddings.PurposeDocument,
+			Title:   title,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to generate embeddings: %w", err)
+		}
+		if len(batch) != len(docs) {
+			return fmt.Errorf("expected %d embeddings, got %d", len(docs), len(batch))
+		}
+		for i, doc := range docs {
+			vectors[doc.ID] = batch[i]
+		}
+	}
+
+	return r.store.StoreEmbeddings(ctx, r.model, vectors)
+}
+
+// reportProgress logs and records how many documents have an embedding of the model
+func (r *Reindexer) reportProgress(ctx context.Context, indexed, total int) {
+	percent := 100.0
+	if total > 0 {
+		percent = float64(indexed) * 100 / float64(total)
+	}
+	log.Printf("Re-indexed %d/%d documents with %s (%.0f%%)", indexed, total, r.model, percent)
+
+	if err := r.store.SetReindexProgress(ctx, r.model, indexed, total); err != nil {
+		log.Printf("Warning: failed to record re-index progress: %v", err)
+	}
+}
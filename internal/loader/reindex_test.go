@@ -0,0 +1,248 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/yourusername/go-rag/internal/database"
+	"github.com/yourusername/go-rag/internal/embeddings"
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// memoryIndexStore keeps documents and the models they were embedded with in memory
+type memoryIndexStore struct {
+	documents []database.UnembeddedDocument
+	embedded  map[uuid.UUID]bool
+	state     string
+	progress  [][2]int
+	indexed   bool
+	// onList and onActivate are called before listing documents and
+	// activating, e.g. to write documents during re-indexing
+	onList     func(store *memoryIndexStore)
+	onActivate func(store *memoryIndexStore)
+}
+
+func newMemoryIndexStore(titles ...string) *memoryIndexStore {
+	store := &memoryIndexStore{embedded: make(map[uuid.UUID]bool)}
+	for i, title := range titles {
+		store.add(title, fmt.Sprintf("chunk %d", i))
+	}
+	return store
+}
+
+func (s *memoryIndexStore) add(title, content string) {
+	s.documents = append(s.documents, database.UnembeddedDocument{
+		Document: models.Document{ID: uuid.New(), Content: content},
+		Title:    title,
+	})
+}
+
+func (s *memoryIndexStore) missing() int {
+	missing := 0
+	for _, document := range s.documents {
+		if !s.embedded[document.Document.ID] {
+			missing++
+		}
+	}
+	return missing
+}
+
+func (s *memoryIndexStore) EnsureActiveEmbeddingModel(ctx context.Context, model models.EmbeddingModel) (models.EmbeddingModel, error) {
+	return model, nil
+}
+
+func (s *memoryIndexStore) ListEmbeddingModels(ctx context.Context) ([]models.EmbeddingModelStatus, error) {
+	return nil, nil
+}
+
+func (s *memoryIndexStore) StartReindex(ctx context.Context, model models.EmbeddingModel) error {
+	s.state = models.EmbeddingModelIndexing
+	return nil
+}
+
+func (s *memoryIndexStore) CountUnembeddedDocuments(ctx context.Context, model models.EmbeddingModel) (int, int, error) {
+	return s.missing(), len(s.documents), nil
+}
+
+func (s *memoryIndexStore) ListUnembeddedDocuments(
+	ctx context.Context,
+	model models.EmbeddingModel,
+	limit int,
+) ([]database.UnembeddedDocument, error) {
+	if s.onList != nil {
+		s.onList(s)
+	}
+
+	var documents []database.UnembeddedDocument
+	for _, document := range s.documents {
+		if !s.embedded[document.Document.ID] && len(documents) < limit {
+			documents = append(documents, document)
+		}
+	}
+	return documents, nil
+}
+
+func (s *memoryIndexStore) StoreEmbeddings(
+	ctx context.Context,
+	model models.EmbeddingModel,
+	embeddings map[uuid.UUID][]float32,
+) error {
+	for id := range embeddings {
+		s.embedded[id] = true
+	}
+	return nil
+}
+
+func (s *memoryIndexStore) SetReindexProgress(ctx context.Context, model models.EmbeddingModel, indexed, total int) error {
+	s.progress = append(s.progress, [2]int{indexed, total})
+	return nil
+}
+
+func (s *memoryIndexStore) CreateEmbeddingIndex(ctx context.Context, model models.EmbeddingModel) error {
+	s.indexed = true
+	return nil
+}
+
+func (s *memoryIndexStore) ActivateEmbeddingModel(ctx context.Context, model models.EmbeddingModel) error {
+	if s.onActivate != nil {
+		s.onActivate(s)
+	}
+	if missing := s.missing(); missing > 0 {
+		return fmt.Errorf("%w: %d documents", database.ErrEmbeddingsIncomplete, missing)
+	}
+	s.state = models.EmbeddingModelActive
+	return nil
+}
+
+func (s *memoryIndexStore) PruneEmbeddings(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+// recordingEmbeddingService records the batches it embeds
+type recordingEmbeddingService struct {
+	topicEmbeddingService
+	batches []embeddings.Options
+	sizes   []int
+}
+
+func (s *recordingEmbeddingService) BatchGenerateEmbeddings(ctx context.Context, texts []string, options embeddings.Options) ([][]float32, error) {
+	s.batches = append(s.batches, options)
+	s.sizes = append(s.sizes, len(texts))
+	return s.topicEmbeddingService.BatchGenerateEmbeddings(ctx, texts, options)
+}
+
+// TestReindexer tests embedding all documents with a new model and activating it
+func TestReindexer(t *testing.T) {
+	store := newMemoryIndexStore("guide", "guide", "faq", "guide", "")
+	service := &recordingEmbeddingService{topicEmbeddingService: topicEmbeddingService{keywords: []string{"chunk"}}}
+	model := models.EmbeddingModel{Name: "text-embedding-004", Dimensions: 1}
+
+	reindexer := NewReindexer(store, service, model)
+	reindexer.SetBatchSize(3)
+	report, err := reindexer.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if report.Embedded != 5 || report.Total != 5 || !report.Activated {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if store.state != models.EmbeddingModelActive || !store.indexed {
+		t.Errorf("Expected the indexed model to be active, got state %s", store.state)
+	}
+
+	// Batches of 3 documents are embedded per title, as documents
+	expectedSizes := []int{2, 1, 1, 1}
+	if len(service.sizes) != len(expectedSizes) {
+		t.Fatalf("Expected batches %v, got %v", expectedSizes, service.sizes)
+	}
+	for i, size := range expectedSizes {
+		if service.sizes[i] != size {
+			t.Errorf("Expected batch %d of %d texts, got %d", i, size, service.sizes[i])
+		}
+		if service.batches[i].Purpose != embeddings.PurposeDocument {
+			t.Errorf("Expected documents to be embedded as documents, got %q", service.batches[i].Purpose)
+		}
+	}
+	if service.batches[0].Title != "guide" || service.batches[1].Title != "faq" {
+		t.Errorf("Expected documents to be embedded with their titles, got %+v", service.batches)
+	}
+
+	// Progress is reported before every batch and when done
+	expectedProgress := [][2]int{{0, 5}, {3, 5}, {5, 5}}
+	if fmt.Sprint(store.progress) != fmt.Sprint(expectedProgress) {
+		t.Errorf("Expected progress %v, got %v", expectedProgress, store.progress)
+	}
+}
+
+// TestReindexerCatchesUp tests embedding documents written while re-indexing before activating
+func TestReindexerCatchesUp(t *testing.T) {
+	store := newMemoryIndexStore("guide", "guide")
+	model := models.EmbeddingModel{Name: "text-embedding-004", Dimensions: 1}
+	service := &topicEmbeddingService{keywords: []string{"chunk"}}
+
+	// Documents are written during the first pass and right before activating
+	lists := 0
+	store.onList = func(store *memoryIndexStore) {
+		lists++
+		if lists == 2 {
+			store.add("news", "written meanwhile")
+		}
+	}
+	activations := 0
+	store.onActivate = func(store *memoryIndexStore) {
+		activations++
+		if activations == 1 {
+			store.add("news", "written before activation")
+		}
+	}
+
+	reindexer := NewReindexer(store, service, model)
+	reindexer.SetBatchSize(2)
+	report, err := reindexer.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.Embedded != 4 || report.Total != 4 || !report.Activated || store.missing() != 0 {
+		t.Errorf("Expected all 4 documents embedded before activation, got %+v", report)
+	}
+	if activations != 2 {
+		t.Errorf("Expected activation to be retried once, got %d attempts", activations)
+	}
+}
+
+// TestReindexerWithoutActivation tests embedding documents without switching models
+func TestReindexerWithoutActivation(t *testing.T) {
+	store := newMemoryIndexStore("guide")
+	model := models.EmbeddingModel{Name: "text-embedding-004", Dimensions: 1}
+
+	reindexer := NewReindexer(store, &topicEmbeddingService{keywords: []string{"chunk"}}, model)
+	reindexer.SetActivate(false)
+	report, err := reindexer.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.Activated || store.state != models.EmbeddingModelIndexing || store.missing() != 0 {
+		t.Errorf("Expected embedded documents without activation, got %+v in state %s", report, store.state)
+	}
+}
+
+// TestReindexerEmbeddingFailure tests that failed embeddings stop re-indexing without activation
+func TestReindexerEmbeddingFailure(t *testing.T) {
+	store := newMemoryIndexStore("guide", "faq")
+	model := models.EmbeddingModel{Name: "text-embedding-004", Dimensions: 1}
+	embeddingErr := errors.New("quota exceeded")
+
+	reindexer := NewReindexer(store, &topicEmbeddingService{err: embeddingErr}, model)
+	report, err := reindexer.Run(context.Background())
+	if !errors.Is(err, embeddingErr) {
+		t.Errorf("Expected the embedding error, got %v", err)
+	}
+	if report.Activated || store.state == models.EmbeddingModelActive {
+		t.Error("Expected the model not to be activated")
+	}
+}
@@ -0,0 +1,130 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yourusername/go-rag/internal/embeddings"
+)
+
+// defaultSemanticPercentile is used when ChunkingOptions.SemanticPercentile is not set
+const defaultSemanticPercentile = 10
+
+// ChunkTextSemantic splits text into chunks at topic shifts.
+//
+// Every sentence is embedded with the embedding service and the similarity of
+// each pair of adjacent sentences is computed. A breakpoint is placed wherever
+// the similarity drops below the SemanticPercentile-th percentile of all
+// adjacent similarities, as long as the current chunk has reached
+// MinChunkSize. Chunks never exceed MaxChunkSize. ChunkOverlap is ignored
+// because semantic chunks are cut at topic boundaries.
+func ChunkTextSemantic(
+	ctx context.Context,
+	text string,
+	options ChunkingOptions,
+	embeddingService embeddings.EmbeddingService,
+) ([]string, error) {
+	tok := options.sizeTokenizer()
+
+	// Split into sentences
+	var sentences []string
+	for _, s := range splitIntoSentences(strings.ToValidUTF8(text, string(utf8.RuneError))) {
+		trimmed := strings.TrimSpace(s)
+		if trimmed != "" {
+			sentences = append(sentences, trimmed)
+		}
+	}
+
+	// Nothing to compare with fewer than two sentences
+	if len(sentences) < 2 {
+		chunks := combineItemsIntoChunks(sentences, options.MaxChunkSize, 0, tok)
+		return enforceTokenLimit(chunks, options.MaxInputTokens, options.tokenizer()), nil
+	}
+
+	// Embed every sentence for comparison with its neighbors
+	vectors, err := embeddingService.BatchGenerateEmbeddings(ctx, sentences, embeddings.Options{Purpose: embeddings.PurposeSimilarity})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed sentences: %w", err)
+	}
+	if len(vectors) != len(sentences) {
+		return nil, fmt.Errorf("expected %d sentence embeddings, got %d", len(sentences), len(vectors))
+	}
+
+	// Similarity between each sentence and the next one
+	similarities := make([]float32, len(sentences)-1)
+	for i := range similarities {
+		similarities[i] = embeddingService.CalculateSimilarity(vectors[i], vectors[i+1])
+	}
+
+	percentile := options.SemanticPercentile
+	if percentile <= 0 {
+		percentile = defaultSemanticPercentile
+	}
+	threshold := percentileOf(similarities, percentile)
+
+	// Group sentences into chunks at breakpoints
+	var groups [][]string
+	current := []string{sentences[0]}
+	for i := 1; i < len(sentences); i++ {
+		currentText := strings.Join(current, " ")
+		topicShift := similarities[i-1] < threshold && tok.CountTokens(currentText) >= options.MinChunkSize
+		tooLarge := tok.CountTokens(currentText+" "+sentences[i]) > options.MaxChunkSize
+
+		if topicShift || tooLarge {
+			groups = append(groups, current)
+			current = nil
+		}
+		current = append(current, sentences[i])
+	}
+
+	// Merge a final undersized group into the previous one if it fits
+	if len(groups) > 0 && tok.CountTokens(strings.Join(current, " ")) < options.MinChunkSize {
+		last := groups[len(groups)-1]
+		merged := append(append([]string{}, last...), current...)
+		if tok.CountTokens(strings.Join(merged, " ")) <= options.MaxChunkSize {
+			groups = groups[:len(groups)-1]
+			current = merged
+		}
+	}
+	groups = append(groups, current)
+
+	// Join groups, splitting single sentences that are larger than the maximum
+	var chunks []string
+	for _, group := range groups {
+		chunk := strings.Join(group, " ")
+		if tok.CountTokens(chunk) > options.MaxChunkSize {
+			chunks = append(chunks, chunkByFixedSize(chunk, options.MaxChunkSize, 0, tok)...)
+			continue
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	return enforceTokenLimit(chunks, options.MaxInputTokens, options.tokenizer()), nil
+}
+
+// percentileOf returns the p-th percentile (0-100) of values using linear interpolation
+func percentileOf(values []float32, p float64) float32 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]float32{}, values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	if p <= 0 {
+		return sorted[0]
+	}
+	if p >= 100 {
+		return sorted[len(sorted)-1]
+	}
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(rank)
+	upper := min(lower+1, len(sorted)-1)
+	fraction := float32(rank - float64(lower))
+
+	return sorted[lower] + (sorted[upper]-sorted[lower])*fraction
+}
@@ -0,0 +1,152 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/go-rag/internal/embeddings"
+)
+
+// topicEmbeddingService embeds text into a vector of keyword counts so that
+// sentences about the same topic are similar
+type topicEmbeddingService struct {
+	keywords []string
+	err      error
+}
+
+func (s *topicEmbeddingService) GenerateEmbedding(ctx context.Context, text string, options embeddings.Options) ([]float32, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	vector := make([]float32, len(s.keywords))
+	lower := strings.ToLower(text)
+	for i, keyword := range s.keywords {
+		vector[i] = float32(strings.Count(lower, keyword))
+	}
+	return vector, nil
+}
+
+func (s *topicEmbeddingService) BatchGenerateEmbeddings(ctx context.Context, texts []string, options embeddings.Options) ([][]float32, error) {
+	var vectors [][]float32
+	for _, text := range texts {
+		vector, err := s.GenerateEmbedding(ctx, text, options)
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, vector)
+	}
+	return vectors, nil
+}
+
+func (s *topicEmbeddingService) CalculateSimilarity(vec1, vec2 []float32) float32 {
+	var dot, norm1, norm2 float64
+	for i := range vec1 {
+		dot += float64(vec1[i] * vec2[i])
+		norm1 += float64(vec1[i] * vec1[i])
+		norm2 += float64(vec2[i] * vec2[i])
+	}
+	if norm1 == 0 || norm2 == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(norm1) * math.Sqrt(norm2)))
+}
+
+func TestChunkTextSemantic(t *testing.T) {
+	text := "Cats are small pets. A cat likes to sleep. Many cats purr. " +
+		"Cars need fuel. A car has four wheels. Old cars rust. " +
+		"Cats hunt mice at night."
+
+	service := &topicEmbeddingService{keywords: []string{"cat", "car"}}
+	options := ChunkingOptions{
+		Strategy:           BySemantic,
+		MaxChunkSize:       1000,
+		MinChunkSize:       10,
+		SemanticPercentile: 50,
+	}
+
+	chunks, err := ChunkTextSemantic(context.Background(), text, options, service)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []string{
+		"Cats are small pets. A cat likes to sleep. Many cats purr.",
+		"Cars need fuel. A car has four wheels. Old cars rust.",
+		"Cats hunt mice at night.",
+	}
+	if len(chunks) != len(expected) {
+		t.Fatalf("Expected %d chunks, got %d: %q", len(expected), len(chunks), chunks)
+	}
+	for i := range expected {
+		if chunks[i] != expected[i] {
+			t.Errorf("Chunk %d: expected %q, got %q", i, expected[i], chunks[i])
+		}
+	}
+}
+
+func TestChunkTextSemanticRespectsSizes(t *testing.T) {
+	text := strings.Repeat("Cats are small pets. Cars need fuel. ", 20)
+
+	service := &topicEmbeddingService{keywords: []string{"cat", "car"}}
+
+	// A large minimum prevents splitting at every topic shift
+	options := ChunkingOptions{
+		Strategy:           BySemantic,
+		MaxChunkSize:       200,
+		MinChunkSize:       100,
+		SemanticPercentile: 50,
+	}
+
+	chunks, err := ChunkTextSemantic(context.Background(), text, options, service)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for i, chunk := range chunks {
+		if len(chunk) > options.MaxChunkSize {
+			t.Errorf("Chunk %d size %d exceeds max size %d", i, len(chunk), options.MaxChunkSize)
+		}
+		if i < len(chunks)-1 && len(chunk) < options.MinChunkSize {
+			t.Errorf("Chunk %d size %d is below min size %d", i, len(chunk), options.MinChunkSize)
+		}
+	}
+}
+
+func TestChunkTextSemanticEmbeddingError(t *testing.T) {
+	service := &topicEmbeddingService{err: errors.New("embedding failed")}
+	options := DefaultChunkingOptions()
+	options.Strategy = BySemantic
+
+	_, err := ChunkTextSemantic(context.Background(), "First sentence. Second sentence.", options, service)
+	if err == nil {
+		t.Error("Expected error when embedding fails, got nil")
+	}
+}
+
+func TestPercentileOf(t *testing.T) {
+	values := []float32{0.5, 0.1, 0.9, 0.3}
+
+	testCases := []struct {
+		percentile float64
+		expected   float32
+	}{
+		{percentile: 0, expected: 0.1},
+		{percentile: 100, expected: 0.9},
+		{percentile: 50, expected: 0.4},
+	}
+
+	for _, tc := range testCases {
+		got := percentileOf(values, tc.percentile)
+		if got < tc.expected-0.001 || got > tc.expected+0.001 {
+			t.Errorf("Percentile %v: expected %v, got %v", tc.percentile, tc.expected, got)
+		}
+	}
+
+	if got := percentileOf(nil, 50); got != 0 {
+		t.Errorf("Expected 0 for empty values, got %v", got)
+	}
+}
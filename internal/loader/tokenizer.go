@@ -0,0 +1,214 @@
+package loader
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// SizeUnit defines the unit in which chunk sizes and overlaps are measured
+type SizeUnit string
+
+const (
+	// SizeInCharacters measures chunk sizes in characters
+	SizeInCharacters SizeUnit = "chars"
+	// SizeInTokens measures chunk sizes in model tokens
+	SizeInTokens SizeUnit = "tokens"
+)
+
+// TokenSpan represents a single token as a byte range within the tokenized text
+type TokenSpan struct {
+	Start int
+	End   int
+}
+
+// Tokenizer splits text into tokens for size measurement
+type Tokenizer interface {
+	// Tokenize returns the token spans of text in order
+	Tokenize(text string) []TokenSpan
+	// CountTokens returns the number of tokens in text
+	CountTokens(text string) int
+}
+
+// CharacterTokenizer treats every user-perceived character of the text as a
+// single token, so multi-byte characters, combining marks and emoji sequences
+// are never split.
+type CharacterTokenizer struct{}
+
+// Tokenize returns one span per character of text
+func (CharacterTokenizer) Tokenize(text string) []TokenSpan {
+	spans := make([]TokenSpan, 0, len(text))
+	for i := 0; i < len(text); {
+		end := nextCharacterBoundary(text, i)
+		spans = append(spans, TokenSpan{Start: i, End: end})
+		i = end
+	}
+	return spans
+}
+
+// CountTokens returns the number of characters in text
+func (CharacterTokenizer) CountTokens(text string) int {
+	count := 0
+	for i := 0; i < len(text); i = nextCharacterBoundary(text, i) {
+		count++
+	}
+	return count
+}
+
+// ApproximateTokenizer estimates subword tokens the way SentencePiece-style
+// tokenizers tend to split text, without requiring a model vocabulary.
+//
+// Words are split into pieces of CharsPerToken characters (half that for
+// non-ASCII words, which tokenize less efficiently), CJK characters and
+// punctuation count as one token each, and whitespace is not counted.
+type ApproximateTokenizer struct {
+	// CharsPerToken is the average number of ASCII characters per token
+	CharsPerToken int
+}
+
+// NewApproximateTokenizer creates an approximate tokenizer with default settings
+func NewApproximateTokenizer() ApproximateTokenizer {
+	return ApproximateTokenizer{CharsPerToken: 4}
+}
+
+// Tokenize returns the approximate token spans of text
+func (t ApproximateTokenizer) Tokenize(text string) []TokenSpan {
+	var spans []TokenSpan
+
+	wordStart := -1
+	flushWord := func(end int) {
+		if wordStart < 0 {
+			return
+		}
+		spans = append(spans, t.splitWord(text, wordStart, end)...)
+		wordStart = -1
+	}
+
+	for i := 0; i < len(text); {
+		r, _ := utf8.DecodeRuneInString(text[i:])
+		end := nextCharacterBoundary(text, i)
+
+		switch {
+		case unicode.IsSpace(r):
+			flushWord(i)
+		case isCJK(r):
+			flushWord(i)
+			spans = append(spans, TokenSpan{Start: i, End: end})
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+			if wordStart < 0 {
+				wordStart = i
+			}
+		default:
+			flushWord(i)
+			spans = append(spans, TokenSpan{Start: i, End: end})
+		}
+
+		i = end
+	}
+	flushWord(len(text))
+
+	return spans
+}
+
+// CountTokens returns the approximate number of tokens in text
+func (t ApproximateTokenizer) CountTokens(text string) int {
+	return len(t.Tokenize(text))
+}
+
+// splitWord splits the word text[start:end] into token-sized pieces
+func (t ApproximateTokenizer) splitWord(text string, start, end int) []TokenSpan {
+	perToken := t.CharsPerToken
+	if perToken <= 0 {
+		perToken = 4
+	}
+
+	// Non-ASCII words usually need more tokens per character
+	for i := start; i < end; i++ {
+		if text[i] >= utf8.RuneSelf {
+			perToken = max(perToken/2, 1)
+			break
+		}
+	}
+
+	var spans []TokenSpan
+	pieceStart := start
+	count := 0
+	for i := start; i < end; i = nextCharacterBoundary(text, i) {
+		if count == perToken {
+			spans = append(spans, TokenSpan{Start: pieceStart, End: i})
+			pieceStart = i
+			count = 0
+		}
+		count++
+	}
+	spans = append(spans, TokenSpan{Start: pieceStart, End: end})
+
+	return spans
+}
+
+// nextCharacterBoundary returns the byte offset of the character boundary
+// following the character that starts at offset start. A character is a rune
+// together with any combining marks, variation selectors, emoji modifiers and
+// zero-width-joined runes that follow it, which approximates a grapheme cluster.
+func nextCharacterBoundary(text string, start int) int {
+	r, size := utf8.DecodeRuneInString(text[start:])
+	i := start + size
+
+	// CRLF is a single character
+	if r == '\r' && i < len(text) && text[i] == '\n' {
+		return i + 1
+	}
+
+	// Regional indicators pair up into flags
+	if isRegionalIndicator(r) && i < len(text) {
+		if next, nextSize := utf8.DecodeRuneInString(text[i:]); isRegionalIndicator(next) {
+			i += nextSize
+		}
+	}
+
+	for i < len(text) {
+		next, nextSize := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case next == zeroWidthJoiner:
+			i += nextSize
+			// The joiner glues the following rune into the same character
+			if i < len(text) {
+				_, joinedSize := utf8.DecodeRuneInString(text[i:])
+				i += joinedSize
+			}
+		case isExtendingRune(next):
+			i += nextSize
+		default:
+			return i
+		}
+	}
+
+	return i
+}
+
+// zeroWidthJoiner joins adjacent runes into a single emoji sequence
+const zeroWidthJoiner = '\u200d'
+
+// isExtendingRune reports whether r extends the preceding character
+func isExtendingRune(r rune) bool {
+	switch {
+	case unicode.IsMark(r):
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F: // Variation selectors
+		return true
+	case r >= 0x1F3FB && r <= 0x1F3FF: // Emoji skin tone modifiers
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // Emoji tag sequences
+		return true
+	}
+	return false
+}
+
+// isRegionalIndicator reports whether r is a regional indicator symbol
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// isCJK reports whether r belongs to a script that is tokenized per character
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
@@ -0,0 +1,154 @@
+package loader
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCharacterTokenizer(t *testing.T) {
+	tok := CharacterTokenizer{}
+
+	if count := tok.CountTokens("hello"); count != 5 {
+		t.Errorf("Expected 5 tokens, got %d", count)
+	}
+
+	spans := tok.Tokenize("abc")
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 spans, got %d", len(spans))
+	}
+	if spans[1].Start != 1 || spans[1].End != 2 {
+		t.Errorf("Unexpected span %+v", spans[1])
+	}
+}
+
+func TestApproximateTokenizer(t *testing.T) {
+	tok := NewApproximateTokenizer()
+
+	testCases := []struct {
+		name     string
+		text     string
+		expected int
+	}{
+		{name: "empty text", text: "", expected: 0},
+		{name: "short words", text: "Go is fun", expected: 3},
+		{name: "long word is split", text: "internationalization", expected: 5},
+		{name: "punctuation counts separately", text: "Hi, you!", expected: 4},
+		{name: "whitespace is ignored", text: "  a \n\n b  ", expected: 2},
+		{name: "CJK per character", text: "日本語", expected: 3},
+		{name: "non-ASCII words use smaller pieces", text: "привет", expected: 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if count := tok.CountTokens(tc.text); count != tc.expected {
+				t.Errorf("Expected %d tokens for %q, got %d", tc.expected, tc.text, count)
+			}
+		})
+	}
+}
+
+func TestApproximateTokenizerSpans(t *testing.T) {
+	tok := NewApproximateTokenizer()
+	text := "Привет, мир! Hello"
+
+	var rebuilt strings.Builder
+	prevEnd := 0
+	for _, span := range tok.Tokenize(text) {
+		if span.Start < prevEnd || span.End <= span.Start {
+			t.Fatalf("Spans must be ordered and non-empty, got %+v after %d", span, prevEnd)
+		}
+		rebuilt.WriteString(text[span.Start:span.End])
+		prevEnd = span.End
+	}
+
+	// Spans cover everything except whitespace
+	expected := strings.Join(strings.Fields(text), "")
+	if rebuilt.String() != expected {
+		t.Errorf("Expected spans to cover %q, got %q", expected, rebuilt.String())
+	}
+}
+
+func TestChunkTextInTokens(t *testing.T) {
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 40)
+
+	options := ChunkingOptions{
+		Strategy:     BySentence,
+		MaxChunkSize: 50,
+		ChunkOverlap: 5,
+		SizeUnit:     SizeInTokens,
+	}
+
+	tok := NewApproximateTokenizer()
+	chunks := ChunkText(text, options)
+	if len(chunks) < 2 {
+		t.Fatalf("Expected multiple chunks, got %d", len(chunks))
+	}
+
+	for i, chunk := range chunks {
+		if count := tok.CountTokens(chunk); count > options.MaxChunkSize {
+			t.Errorf("Chunk %d has %d tokens, exceeding max size %d", i, count, options.MaxChunkSize)
+		}
+	}
+}
+
+func TestChunkTextEnforcesInputLimit(t *testing.T) {
+	testCases := []struct {
+		name     string
+		text     string
+		expected int
+	}{
+		{name: "even split", text: strings.Repeat("word ", 500), expected: 5},
+		{name: "short tail", text: strings.Repeat("word ", 520), expected: 6},
+	}
+
+	tok := NewApproximateTokenizer()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			options := ChunkingOptions{
+				Strategy:       ByParagraph,
+				MaxChunkSize:   10000,
+				SizeUnit:       SizeInCharacters,
+				MaxInputTokens: 100,
+			}
+
+			chunks := ChunkText(tc.text, options)
+			if len(chunks) != tc.expected {
+				t.Errorf("Expected %d chunks, got %d", tc.expected, len(chunks))
+			}
+
+			for i, chunk := range chunks {
+				if count := tok.CountTokens(chunk); count > options.MaxInputTokens {
+					t.Errorf("Chunk %d has %d tokens, exceeding input limit %d", i, count, options.MaxInputTokens)
+				}
+			}
+		})
+	}
+}
+
+func TestCharacterTokenizerGraphemes(t *testing.T) {
+	tok := CharacterTokenizer{}
+
+	testCases := []struct {
+		name     string
+		text     string
+		expected int
+	}{
+		{name: "cyrillic", text: "привет", expected: 6},
+		{name: "combining mark", text: "e\u0301", expected: 1},
+		{name: "zwj emoji sequence", text: "👩‍👩‍👧", expected: 1},
+		{name: "skin tone modifier", text: "👍🏽", expected: 1},
+		{name: "flag", text: "🇷🇺🇩🇪", expected: 2},
+		{name: "crlf", text: "a\r\nb", expected: 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if count := tok.CountTokens(tc.text); count != tc.expected {
+				t.Errorf("Expected %d characters for %q, got %d", tc.expected, tc.text, count)
+			}
+			if spans := tok.Tokenize(tc.text); len(spans) != tc.expected {
+				t.Errorf("Expected %d spans for %q, got %d", tc.expected, tc.text, len(spans))
+			}
+		})
+	}
+}
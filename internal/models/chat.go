@@ -0,0 +1,86 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Roles of chat messages, matching the roles of Gemini multi-turn contents
+const (
+	// ChatRoleUser marks messages written by the user
+	ChatRoleUser = "user"
+	// ChatRoleModel marks answers generated by the model
+	ChatRoleModel = "model"
+)
+
+// ChatSession represents a conversation with the RAG system
+type ChatSession struct {
+	ID        uuid.UUID              `json:"id"`
+	Title     string                 `json:"title,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+// NewChatSession creates a new chat session with the given title
+func NewChatSession(title string, metadata map[string]interface{}) ChatSession {
+	now := time.Now()
+	return ChatSession{
+		ID:        uuid.New(),
+		Title:     title,
+		Metadata:  metadata,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// ChatSource references a document used to answer a chat message
+type ChatSource struct {
+	DocumentID uuid.UUID  `json:"document_id"`
+	SourceID   *uuid.UUID `json:"source_id,omitempty"`
+	Position   int        `json:"position"`
+	Similarity float32    `json:"similarity"`
+}
+
+// ChatMessage represents a single turn of a chat session
+type ChatMessage struct {
+	ID        uuid.UUID `json:"id"`
+	SessionID uuid.UUID `json:"session_id"`
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	// StandaloneQuery is the user message rewritten to be understood without the history
+	StandaloneQuery string `json:"standalone_query,omitempty"`
+	// Sources and Citations are set on model messages
+	Sources   []ChatSource `json:"sources,omitempty"`
+	Citations []Citation   `json:"citations,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// NewChatMessage creates a new message of a chat session
+func NewChatMessage(sessionID uuid.UUID, role, content string) ChatMessage {
+	return ChatMessage{
+		ID:        uuid.New(),
+		SessionID: sessionID,
+		Role:      role,
+		Content:   content,
+		CreatedAt: time.Now(),
+	}
+}
+
+// ChatRequest represents a message sent to a chat session
+type ChatRequest struct {
+	// SessionID is the session to continue; a new session is started when it is not set
+	SessionID *uuid.UUID `json:"session_id,omitempty"`
+	Message   string     `json:"message"`
+	// Options contains the retrieval and generation options of the RAG query;
+	// its Query field is ignored
+	Options RAGQuery `json:"options"`
+}
+
+// ChatResponse represents the answer to a chat message
+type ChatResponse struct {
+	SessionID       uuid.UUID `json:"session_id"`
+	StandaloneQuery string    `json:"standalone_query"`
+	RAGResponse
+}
@@ -1,14 +1,44 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// Source represents an ingested file or record that documents are chunked from
+type Source struct {
+	ID        uuid.UUID              `json:"id"`
+	URI       string                 `json:"uri,omitempty"`
+	Title     string                 `json:"title,omitempty"`
+	Checksum  string                 `json:"checksum"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+// NewSource creates a new source with the given URI, title, checksum and metadata
+func NewSource(uri, title, checksum string, metadata map[string]interface{}) Source {
+	now := time.Now()
+	return Source{
+		ID:        uuid.New(),
+		URI:       uri,
+		Title:     title,
+		Checksum:  checksum,
+		Metadata:  metadata,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
 // Document represents a document stored in the RAG system
 type Document struct {
 	ID        uuid.UUID              `json:"id"`
+	SourceID  *uuid.UUID             `json:"source_id,omitempty"`
+	Position  int                    `json:"position"`
 	Content   string                 `json:"content"`
 	Metadata  map[string]interface{} `json:"metadata,omitempty"`
 	CreatedAt time.Time              `json:"created_at"`
@@ -27,6 +57,14 @@ func NewDocument(content string, metadata map[string]interface{}) Document {
 	}
 }
 
+// NewSourceDocument creates a new document that is the chunk at the given position of a source
+func NewSourceDocument(sourceID uuid.UUID, position int, content string, metadata map[string]interface{}) Document {
+	doc := NewDocument(content, metadata)
+	doc.SourceID = &sourceID
+	doc.Position = position
+	return doc
+}
+
 // Embedding represents a vector embedding of a document
 type Embedding struct {
 	ID         uuid.UUID `json:"id"`
@@ -53,23 +91,366 @@ type VectorQuery struct {
 	Vector    []float32 `json:"vector"`
 	Limit     int       `json:"limit"`
 	Threshold float32   `json:"threshold"`
+	// IncludeVectors returns the stored embedding of every result
+	IncludeVectors bool `json:"include_vectors,omitempty"`
 }
 
 // SearchResult represents the result of a vector similarity search
 type SearchResult struct {
 	Document   Document `json:"document"`
 	Similarity float32  `json:"similarity"`
+	// Score is the relevance score assigned after retrieval, e.g. by rank
+	// fusion; zero when results are ranked by similarity only
+	Score float32 `json:"score,omitempty"`
+	// RerankScore is the score assigned by the reranker, between 0 and 1; nil
+	// when the result was not reranked
+	RerankScore *float32 `json:"rerank_score,omitempty"`
+	// Vector is the stored embedding of the document, set when requested by
+	// the vector query
+	Vector []float32 `json:"-"`
+}
+
+// SortByRelevance orders results by descending relevance, keeping their order
+// for equal relevance. Scores of different stages are not comparable, so the
+// whole list is ordered by the rerank scores if every result has one, else by
+// the scores assigned after retrieval if every result has one, else by similarity.
+func SortByRelevance(results []SearchResult) {
+	reranked, scored := true, true
+	for _, result := range results {
+		reranked = reranked && result.RerankScore != nil
+		scored = scored && result.Score != 0
+	}
+
+	relevance := func(r SearchResult) float32 {
+		switch {
+		case reranked:
+			return *r.RerankScore
+		case scored:
+			return r.Score
+		default:
+			return r.Similarity
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return relevance(results[i]) > relevance(results[j])
+	})
+}
+
+// RetrievalMode selects how the vector used for retrieval is built
+type RetrievalMode string
+
+const (
+	// RetrievalVector searches with the embedding of the query
+	RetrievalVector RetrievalMode = "vector"
+	// RetrievalHyDE searches with the embedding of a hypothetical answer
+	// generated for the query (hypothetical document embeddings)
+	RetrievalHyDE RetrievalMode = "hyde"
+)
+
+// ErrInvalidRetrievalOptions is returned when retrieval options are not supported
+var ErrInvalidRetrievalOptions = errors.New("invalid retrieval options")
+
+// RetrievalOptions controls how documents are retrieved for a query
+type RetrievalOptions struct {
+	// Mode defaults to RetrievalVector
+	Mode RetrievalMode `json:"retrieval_mode,omitempty"`
+	// HyDEIncludeQuery averages the hypothetical answer embedding with the query embedding
+	HyDEIncludeQuery bool `json:"hyde_include_query,omitempty"`
+	// MMR selects the results from a larger candidate pool with maximal
+	// marginal relevance, trading relevance for diversity
+	MMR bool `json:"mmr,omitempty"`
+	// MMRLambda weighs relevance against diversity between 0 (most diverse)
+	// and 1 (most relevant); defaults to DefaultMMRLambda
+	MMRLambda *float64 `json:"mmr_lambda,omitempty"`
+	// MMRCandidates is the size of the candidate pool; defaults to
+	// DefaultMMRPoolFactor times the limit
+	MMRCandidates int `json:"mmr_candidates,omitempty"`
+}
+
+// Defaults and bounds of maximal marginal relevance
+const (
+	DefaultMMRLambda     = 0.5
+	DefaultMMRPoolFactor = 4
+	MaxMMRCandidates     = 200
+)
+
+// Validate checks that the retrieval mode is supported and the MMR parameters are in range
+func (o RetrievalOptions) Validate() error {
+	switch o.Mode {
+	case "", RetrievalVector, RetrievalHyDE:
+	default:
+		return fmt.Errorf("%w: unknown retrieval mode %q", ErrInvalidRetrievalOptions, o.Mode)
+	}
+	if o.MMRLambda != nil && (*o.MMRLambda < 0 || *o.MMRLambda > 1) {
+		return fmt.Errorf("%w: mmr_lambda must be between 0 and 1", ErrInvalidRetrievalOptions)
+	}
+	if o.MMRCandidates < 0 || o.MMRCandidates > MaxMMRCandidates {
+		return fmt.Errorf("%w: mmr_candidates must be between 0 and %d", ErrInvalidRetrievalOptions, MaxMMRCandidates)
+	}
+	return nil
+}
+
+// Lambda returns the MMR lambda, or the default when it is not set
+func (o RetrievalOptions) Lambda() float64 {
+	if o.MMRLambda != nil {
+		return *o.MMRLambda
+	}
+	return DefaultMMRLambda
+}
+
+// PoolSize returns the number of MMR candidates retrieved for limit results
+func (o RetrievalOptions) PoolSize(limit int) int {
+	pool := o.MMRCandidates
+	if pool <= 0 {
+		pool = DefaultMMRPoolFactor * limit
+		if pool > MaxMMRCandidates {
+			pool = MaxMMRCandidates
+		}
+	}
+	if pool < limit {
+		pool = limit
+	}
+	return pool
 }
 
 // RAGQuery represents a query for the RAG system
 type RAGQuery struct {
 	Query string `json:"query"`
 	Limit int    `json:"limit,omitempty"`
+	RetrievalOptions
+	// NeighborChunks is the number of preceding and following chunks of the
+	// same source added around each retrieved chunk
+	NeighborChunks int `json:"neighbor_chunks,omitempty"`
+	// Template is the name of the prompt template used for the query
+	Template string `json:"template,omitempty"`
+	// Collection selects the prompt template configured for the collection
+	// when no template is given
+	Collection string `json:"collection,omitempty"`
+	// Generation overrides the configured generation options for the query
+	Generation *GenerationOptions `json:"generation,omitempty"`
+	// MultiQuery is the number of alternative queries generated and searched
+	// in addition to the query; their results are merged with rank fusion
+	MultiQuery int `json:"multi_query,omitempty"`
+	// RerankCandidates overrides the configured number of documents retrieved
+	// for reranking before the best Limit documents are kept
+	RerankCandidates int `json:"rerank_candidates,omitempty"`
+	// Explain adds the candidates, scores, prompt, raw model response, token
+	// usage and stage latencies to the response metadata
+	Explain bool `json:"explain,omitempty"`
+	// Groundedness overrides the configured verification of the answer
+	Groundedness *GroundednessOptions `json:"groundedness,omitempty"`
+	// NoCache bypasses the answer cache for the query
+	NoCache bool `json:"no_cache,omitempty"`
+}
+
+// ErrInvalidGenerationOptions is returned when generation options are out of range
+var ErrInvalidGenerationOptions = errors.New("invalid generation options")
+
+// maxStopSequences is the maximum number of stop sequences accepted by the model
+const maxStopSequences = 5
+
+// SafetySetting sets the blocking threshold of a harm category, e.g.
+// HARM_CATEGORY_HARASSMENT with BLOCK_ONLY_HIGH
+type SafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+// GenerationOptions controls how answers are generated by the model.
+// Unset fields use the defaults of the model.
+type GenerationOptions struct {
+	Temperature       *float64        `json:"temperature,omitempty"`
+	TopP              *float64        `json:"top_p,omitempty"`
+	TopK              *int            `json:"top_k,omitempty"`
+	MaxOutputTokens   *int            `json:"max_output_tokens,omitempty"`
+	StopSequences     []string        `json:"stop_sequences,omitempty"`
+	SystemInstruction string          `json:"system_instruction,omitempty"`
+	SafetySettings    []SafetySetting `json:"safety_settings,omitempty"`
+}
+
+// Merge returns a copy of the options in which every field set in override
+// replaces the corresponding field
+func (o GenerationOptions) Merge(override *GenerationOptions) GenerationOptions {
+	if override == nil {
+		return o
+	}
+
+	merged := o
+	if override.Temperature != nil {
+		merged.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		merged.TopP = override.TopP
+	}
+	if override.TopK != nil {
+		merged.TopK = override.TopK
+	}
+	if override.MaxOutputTokens != nil {
+		merged.MaxOutputTokens = override.MaxOutputTokens
+	}
+	if override.StopSequences != nil {
+		merged.StopSequences = override.StopSequences
+	}
+	if override.SystemInstruction != "" {
+		merged.SystemInstruction = override.SystemInstruction
+	}
+	if override.SafetySettings != nil {
+		merged.SafetySettings = override.SafetySettings
+	}
+
+	return merged
+}
+
+// Validate checks that the options are within the ranges accepted by the model
+func (o GenerationOptions) Validate() error {
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > 2) {
+		return fmt.Errorf("%w: temperature must be between 0 and 2", ErrInvalidGenerationOptions)
+	}
+	if o.TopP != nil && (*o.TopP < 0 || *o.TopP > 1) {
+		return fmt.Errorf("%w: top_p must be between 0 and 1", ErrInvalidGenerationOptions)
+	}
+	if o.TopK != nil && *o.TopK < 1 {
+		return fmt.Errorf("%w: top_k must be positive", ErrInvalidGenerationOptions)
+	}
+	if o.MaxOutputTokens != nil && *o.MaxOutputTokens < 1 {
+		return fmt.Errorf("%w: max_output_tokens must be positive", ErrInvalidGenerationOptions)
+	}
+	if len(o.StopSequences) > maxStopSequences {
+		return fmt.Errorf("%w: at most %d stop sequences are allowed", ErrInvalidGenerationOptions, maxStopSequences)
+	}
+	for _, setting := range o.SafetySettings {
+		if setting.Category == "" || setting.Threshold == "" {
+			return fmt.Errorf("%w: safety settings need a category and a threshold", ErrInvalidGenerationOptions)
+		}
+	}
+
+	return nil
+}
+
+// GroundednessMethod selects how answer sentences are checked against the context
+type GroundednessMethod string
+
+const (
+	// GroundednessNone skips the check
+	GroundednessNone GroundednessMethod = "none"
+	// GroundednessLLM asks the model which sentences the context supports
+	GroundednessLLM GroundednessMethod = "llm"
+	// GroundednessEmbedding compares sentence embeddings with the context sentences
+	GroundednessEmbedding GroundednessMethod = "embedding"
+)
+
+// GroundednessAction selects what happens to answers below the threshold
+type GroundednessAction string
+
+const (
+	// GroundednessFlag returns the answer marked as low confidence
+	GroundednessFlag GroundednessAction = "flag"
+	// GroundednessRefuse replaces the answer with a refusal
+	GroundednessRefuse GroundednessAction = "refuse"
+)
+
+// ErrInvalidGroundednessOptions is returned when groundedness options are invalid
+var ErrInvalidGroundednessOptions = errors.New("invalid groundedness options")
+
+// GroundednessOptions configure the verification of answers against the retrieved context
+type GroundednessOptions struct {
+	Method GroundednessMethod `json:"method,omitempty"`
+	// Threshold is the fraction of supported sentences an answer needs
+	Threshold *float64           `json:"threshold,omitempty"`
+	Action    GroundednessAction `json:"action,omitempty"`
+}
+
+// Merge returns the options with the fields set in override replacing their values
+func (o GroundednessOptions) Merge(override *GroundednessOptions) GroundednessOptions {
+	if override == nil {
+		return o
+	}
+
+	merged := o
+	if override.Method != "" {
+		merged.Method = override.Method
+	}
+	if override.Threshold != nil {
+		merged.Threshold = override.Threshold
+	}
+	if override.Action != "" {
+		merged.Action = override.Action
+	}
+	return merged
+}
+
+// Validate checks that the method and action are known and the threshold is in range
+func (o GroundednessOptions) Validate() error {
+	switch o.Method {
+	case "", GroundednessNone, GroundednessLLM, GroundednessEmbedding:
+	default:
+		return fmt.Errorf("%w: unknown method %q", ErrInvalidGroundednessOptions, o.Method)
+	}
+	switch o.Action {
+	case "", GroundednessFlag, GroundednessRefuse:
+	default:
+		return fmt.Errorf("%w: unknown action %q", ErrInvalidGroundednessOptions, o.Action)
+	}
+	if o.Threshold != nil && (*o.Threshold < 0 || *o.Threshold > 1) {
+		return fmt.Errorf("%w: threshold must be between 0 and 1", ErrInvalidGroundednessOptions)
+	}
+	return nil
+}
+
+// Enabled reports whether answers are checked
+func (o GroundednessOptions) Enabled() bool {
+	return o.Method != "" && o.Method != GroundednessNone
+}
+
+// Citation ties a statement of a RAG answer to the source document that supports it
+type Citation struct {
+	// SourceLabel is the label of the document in the prompt, e.g. "S1"
+	SourceLabel string    `json:"source_label"`
+	DocumentID  uuid.UUID `json:"document_id"`
+	FileName    string    `json:"file_name,omitempty"`
+	ChunkIndex  int       `json:"chunk_index"`
+	Page        *int      `json:"page,omitempty"`
+	Similarity  float32   `json:"similarity"`
+	// Quote is a verbatim span of the document supporting the statement
+	Quote string `json:"quote,omitempty"`
+}
+
+// ContextStatus describes how a retrieved document was packed into the prompt context
+type ContextStatus string
+
+const (
+	// ContextIncluded means the document was included as a whole
+	ContextIncluded ContextStatus = "included"
+	// ContextTruncated means the document was cut to fit the token budget
+	ContextTruncated ContextStatus = "truncated"
+	// ContextDropped means the document did not fit the token budget
+	ContextDropped ContextStatus = "dropped"
+)
+
+// ContextDocument reports how a single retrieved document was packed
+type ContextDocument struct {
+	DocumentID     uuid.UUID     `json:"document_id"`
+	Status         ContextStatus `json:"status"`
+	Similarity     float32       `json:"similarity"`
+	Tokens         int           `json:"tokens"`
+	OriginalTokens int           `json:"original_tokens"`
+}
+
+// ContextReport reports how retrieved documents were fitted into the context token budget
+type ContextReport struct {
+	// Budget is the number of tokens available for the whole prompt; zero means unlimited
+	Budget int `json:"budget"`
+	// PromptTokens is the number of tokens of the prompt besides the documents
+	PromptTokens int `json:"prompt_tokens"`
+	// UsedTokens is the number of tokens of the packed documents
+	UsedTokens int               `json:"used_tokens"`
+	Documents  []ContextDocument `json:"documents"`
 }
 
 // RAGResponse represents the response from the RAG system
 type RAGResponse struct {
 	Answer    string      `json:"answer"`
 	Documents []Document  `json:"documents,omitempty"`
+	Citations []Citation  `json:"citations,omitempty"`
 	Metadata  interface{} `json:"metadata,omitempty"`
 }
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -144,6 +146,48 @@ func TestSearchResultStructure(t *testing.T) {
 	}
 }
 
+// TestSortByRelevance tests that results are ordered by a single score scale
+func TestSortByRelevance(t *testing.T) {
+	score := func(v float32) *float32 { return &v }
+	order := func(results []SearchResult) string {
+		var contents []string
+		for _, result := range results {
+			contents = append(contents, result.Document.Content)
+		}
+		return strings.Join(contents, ",")
+	}
+
+	// Fusion scores rank the results even though they are much smaller than similarities
+	fused := []SearchResult{
+		{Document: Document{Content: "a"}, Similarity: 0.9, Score: 0.016},
+		{Document: Document{Content: "b"}, Similarity: 0.5, Score: 0.032},
+	}
+	SortByRelevance(fused)
+	if got := order(fused); got != "b,a" {
+		t.Errorf("Expected order b,a by fusion score, got %s", got)
+	}
+
+	// Without a fusion score for every result, similarity ranks them
+	mixed := []SearchResult{
+		{Document: Document{Content: "a"}, Similarity: 0.5, Score: 0.016},
+		{Document: Document{Content: "b"}, Similarity: 0.9},
+	}
+	SortByRelevance(mixed)
+	if got := order(mixed); got != "b,a" {
+		t.Errorf("Expected order b,a by similarity, got %s", got)
+	}
+
+	// Rerank scores take precedence when every result has one
+	reranked := []SearchResult{
+		{Document: Document{Content: "a"}, Similarity: 0.9, Score: 0.032, RerankScore: score(0.1)},
+		{Document: Document{Content: "b"}, Similarity: 0.5, Score: 0.016, RerankScore: score(0.8)},
+	}
+	SortByRelevance(reranked)
+	if got := order(reranked); got != "b,a" {
+		t.Errorf("Expected order b,a by rerank score, got %s", got)
+	}
+}
+
 func TestRAGQueryStructure(t *testing.T) {
 	// Create a RAGQuery
 	query := RAGQuery{
@@ -206,3 +250,180 @@ func TestRAGResponseStructure(t *testing.T) {
 		}
 	}
 }
+
+func TestNewSource(t *testing.T) {
+	metadata := map[string]interface{}{"key": "value"}
+	source := NewSource("/data/file.txt", "file.txt", "abc123", metadata)
+
+	if source.ID == uuid.Nil {
+		t.Error("Expected non-nil UUID")
+	}
+
+	if source.URI != "/data/file.txt" || source.Title != "file.txt" || source.Checksum != "abc123" {
+		t.Errorf("Unexpected source fields: %+v", source)
+	}
+
+	if source.Metadata["key"] != "value" {
+		t.Errorf("Expected metadata[key] = value, got %v", source.Metadata["key"])
+	}
+
+	if !source.CreatedAt.Equal(source.UpdatedAt) {
+		t.Errorf("CreatedAt and UpdatedAt should be equal for new source")
+	}
+}
+
+func TestNewSourceDocument(t *testing.T) {
+	sourceID := uuid.New()
+	doc := NewSourceDocument(sourceID, 3, "chunk content", nil)
+
+	if doc.SourceID == nil || *doc.SourceID != sourceID {
+		t.Errorf("Expected source ID %s, got %v", sourceID, doc.SourceID)
+	}
+
+	if doc.Position != 3 {
+		t.Errorf("Expected position 3, got %d", doc.Position)
+	}
+
+	if doc.Content != "chunk content" {
+		t.Errorf("Expected content 'chunk content', got '%s'", doc.Content)
+	}
+
+	// Documents created directly have no source
+	if standalone := NewDocument("content", nil); standalone.SourceID != nil {
+		t.Errorf("Expected no source ID for standalone document, got %v", standalone.SourceID)
+	}
+}
+
+func TestGenerationOptionsMerge(t *testing.T) {
+	temperature, lowTemperature, topK := 0.7, 0.0, 40
+	global := GenerationOptions{
+		Temperature:       &temperature,
+		TopK:              &topK,
+		SystemInstruction: "Be helpful.",
+	}
+
+	// Without an override the global options are used
+	if merged := global.Merge(nil); merged.Temperature != &temperature || merged.SystemInstruction != "Be helpful." {
+		t.Errorf("Expected global options, got %+v", merged)
+	}
+
+	merged := global.Merge(&GenerationOptions{
+		Temperature:   &lowTemperature,
+		StopSequences: []string{"END"},
+	})
+
+	if merged.Temperature == nil || *merged.Temperature != 0 {
+		t.Errorf("Expected overridden temperature 0, got %v", merged.Temperature)
+	}
+	if merged.TopK == nil || *merged.TopK != 40 {
+		t.Errorf("Expected global top_k 40, got %v", merged.TopK)
+	}
+	if len(merged.StopSequences) != 1 || merged.SystemInstruction != "Be helpful." {
+		t.Errorf("Unexpected merged options %+v", merged)
+	}
+
+	// The global options are not modified
+	if *global.Temperature != 0.7 || global.StopSequences != nil {
+		t.Errorf("Expected global options to be unchanged, got %+v", global)
+	}
+}
+
+func TestGenerationOptionsValidate(t *testing.T) {
+	floatPtr := func(f float64) *float64 { return &f }
+	intPtr := func(n int) *int { return &n }
+
+	tests := []struct {
+		name    string
+		options GenerationOptions
+		valid   bool
+	}{
+		{name: "Empty", options: GenerationOptions{}, valid: true},
+		{name: "Deterministic", options: GenerationOptions{Temperature: floatPtr(0), TopK: intPtr(1)}, valid: true},
+		{name: "Temperature too high", options: GenerationOptions{Temperature: floatPtr(2.5)}},
+		{name: "Negative top_p", options: GenerationOptions{TopP: floatPtr(-0.1)}},
+		{name: "Zero top_k", options: GenerationOptions{TopK: intPtr(0)}},
+		{name: "Zero max output tokens", options: GenerationOptions{MaxOutputTokens: intPtr(0)}},
+		{name: "Too many stop sequences", options: GenerationOptions{StopSequences: []string{"a", "b", "c", "d", "e", "f"}}},
+		{name: "Incomplete safety setting", options: GenerationOptions{SafetySettings: []SafetySetting{{Category: "HARM_CATEGORY_HARASSMENT"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidGenerationOptions) {
+				t.Errorf("Expected ErrInvalidGenerationOptions, got %v", err)
+			}
+		})
+	}
+}
+
+// TestRetrievalOptionsValidate tests the validation of retrieval options
+func TestRetrievalOptionsValidate(t *testing.T) {
+	floatPtr := func(f float64) *float64 { return &f }
+
+	tests := []struct {
+		name    string
+		options RetrievalOptions
+		valid   bool
+	}{
+		{name: "Empty", options: RetrievalOptions{}, valid: true},
+		{name: "HyDE", options: RetrievalOptions{Mode: RetrievalHyDE}, valid: true},
+		{name: "MMR", options: RetrievalOptions{MMR: true, MMRLambda: floatPtr(0), MMRCandidates: 50}, valid: true},
+		{name: "Unknown mode", options: RetrievalOptions{Mode: "keyword"}},
+		{name: "Lambda too high", options: RetrievalOptions{MMR: true, MMRLambda: floatPtr(1.5)}},
+		{name: "Too many candidates", options: RetrievalOptions{MMR: true, MMRCandidates: MaxMMRCandidates + 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidRetrievalOptions) {
+				t.Errorf("Expected ErrInvalidRetrievalOptions, got %v", err)
+			}
+		})
+	}
+}
+
+// TestRetrievalOptionsPoolSize tests the size of the MMR candidate pool
+func TestRetrievalOptionsPoolSize(t *testing.T) {
+	if size := (RetrievalOptions{}).PoolSize(5); size != 5*DefaultMMRPoolFactor {
+		t.Errorf("Expected the default pool of %d, got %d", 5*DefaultMMRPoolFactor, size)
+	}
+	if size := (RetrievalOptions{}).PoolSize(100); size != MaxMMRCandidates {
+		t.Errorf("Expected the pool to be capped at %d, got %d", MaxMMRCandidates, size)
+	}
+	if size := (RetrievalOptions{MMRCandidates: 3}).PoolSize(5); size != 5 {
+		t.Errorf("Expected the pool to hold at least the limit, got %d", size)
+	}
+}
+
+// TestGroundednessOptions tests merging and validating groundedness options
+func TestGroundednessOptions(t *testing.T) {
+	threshold := 0.5
+	defaults := GroundednessOptions{Method: GroundednessEmbedding, Action: GroundednessFlag}
+
+	merged := defaults.Merge(&GroundednessOptions{Action: GroundednessRefuse, Threshold: &threshold})
+	if merged.Method != GroundednessEmbedding || merged.Action != GroundednessRefuse || *merged.Threshold != 0.5 {
+		t.Errorf("Unexpected merged options %+v", merged)
+	}
+	if !merged.Enabled() || (GroundednessOptions{Method: GroundednessNone}).Enabled() {
+		t.Errorf("Expected only a method other than none to enable the check")
+	}
+
+	invalid := []GroundednessOptions{
+		{Method: "regex"},
+		{Action: "ignore"},
+		{Threshold: func() *float64 { f := 1.5; return &f }()},
+	}
+	for _, options := range invalid {
+		if err := options.Validate(); !errors.Is(err, ErrInvalidGroundednessOptions) {
+			t.Errorf("Expected ErrInvalidGroundednessOptions for %+v, got %v", options, err)
+		}
+	}
+}
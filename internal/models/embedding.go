@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// States of an embedding model in the registry of stored embeddings
+const (
+	// EmbeddingModelIndexing marks a model whose embeddings are being generated
+	EmbeddingModelIndexing = "indexing"
+	// EmbeddingModelActive marks the model queries are answered with
+	EmbeddingModelActive = "active"
+	// EmbeddingModelRetired marks a model replaced by another model
+	EmbeddingModelRetired = "retired"
+)
+
+// EmbeddingModel identifies the model that generated stored embeddings.
+// Embeddings are only comparable with embeddings of the same model.
+type EmbeddingModel struct {
+	Name string `json:"name"`
+	// Version distinguishes revisions of a model published under the same name
+	Version    string `json:"version,omitempty"`
+	Dimensions int    `json:"dimensions"`
+}
+
+// String returns the name of the model, followed by @ and its version if it has one
+func (m EmbeddingModel) String() string {
+	if m.Version == "" {
+		return m.Name
+	}
+	return m.Name + "@" + m.Version
+}
+
+// EmbeddingModelStatus describes a registered embedding model and the
+// progress of indexing the documents with it
+type EmbeddingModelStatus struct {
+	EmbeddingModel
+	State            string     `json:"state"`
+	IndexedDocuments int        `json:"indexed_documents"`
+	TotalDocuments   int        `json:"total_documents"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	ActivatedAt      *time.Time `json:"activated_at,omitempty"`
+}
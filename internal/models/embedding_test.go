@@ -0,0 +1,13 @@
+package models
+
+import "testing"
+
+// TestEmbeddingModelString tests naming embedding models with and without versions
+func TestEmbeddingModelString(t *testing.T) {
+	if name := (EmbeddingModel{Name: "embedding-001", Dimensions: 768}).String(); name != "embedding-001" {
+		t.Errorf("Expected embedding-001, got %s", name)
+	}
+	if name := (EmbeddingModel{Name: "nomic-embed-text", Version: "v1.5"}).String(); name != "nomic-embed-text@v1.5" {
+		t.Errorf("Expected nomic-embed-text@v1.5, got %s", name)
+	}
+}
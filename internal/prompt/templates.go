@@ -0,0 +1,225 @@
+package prompt
+
+/*
+This file defines the prompt templates used to build the RAG prompt.
+
+Key responsibilities:
+- Load prompt templates written in Go text/template syntax from files
+- Validate templates before they are used
+- Select templates per collection or per request by name
+*/
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// DefaultTemplateName is the name of the built-in template
+const DefaultTemplateName = "default"
+
+// templateExtension is the file extension of prompt template files
+const templateExtension = ".tmpl"
+
+// ErrTemplateNotFound is returned when a template name is not registered
+var ErrTemplateNotFound = errors.New("prompt template not found")
+
+// defaultTemplate reproduces the built-in prompt: labeled context documents
+// followed by the citation instructions and the query. Without documents the
+// query is used as is.
+const defaultTemplate = `{{- if .Documents -}}
+Context information is below. Each source is labeled with an ID in square brackets.
+---------------------
+{{range .Documents}}[{{.Label}}]{{if .Description}} ({{.Description}}){{end}}:
+{{.Content}}
+
+{{end -}}
+---------------------
+Cite the sources that support each statement by their IDs in square brackets, for example [S1] or [S1, S2]. To quote a source, add the verbatim text after the ID, for example [S1: "quoted text"]. Only cite the sources listed above.
+Given the context information and not prior knowledge, answer the following query:
+{{.Query}}
+{{- else -}}
+{{.Query}}
+{{- end -}}`
+
+// Document is a context document as seen by a template
+type Document struct {
+	// Label is the stable ID used to cite the document, e.g. "S1"
+	Label string
+	// Description is a short description of where the document comes from
+	Description string
+	Content     string
+	Metadata    map[string]interface{}
+	Similarity  float32
+}
+
+// Message is a previous turn of the conversation as seen by a template
+type Message struct {
+	Role    string
+	Content string
+}
+
+// Data contains the variables available to a template
+type Data struct {
+	Query     string
+	Documents []Document
+	// Metadata contains request-level values such as the collection name
+	Metadata map[string]interface{}
+	// History holds the previous turns of a chat, which are also sent to the
+	// model as multi-turn contents
+	History []Message
+}
+
+// Templates is a validated set of named prompt templates
+type Templates struct {
+	templates   map[string]*template.Template
+	defaultName string
+	collections map[string]string
+}
+
+// NewTemplates creates a template set that only contains the built-in default template
+func NewTemplates() *Templates {
+	return &Templates{
+		templates: map[string]*template.Template{
+			DefaultTemplateName: template.Must(template.New(DefaultTemplateName).Parse(defaultTemplate)),
+		},
+		defaultName: DefaultTemplateName,
+		collections: make(map[string]string),
+	}
+}
+
+// LoadTemplates loads every *.tmpl file of dir as a template named after the
+// file, in addition to the built-in default template. A file named
+// default.tmpl replaces the built-in default. Every template is validated by
+// rendering it with sample data.
+func LoadTemplates(dir string) (*Templates, error) {
+	t := NewTemplates()
+	if dir == "" {
+		return t, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+templateExtension))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompt templates: %w", err)
+	}
+
+	for _, path := range paths {
+		text, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt template %s: %w", path, err)
+		}
+
+		name := strings.TrimSuffix(filepath.Base(path), templateExtension)
+		if err := t.Add(name, string(text)); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// Add parses and validates a template and registers it under name
+func (t *Templates) Add(name, text string) error {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return fmt.Errorf("failed to parse prompt template %q: %w", name, err)
+	}
+
+	// Render with sample data so that references to unknown fields fail now
+	if err := tmpl.Execute(io.Discard, sampleData()); err != nil {
+		return fmt.Errorf("invalid prompt template %q: %w", name, err)
+	}
+
+	t.templates[name] = tmpl
+	return nil
+}
+
+// SetDefault sets the template used when neither the request nor its collection selects one
+func (t *Templates) SetDefault(name string) error {
+	if _, ok := t.templates[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+
+	t.defaultName = name
+	return nil
+}
+
+// SetCollectionTemplate selects the template used for queries of a collection
+func (t *Templates) SetCollectionTemplate(collection, name string) error {
+	if _, ok := t.templates[name]; !ok {
+		return fmt.Errorf("%w: %s (collection %s)", ErrTemplateNotFound, name, collection)
+	}
+
+	t.collections[collection] = name
+	return nil
+}
+
+// Names returns the names of all registered templates in alphabetical order
+func (t *Templates) Names() []string {
+	names := make([]string, 0, len(t.templates))
+	for name := range t.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns the name of the template to use. An explicit name takes
+// precedence over the template of the collection, which takes precedence over
+// the default template.
+func (t *Templates) Resolve(name, collection string) (string, error) {
+	if name == "" {
+		name = t.collections[collection]
+	}
+	if name == "" {
+		name = t.defaultName
+	}
+
+	if _, ok := t.templates[name]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+
+	return name, nil
+}
+
+// Render renders the named template with data
+func (t *Templates) Render(name string, data Data) (string, error) {
+	tmpl, ok := t.templates[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt template %q: %w", name, err)
+	}
+
+	return buf.String(), nil
+}
+
+// sampleData returns data that exercises every variable available to templates
+func sampleData() Data {
+	return Data{
+		Query: "What is the answer?",
+		Documents: []Document{
+			{
+				Label:       "S1",
+				Description: "file: sample.md, chunk: 0",
+				Content:     "Sample content.",
+				Metadata:    map[string]interface{}{"file_name": "sample.md"},
+				Similarity:  0.9,
+			},
+		},
+		Metadata: map[string]interface{}{"collection": "sample"},
+		History: []Message{
+			{Role: "user", Content: "Previous question"},
+			{Role: "model", Content: "Previous answer"},
+		},
+	}
+}
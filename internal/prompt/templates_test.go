@@ -0,0 +1,156 @@
+package prompt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestDefaultTemplate tests that the default template renders the built-in prompt
+func TestDefaultTemplate(t *testing.T) {
+	templates := NewTemplates()
+
+	data := Data{
+		Query: "test query",
+		Documents: []Document{
+			{Label: "S1", Description: "file: a.md, chunk: 0", Content: "First content"},
+			{Label: "S2", Content: "Second content"},
+		},
+	}
+
+	result, err := templates.Render(DefaultTemplateName, data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := "Context information is below. Each source is labeled with an ID in square brackets.\n" +
+		"---------------------\n" +
+		"[S1] (file: a.md, chunk: 0):\nFirst content\n\n" +
+		"[S2]:\nSecond content\n\n" +
+		"---------------------\n" +
+		"Cite the sources that support each statement by their IDs in square brackets, " +
+		"for example [S1] or [S1, S2]. To quote a source, add the verbatim text after the ID, " +
+		"for example [S1: \"quoted text\"]. Only cite the sources listed above.\n" +
+		"Given the context information and not prior knowledge, answer the following query:\n" +
+		"test query"
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+
+	// Without documents the query is used as is
+	result, _ = templates.Render(DefaultTemplateName, Data{Query: "test query"})
+	if result != "test query" {
+		t.Errorf("Expected the query as is, got %q", result)
+	}
+}
+
+// TestLoadTemplates tests loading templates from a directory
+func TestLoadTemplates(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "short.tmpl", "{{.Query}} ({{len .Documents}} sources, {{len .History}} turns)")
+	writeTemplate(t, dir, "notes.txt", "not a template")
+
+	templates, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	names := templates.Names()
+	if len(names) != 2 || names[0] != "default" || names[1] != "short" {
+		t.Errorf("Expected templates [default short], got %v", names)
+	}
+
+	result, err := templates.Render("short", Data{Query: "q", Documents: []Document{{Label: "S1"}}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result != "q (1 sources, 0 turns)" {
+		t.Errorf("Unexpected rendered template: %q", result)
+	}
+
+	// A missing directory only provides the default template
+	templates, err = LoadTemplates(filepath.Join(dir, "missing"))
+	if err != nil || len(templates.Names()) != 1 {
+		t.Errorf("Expected only the default template, got %v (%v)", templates, err)
+	}
+}
+
+// TestLoadTemplatesValidation tests that invalid templates are rejected at load time
+func TestLoadTemplatesValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{name: "Syntax error", text: "{{if .Query}}unterminated"},
+		{name: "Unknown variable", text: "{{.Question}}"},
+		{name: "Unknown document field", text: "{{range .Documents}}{{.Title}}{{end}}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTemplate(t, dir, "broken.tmpl", tt.text)
+
+			if _, err := LoadTemplates(dir); err == nil {
+				t.Error("Expected error for invalid template, got nil")
+			}
+		})
+	}
+}
+
+// TestResolve tests selecting templates per request and per collection
+func TestResolve(t *testing.T) {
+	templates := NewTemplates()
+	for _, name := range []string{"concise", "technical"} {
+		if err := templates.Add(name, "{{.Query}}"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if err := templates.SetCollectionTemplate("code", "technical"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		template   string
+		collection string
+		expected   string
+	}{
+		{name: "Default", expected: "default"},
+		{name: "Collection", collection: "code", expected: "technical"},
+		{name: "Unknown collection", collection: "docs", expected: "default"},
+		{name: "Request overrides collection", template: "concise", collection: "code", expected: "concise"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, err := templates.Resolve(tt.template, tt.collection)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if name != tt.expected {
+				t.Errorf("Expected template %s, got %s", tt.expected, name)
+			}
+		})
+	}
+
+	// Unknown names are reported with ErrTemplateNotFound
+	if _, err := templates.Resolve("missing", ""); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("Expected ErrTemplateNotFound, got %v", err)
+	}
+	if err := templates.SetDefault("missing"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("Expected ErrTemplateNotFound, got %v", err)
+	}
+	if err := templates.SetCollectionTemplate("docs", "missing"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("Expected ErrTemplateNotFound, got %v", err)
+	}
+}
+
+// writeTemplate writes a template file into dir
+func writeTemplate(t *testing.T, dir, name, text string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+}
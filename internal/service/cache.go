@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/yourusername/go-rag/internal/config"
+	"github.com/yourusername/go-rag/internal/embeddings"
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// CacheStatus reports whether an answer was served from the answer cache
+type CacheStatus struct {
+	Hit bool `json:"hit"`
+	// Similarity, CachedQuery and Age describe the cached answer on hits
+	Similarity  float32 `json:"similarity,omitempty"`
+	CachedQuery string  `json:"cached_query,omitempty"`
+	AgeSeconds  int64   `json:"age_seconds,omitempty"`
+}
+
+// AnswerCache keeps the answers of previous queries in memory and reuses them
+// for identical or paraphrased queries with the same options, as long as the
+// corpus they were generated from has not changed
+type AnswerCache struct {
+	mu         sync.Mutex
+	entries    []*cacheEntry
+	threshold  float32
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+}
+
+// cacheEntry is a cached answer
+type cacheEntry struct {
+	query string
+	// variant identifies the options the answer was generated with
+	variant   string
+	embedding []float32
+	response  models.RAGResponse
+	version   int64
+	createdAt time.Time
+}
+
+// NewAnswerCache creates the answer cache configured by cfg, or returns nil
+// when the cache is disabled
+func NewAnswerCache(cfg config.AnswerCacheConfig) *AnswerCache {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &AnswerCache{
+		threshold:  float32(cfg.Threshold),
+		ttl:        cfg.TTL,
+		maxEntries: cfg.MaxEntries,
+		now:        time.Now,
+	}
+}
+
+// lookup returns the entry of the most similar cached query with the same
+// variant and corpus version, and its similarity. Expired entries and entries
+// of another corpus version are dropped along the way.
+func (c *AnswerCache) lookup(
+	embedding []float32,
+	variant string,
+	version int64,
+	similarity func(vec1, vec2 []float32) float32,
+) (*cacheEntry, float32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	var best *cacheEntry
+	var bestSimilarity float32
+	kept := c.entries[:0]
+	for _, entry := range c.entries {
+		if entry.version != version || (c.ttl > 0 && now.Sub(entry.createdAt) > c.ttl) {
+			continue
+		}
+		kept = append(kept, entry)
+
+		if entry.variant != variant {
+			continue
+		}
+		if sim := similarity(embedding, entry.embedding); sim >= c.threshold && sim > bestSimilarity {
+			best, bestSimilarity = entry, sim
+		}
+	}
+	for i := len(kept); i < len(c.entries); i++ {
+		c.entries[i] = nil
+	}
+	c.entries = kept
+
+	return best, bestSimilarity
+}
+
+// store adds an entry, replacing the entry of the same query and variant and
+// evicting the oldest entries beyond the capacity
+func (c *AnswerCache) store(entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, existing := range c.entries {
+		if existing.query == entry.query && existing.variant == entry.variant {
+			c.entries = append(c.entries[:i], c.entries[i+1:]...)
+			break
+		}
+	}
+	c.entries = append(c.entries, entry)
+	if overflow := len(c.entries) - c.maxEntries; overflow > 0 {
+		c.entries = append([]*cacheEntry(nil), c.entries[overflow:]...)
+	}
+}
+
+// invalidate drops all cached answers
+func (c *AnswerCache) invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
+
+// cacheVariant identifies the options of a request that affect its answer
+func cacheVariant(request models.RAGQuery) (string, error) {
+	request.Query = ""
+	request.NoCache = false
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// cachedAnswer answers a query from the answer cache when a similar query was
+// answered with the same options for the current corpus, and caches the
+// answer otherwise. Failures of the cache itself only bypass it.
+func (s *DefaultRAGService) cachedAnswer(ctx context.Context, request models.RAGQuery) (*models.RAGResponse, error) {
+	variant, err := cacheVariant(request)
+	if err != nil {
+		log.Printf("Failed to build answer cache key, bypassing the cache: %v", err)
+		return s.uncachedAnswer(ctx, request)
+	}
+	version, err := s.db.CorpusVersion(ctx)
+	if err != nil {
+		log.Printf("Failed to get corpus version, bypassing the answer cache: %v", err)
+		return s.uncachedAnswer(ctx, request)
+	}
+	// Queries are embedded as for retrieval, so that an embedding cache serves
+	// the retrieval stage on misses
+	embedding, err := s.embeddingService.GenerateEmbedding(ctx, request.Query, embeddings.Options{Purpose: embeddings.PurposeQuery})
+	if err != nil {
+		log.Printf("Failed to embed query, bypassing the answer cache: %v", err)
+		return s.uncachedAnswer(ctx, request)
+	}
+
+	if entry, similarity := s.cache.lookup(embedding, variant, version, s.embeddingService.CalculateSimilarity); entry != nil {
+		return withCacheStatus(entry.response, &CacheStatus{
+			Hit:         true,
+			Similarity:  similarity,
+			CachedQuery: entry.query,
+			AgeSeconds:  int64(s.cache.now().Sub(entry.createdAt) / time.Second),
+		}), nil
+	}
+
+	response, _, err := s.answer(ctx, request, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Answers are cached with the version read before retrieval, so that a
+	// write during generation invalidates them
+	s.cache.store(&cacheEntry{
+		query:     request.Query,
+		variant:   variant,
+		embedding: embedding,
+		response:  *withCacheStatus(*response, nil),
+		version:   version,
+		createdAt: s.cache.now(),
+	})
+
+	return withCacheStatus(*response, &CacheStatus{Hit: false}), nil
+}
+
+// uncachedAnswer answers a query without the answer cache
+func (s *DefaultRAGService) uncachedAnswer(ctx context.Context, request models.RAGQuery) (*models.RAGResponse, error) {
+	response, _, err := s.answer(ctx, request, nil)
+	return response, err
+}
+
+// withCacheStatus returns a copy of the response whose metadata reports the
+// cache status, or has none when status is nil, so that cached responses are
+// never modified by callers
+func withCacheStatus(response models.RAGResponse, status *CacheStatus) *models.RAGResponse {
+	metadata := make(map[string]interface{})
+	if existing, ok := response.Metadata.(map[string]interface{}); ok {
+		for key, value := range existing {
+			metadata[key] = value
+		}
+	}
+	delete(metadata, "cache")
+	if status != nil {
+		metadata["cache"] = status
+	}
+	response.Metadata = metadata
+	return &response
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yourusername/go-rag/internal/config"
+	"github.com/yourusername/go-rag/internal/embeddings"
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// cacheTestEmbeddings maps queries to embeddings; paraphrases point in almost
+// the same direction
+var cacheTestEmbeddings = map[string][]float32{
+	"How do refunds work?":        {1, 0, 0},
+	"How do refunds work":         {0.99, 0.14, 0},
+	"What is the shipping price?": {0, 1, 0},
+}
+
+// newCacheTestService creates a service with an answer cache that counts the
+// generated answers
+func newCacheTestService(t *testing.T, version *int64, generated *int) (*DefaultRAGService, *AnswerCache) {
+	t.Helper()
+
+	mockDB := &MockVectorDB{
+		FindSimilarFunc: func(ctx context.Context, query models.VectorQuery) ([]models.SearchResult, error) {
+			return []models.SearchResult{{Document: models.NewDocument("Refunds take 5 days.", nil), Similarity: 0.9}}, nil
+		},
+		CorpusVersionFunc: func(ctx context.Context) (int64, error) {
+			return *version, nil
+		},
+		StoreDocumentFunc: func(ctx context.Context, doc models.Document, embedding []float32) error {
+			return nil
+		},
+		DeleteSourceFunc: func(ctx context.Context, id uuid.UUID) error {
+			return nil
+		},
+	}
+	mockEmbedding := &MockEmbeddingService{
+		GenerateEmbeddingFunc: func(ctx context.Context, text string, options embeddings.Options) ([]float32, error) {
+			if embedding, ok := cacheTestEmbeddings[text]; ok {
+				return embedding, nil
+			}
+			return []float32{0, 0, 1}, nil
+		},
+		CalculateSimilarityFunc: func(vec1, vec2 []float32) float32 {
+			return vec1[0]*vec2[0] + vec1[1]*vec2[1] + vec1[2]*vec2[2]
+		},
+	}
+	generator := &MockGenerator{
+		GenerateFunc: func(ctx context.Context, history []models.ChatMessage, prompt string, options models.GenerationOptions) (string, error) {
+			*generated++
+			return "Refunds take 5 days [S1].", nil
+		},
+	}
+
+	cache := NewAnswerCache(config.AnswerCacheConfig{Enabled: true, Threshold: 0.95, TTL: time.Hour, MaxEntries: 10})
+	mockConfig := &config.GeminiConfig{APIKey: "test-api-key", TextModel: "test-model"}
+	ragService, err := NewRAGService(mockDB, mockEmbedding, mockConfig, WithGenerator(generator), WithAnswerCache(cache))
+	if err != nil {
+		t.Fatalf("Failed to create RAG service: %v", err)
+	}
+	return ragService.(*DefaultRAGService), cache
+}
+
+// cacheStatus returns the cache status of a response
+func cacheStatus(t *testing.T, response *models.RAGResponse) *CacheStatus {
+	t.Helper()
+	status, ok := response.Metadata.(map[string]interface{})["cache"].(*CacheStatus)
+	if !ok {
+		t.Fatalf("Expected a cache status, got %+v", response.Metadata)
+	}
+	return status
+}
+
+// TestAnswerCache tests reusing answers for paraphrased queries
+func TestAnswerCache(t *testing.T) {
+	var version int64
+	var generated int
+	ragService, _ := newCacheTestService(t, &version, &generated)
+	ctx := context.Background()
+
+	response, err := ragService.QueryWithOptions(ctx, models.RAGQuery{Query: "How do refunds work?"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if status := cacheStatus(t, response); status.Hit {
+		t.Errorf("Expected a cache miss for the first query")
+	}
+
+	// A paraphrase with the same options is served from the cache
+	response, err = ragService.QueryWithOptions(ctx, models.RAGQuery{Query: "How do refunds work"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	status := cacheStatus(t, response)
+	if !status.Hit || status.CachedQuery != "How do refunds work?" || status.Similarity < 0.95 {
+		t.Errorf("Expected a cache hit for the paraphrase, got %+v", status)
+	}
+	if response.Answer != "Refunds take 5 days [S1]." || len(response.Citations) != 1 {
+		t.Errorf("Expected the cached answer with its citations, got %+v", response)
+	}
+	if generated != 1 {
+		t.Errorf("Expected 1 generated answer, got %d", generated)
+	}
+
+	// Unrelated queries, other options, explained and uncached queries are generated
+	requests := []models.RAGQuery{
+		{Query: "What is the shipping price?"},
+		{Query: "How do refunds work?", Limit: 3},
+		{Query: "How do refunds work?", Explain: true},
+		{Query: "How do refunds work?", NoCache: true},
+	}
+	for _, request := range requests {
+		if _, err := ragService.QueryWithOptions(ctx, request); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if generated != 5 {
+		t.Errorf("Expected 5 generated answers, got %d", generated)
+	}
+}
+
+// TestAnswerCacheInvalidation tests that answers of an older corpus are not reused
+func TestAnswerCacheInvalidation(t *testing.T) {
+	var version int64
+	var generated int
+	ragService, cache := newCacheTestService(t, &version, &generated)
+	ctx := context.Background()
+	query := models.RAGQuery{Query: "How do refunds work?"}
+
+	ask := func() bool {
+		t.Helper()
+		response, err := ragService.QueryWithOptions(ctx, query)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return cacheStatus(t, response).Hit
+	}
+
+	ask()
+
+	// Writes by other processes change the corpus version
+	version++
+	if ask() {
+		t.Errorf("Expected a miss after the corpus version changed")
+	}
+
+	// Writes through the service drop the cache
+	if _, err := ragService.AddDocument(ctx, "Refunds take 3 days.", nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if ask() {
+		t.Errorf("Expected a miss after a document was added")
+	}
+	if err := ragService.DeleteSource(ctx, uuid.New()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if ask() {
+		t.Errorf("Expected a miss after a source was deleted")
+	}
+
+	// Entries expire after the TTL
+	now := time.Now()
+	cache.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if ask() {
+		t.Errorf("Expected a miss after the TTL")
+	}
+	if !ask() {
+		t.Errorf("Expected a hit for the refreshed entry")
+	}
+	if generated != 5 {
+		t.Errorf("Expected 5 generated answers, got %d", generated)
+	}
+}
+
+// TestAnswerCacheBypass tests that cache failures only bypass the cache
+func TestAnswerCacheBypass(t *testing.T) {
+	var version int64
+	var generated int
+	ragService, _ := newCacheTestService(t, &version, &generated)
+	ragService.db.(*MockVectorDB).CorpusVersionFunc = func(ctx context.Context) (int64, error) {
+		return 0, errors.New("connection lost")
+	}
+
+	for i := 0; i < 2; i++ {
+		response, err := ragService.QueryWithOptions(context.Background(), models.RAGQuery{Query: "How do refunds work?"})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if _, ok := response.Metadata.(map[string]interface{})["cache"]; ok {
+			t.Errorf("Expected no cache status when the cache is bypassed")
+		}
+	}
+	if generated != 2 {
+		t.Errorf("Expected 2 generated answers, got %d", generated)
+	}
+}
+
+// TestAnswerCacheEviction tests that the oldest entries are evicted first
+func TestAnswerCacheEviction(t *testing.T) {
+	cache := NewAnswerCache(config.AnswerCacheConfig{Enabled: true, Threshold: 0.9, MaxEntries: 2})
+	similarity := func(vec1, vec2 []float32) float32 { return vec1[0]*vec2[0] + vec1[1]*vec2[1] }
+
+	cache.store(&cacheEntry{query: "a", embedding: []float32{1, 0}, createdAt: time.Now()})
+	cache.store(&cacheEntry{query: "b", embedding: []float32{0, 1}, createdAt: time.Now()})
+	cache.store(&cacheEntry{query: "a", embedding: []float32{1, 0}, createdAt: time.Now()})
+	cache.store(&cacheEntry{query: "c", embedding: []float32{0.7, 0.7}, createdAt: time.Now()})
+
+	if entry, _ := cache.lookup([]float32{0, 1}, "", 0, similarity); entry != nil {
+		t.Errorf("Expected the oldest entry to be evicted, got %q", entry.query)
+	}
+	if entry, _ := cache.lookup([]float32{1, 0}, "", 0, similarity); entry == nil || entry.query != "a" {
+		t.Errorf("Expected the refreshed entry to be kept, got %+v", entry)
+	}
+
+	if NewAnswerCache(config.AnswerCacheConfig{}) != nil {
+		t.Errorf("Expected no cache when it is disabled")
+	}
+}
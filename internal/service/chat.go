@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// maxHistoryMessages is the number of previous messages used to continue a conversation
+const maxHistoryMessages = 10
+
+// maxSessionTitleLength is the length of the first message used as session title
+const maxSessionTitleLength = 80
+
+// condensePrompt asks the model to rewrite a follow-up question so that it can
+// be understood, and retrieved for, without the conversation
+const condensePrompt = `Given the following conversation and a follow-up question, rephrase the follow-up question to be a standalone question in its original language.
+Keep every detail needed to understand it without the conversation. If it is already standalone, return it unchanged.
+Only return the standalone question.
+
+Conversation:
+%s
+Follow-up question: %s
+Standalone question:`
+
+// Chat answers a message of a chat session. Follow-up questions are condensed
+// into standalone queries using the history before retrieval, the history is
+// sent to Gemini as multi-turn contents, and both turns are stored with the
+// sources of the answer.
+func (s *DefaultRAGService) Chat(ctx context.Context, request models.ChatRequest) (*models.ChatResponse, error) {
+	if s.conversations == nil {
+		return nil, fmt.Errorf("conversation store is not configured")
+	}
+
+	message := strings.TrimSpace(request.Message)
+	if message == "" {
+		return nil, fmt.Errorf("message cannot be empty")
+	}
+
+	// Continue the given session or start a new one, which is only stored
+	// together with its first turn
+	var session *models.ChatSession
+	var sessionID uuid.UUID
+	var history []models.ChatMessage
+	if request.SessionID != nil {
+		sessionID = *request.SessionID
+		if _, err := s.conversations.GetSession(ctx, sessionID); err != nil {
+			return nil, fmt.Errorf("failed to get chat session: %w", err)
+		}
+
+		var err error
+		history, err = s.conversations.ListMessages(ctx, sessionID, maxHistoryMessages)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load chat history: %w", err)
+		}
+	} else {
+		newSession := models.NewChatSession(sessionTitle(message), nil)
+		session = &newSession
+		sessionID = newSession.ID
+	}
+
+	// Rewrite the follow-up into a standalone query for retrieval
+	standaloneQuery := s.condenseQuery(ctx, history, message)
+
+	query := request.Options
+	query.Query = standaloneQuery
+	response, results, err := s.answer(ctx, query, history)
+	if err != nil {
+		return nil, err
+	}
+
+	// Store both turns of the conversation at once, so that the roles of the
+	// history keep alternating
+	userMessage := models.NewChatMessage(sessionID, models.ChatRoleUser, message)
+	userMessage.StandaloneQuery = standaloneQuery
+
+	modelMessage := models.NewChatMessage(sessionID, models.ChatRoleModel, response.Answer)
+	modelMessage.Citations = response.Citations
+	for _, result := range results {
+		modelMessage.Sources = append(modelMessage.Sources, models.ChatSource{
+			DocumentID: result.Document.ID,
+			SourceID:   result.Document.SourceID,
+			Position:   result.Document.Position,
+			Similarity: result.Similarity,
+		})
+	}
+	if err := s.conversations.StoreTurn(ctx, session, userMessage, modelMessage); err != nil {
+		return nil, fmt.Errorf("failed to store chat messages: %w", err)
+	}
+
+	return &models.ChatResponse{
+		SessionID:       sessionID,
+		StandaloneQuery: standaloneQuery,
+		RAGResponse:     *response,
+	}, nil
+}
+
+// GetChatHistory returns all messages of a chat session in chronological order
+func (s *DefaultRAGService) GetChatHistory(ctx context.Context, sessionID uuid.UUID) ([]models.ChatMessage, error) {
+	if s.conversations == nil {
+		return nil, fmt.Errorf("conversation store is not configured")
+	}
+
+	// Make sure the session exists so that an unknown ID is reported as such
+	if _, err := s.conversations.GetSession(ctx, sessionID); err != nil {
+		return nil, fmt.Errorf("failed to get chat session: %w", err)
+	}
+
+	messages, err := s.conversations.ListMessages(ctx, sessionID, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chat messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+// DeleteChatSession deletes a chat session together with its messages
+func (s *DefaultRAGService) DeleteChatSession(ctx context.Context, sessionID uuid.UUID) error {
+	if s.conversations == nil {
+		return fmt.Errorf("conversation store is not configured")
+	}
+
+	if err := s.conversations.DeleteSession(ctx, sessionID); err != nil {
+		return fmt.Errorf("failed to delete chat session: %w", err)
+	}
+
+	return nil
+}
+
+// condenseQuery rewrites a follow-up message into a standalone query using the
+// conversation history. The message is used as is when there is no history or
+// the rewrite fails.
+func (s *DefaultRAGService) condenseQuery(ctx context.Context, history []models.ChatMessage, message string) string {
+	if len(history) == 0 {
+		return message
+	}
+
+	var conversation strings.Builder
+	for _, turn := range history {
+		role := "User"
+		if turn.Role == models.ChatRoleModel {
+			role = "Assistant"
+		}
+		conversation.WriteString(fmt.Sprintf("%s: %s\n", role, turn.Content))
+	}
+
+	// Rewriting should be deterministic and not follow the answer instructions
+	temperature := 0.0
+	options := models.GenerationOptions{
+		Temperature:    &temperature,
+		SafetySettings: s.geminiConfig.Generation.SafetySettings,
+	}
+
+	condensed, err := s.generateResponseWithGemini(ctx, fmt.Sprintf(condensePrompt, conversation.String(), message), options)
+	if err != nil {
+		log.Printf("Failed to condense follow-up question, using it as is: %v", err)
+		return message
+	}
+
+	condensed = strings.TrimSpace(condensed)
+	if condensed == "" {
+		return message
+	}
+
+	return condensed
+}
+
+// sessionTitle derives the title of a new session from its first message
+func sessionTitle(message string) string {
+	runes := []rune(strings.Join(strings.Fields(message), " "))
+	if len(runes) <= maxSessionTitleLength {
+		return string(runes)
+	}
+	return string(runes[:maxSessionTitleLength-3]) + "..."
+}
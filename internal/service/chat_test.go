@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/yourusername/go-rag/internal/config"
+	"github.com/yourusername/go-rag/internal/database"
+	"github.com/yourusername/go-rag/internal/embeddings"
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// MockConversationStore is an in-memory implementation of the ConversationStore interface
+type MockConversationStore struct {
+	sessions map[uuid.UUID]models.ChatSession
+	messages []models.ChatMessage
+}
+
+// NewMockConversationStore creates an empty in-memory conversation store
+func NewMockConversationStore() *MockConversationStore {
+	return &MockConversationStore{sessions: make(map[uuid.UUID]models.ChatSession)}
+}
+
+func (m *MockConversationStore) CreateSession(ctx context.Context, session models.ChatSession) error {
+	m.sessions[session.ID] = session
+	return nil
+}
+
+func (m *MockConversationStore) GetSession(ctx context.Context, id uuid.UUID) (models.ChatSession, error) {
+	session, ok := m.sessions[id]
+	if !ok {
+		return models.ChatSession{}, database.ErrSessionNotFound
+	}
+	return session, nil
+}
+
+func (m *MockConversationStore) DeleteSession(ctx context.Context, id uuid.UUID) error {
+	if _, ok := m.sessions[id]; !ok {
+		return database.ErrSessionNotFound
+	}
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *MockConversationStore) StoreMessage(ctx context.Context, message models.ChatMessage) error {
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+func (m *MockConversationStore) StoreTurn(ctx context.Context, session *models.ChatSession, messages ...models.ChatMessage) error {
+	if session != nil {
+		m.sessions[session.ID] = *session
+	}
+	m.messages = append(m.messages, messages...)
+	return nil
+}
+
+func (m *MockConversationStore) ListMessages(ctx context.Context, sessionID uuid.UUID, limit int) ([]models.ChatMessage, error) {
+	var messages []models.ChatMessage
+	for _, message := range m.messages {
+		if message.SessionID == sessionID {
+			messages = append(messages, message)
+		}
+	}
+	if limit > 0 && len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	return messages, nil
+}
+
+// newGeminiTestServer starts a fake Gemini API that answers every generation
+// request with the text returned by respond
+func newGeminiTestServer(t *testing.T, respond func(request GeminiGenerationRequest) string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		var request GeminiGenerationRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			t.Errorf("Failed to unmarshal generation request: %v", err)
+		}
+
+		var response GeminiGenerationResponse
+		response.Candidates = make([]struct {
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+		}, 1)
+		response.Candidates[0].Content.Parts = []struct {
+			Text string `json:"text"`
+		}{{Text: respond(request)}}
+
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// newTestGeminiGenerator creates a Gemini generator that sends its requests to baseURL
+func newTestGeminiGenerator(cfg *config.GeminiConfig, baseURL string) *GeminiGenerator {
+	generator := NewGeminiGenerator(cfg)
+	generator.baseURL = baseURL
+	return generator
+}
+
+// TestChattests continuing a conversation with a follow-up question
+func TestChat(t *testing.T) {
+	doc := models.NewDocument("On Linux, extract the tarball into /usr/local.", map[string]interface{}{"file_name": "install.md"})
+
+	var embeddedQueries []string
+	mockEmbedding := &MockEmbeddingService{
+		GenerateEmbeddingFunc: func(ctx context.Context, text string, options embeddings.Options) ([]float32, error) {
+			embeddedQueries = append(embeddedQueries, text)
+			return []float32{0.1, 0.2}, nil
+		},
+	}
+	mockDB := &MockVectorDB{
+		FindSimilarFunc: func(ctx context.Context, query models.VectorQuery) ([]models.SearchResult, error) {
+			return []models.SearchResult{{Document: doc, Similarity: 0.8}}, nil
+		},
+	}
+
+	var answerRequest GeminiGenerationRequest
+	server := newGeminiTestServer(t, func(request GeminiGenerationRequest) string {
+		text := request.Contents[len(request.Contents)-1].Parts[0].Text
+		if strings.Contains(text, "Standalone question:") {
+			return "How do I install Go on Linux?"
+		}
+		answerRequest = request
+		return "Extract the tarball into /usr/local [S1]."
+	})
+
+	store := NewMockConversationStore()
+	mockConfig := &config.GeminiConfig{APIKey: "test-api-key", TextModel: "test-model"}
+	ragService, _ := NewRAGService(mockDB, mockEmbedding, mockConfig, WithConversationStore(store))
+	ragService.(*DefaultRAGService).generator = newTestGeminiGenerator(mockConfig, server.URL)
+
+	// Start a session with a previous turn
+	session := models.NewChatSession("Installing Go", nil)
+	store.CreateSession(context.Background(), session)
+	store.StoreMessage(context.Background(), models.NewChatMessage(session.ID, models.ChatRoleUser, "How do I install Go on macOS?"))
+	store.StoreMessage(context.Background(), models.NewChatMessage(session.ID, models.ChatRoleModel, "Use the package installer."))
+
+	response, err := ragService.Chat(context.Background(), models.ChatRequest{
+		SessionID: &session.ID,
+		Message:   "and for Linux?",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Retrieval uses the condensed question
+	if len(embeddedQueries) != 1 || embeddedQueries[0] != "How do I install Go on Linux?" {
+		t.Errorf("Expected the standalone query to be embedded, got %v", embeddedQueries)
+	}
+	if response.StandaloneQuery != "How do I install Go on Linux?" || response.SessionID != session.ID {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	// The history is sent as multi-turn contents
+	roles := make([]string, 0, len(answerRequest.Contents))
+	for _, content := range answerRequest.Contents {
+		roles = append(roles, content.Role)
+	}
+	if strings.Join(roles, ",") != "user,model,user" {
+		t.Errorf("Expected roles user,model,user, got %v", roles)
+	}
+
+	// Both turns are stored with the sources of the answer
+	if len(store.messages) != 4 {
+		t.Fatalf("Expected 4 stored messages, got %d", len(store.messages))
+	}
+	userMessage, modelMessage := store.messages[2], store.messages[3]
+	if userMessage.Content != "and for Linux?" || userMessage.StandaloneQuery != "How do I install Go on Linux?" {
+		t.Errorf("Unexpected user message: %+v", userMessage)
+	}
+	if modelMessage.Role != models.ChatRoleModel || len(modelMessage.Sources) != 1 || modelMessage.Sources[0].DocumentID != doc.ID {
+		t.Errorf("Unexpected model message: %+v", modelMessage)
+	}
+	if len(modelMessage.Citations) != 1 || modelMessage.Citations[0].DocumentID != doc.ID {
+		t.Errorf("Expected citation of the document, got %+v", modelMessage.Citations)
+	}
+}
+
+// TestChatNewSession tests starting a new conversation
+func TestChatNewSession(t *testing.T) {
+	mockEmbedding := &MockEmbeddingService{
+		GenerateEmbeddingFunc: func(ctx context.Context, text string, options embeddings.Options) ([]float32, error) {
+			return []float32{0.1, 0.2}, nil
+		},
+	}
+	mockDB := &MockVectorDB{
+		FindSimilarFunc: func(ctx context.Context, query models.VectorQuery) ([]models.SearchResult, error) {
+			return nil, nil
+		},
+	}
+
+	generations := 0
+	server := newGeminiTestServer(t, func(request GeminiGenerationRequest) string {
+		generations++
+		if request.Contents[0].Role != "" {
+			t.Errorf("Expected single-turn contents without role, got %q", request.Contents[0].Role)
+		}
+		return "Hello!"
+	})
+
+	store := NewMockConversationStore()
+	mockConfig := &config.GeminiConfig{APIKey: "test-api-key", TextModel: "test-model"}
+	ragService, _ := NewRAGService(mockDB, mockEmbedding, mockConfig, WithConversationStore(store))
+	ragService.(*DefaultRAGService).generator = newTestGeminiGenerator(mockConfig, server.URL)
+
+	response, err := ragService.Chat(context.Background(), models.ChatRequest{Message: "Hi there"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Nothing to condense without history
+	if generations != 1 || response.StandaloneQuery != "Hi there" {
+		t.Errorf("Expected a single generation with the message as query, got %d (%q)", generations, response.StandaloneQuery)
+	}
+
+	session, err := store.GetSession(context.Background(), response.SessionID)
+	if err != nil || session.Title != "Hi there" {
+		t.Errorf("Expected a new session titled after the message, got %+v (%v)", session, err)
+	}
+
+	if len(store.messages) != 2 {
+		t.Errorf("Expected both messages of the first turn to be stored, got %d", len(store.messages))
+	}
+
+	// Unknown sessions are reported with ErrSessionNotFound
+	unknown := uuid.New()
+	_, err = ragService.Chat(context.Background(), models.ChatRequest{SessionID: &unknown, Message: "Hi"})
+	if !errors.Is(err, database.ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+// TestChatFailedFirstTurn tests that a failed first turn does not leave an empty session
+func TestChatFailedFirstTurn(t *testing.T) {
+	mockEmbedding := &MockEmbeddingService{
+		GenerateEmbeddingFunc: func(ctx context.Context, text string, options embeddings.Options) ([]float32, error) {
+			return nil, errors.New("quota exceeded")
+		},
+	}
+
+	store := NewMockConversationStore()
+	mockConfig := &config.GeminiConfig{APIKey: "test-api-key", TextModel: "test-model"}
+	ragService, _ := NewRAGService(&MockVectorDB{}, mockEmbedding, mockConfig, WithConversationStore(store))
+
+	if _, err := ragService.Chat(context.Background(), models.ChatRequest{Message: "Hi there"}); err == nil {
+		t.Fatal("Expected error when the answer fails")
+	}
+	if len(store.sessions) != 0 || len(store.messages) != 0 {
+		t.Errorf("Expected nothing to be stored, got %d sessions and %d messages", len(store.sessions), len(store.messages))
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// citationPattern matches citation markers such as [S1], [S1, S2] and [S1: "quoted text"]
+var citationPattern = regexp.MustCompile(`\[(S\d+(?:\s*,\s*S\d+)*)(?::\s*["“]([^"”\]]+)["”])?\]`)
+
+// sourceLabel returns the stable label of the document at index i of the prompt context
+func sourceLabel(i int) string {
+	return fmt.Sprintf("S%d", i+1)
+}
+
+// describeSource returns a short description of where a document comes from,
+// used next to its label in the prompt
+func describeSource(doc models.Document) string {
+	var parts []string
+	if fileName, ok := doc.Metadata["file_name"].(string); ok && fileName != "" {
+		parts = append(parts, "file: "+fileName)
+	}
+	if chunkIndex, ok := documentChunkIndex(doc); ok {
+		parts = append(parts, fmt.Sprintf("chunk: %d", chunkIndex))
+	}
+	if page, ok := metadataInt(doc.Metadata, "page"); ok {
+		parts = append(parts, fmt.Sprintf("page: %d", page))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// parseCitations extracts the citation markers of an answer and resolves them
+// against the documents the answer was generated from. Markers that refer to
+// unknown sources are ignored, and quotes that do not occur in the cited
+// document are dropped so that every returned citation can be verified.
+func parseCitations(answer string, results []models.SearchResult) []models.Citation {
+	var citations []models.Citation
+	seen := make(map[string]bool)
+
+	for _, match := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		quote := strings.TrimSpace(match[2])
+
+		for _, label := range strings.Split(match[1], ",") {
+			label = strings.TrimSpace(label)
+			index, err := strconv.Atoi(strings.TrimPrefix(label, "S"))
+			if err != nil || index < 1 || index > len(results) {
+				continue
+			}
+			result := results[index-1]
+
+			// Only keep quotes that can be found in the cited document
+			citationQuote := quote
+			if citationQuote != "" && !containsQuote(result.Document.Content, citationQuote) {
+				citationQuote = ""
+			}
+
+			key := label + "\x00" + citationQuote
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			citations = append(citations, newCitation(label, result, citationQuote))
+		}
+	}
+
+	return citations
+}
+
+// newCitation creates a citation of a search result
+func newCitation(label string, result models.SearchResult, quote string) models.Citation {
+	doc := result.Document
+	citation := models.Citation{
+		SourceLabel: label,
+		DocumentID:  doc.ID,
+		Similarity:  result.Similarity,
+		Quote:       quote,
+	}
+
+	citation.FileName, _ = doc.Metadata["file_name"].(string)
+	citation.ChunkIndex, _ = documentChunkIndex(doc)
+	if page, ok := metadataInt(doc.Metadata, "page"); ok {
+		citation.Page = &page
+	}
+
+	return citation
+}
+
+// documentChunkIndex returns the index of a document within its source
+func documentChunkIndex(doc models.Document) (int, bool) {
+	if doc.SourceID != nil {
+		return doc.Position, true
+	}
+	return metadataInt(doc.Metadata, "chunk_index")
+}
+
+// metadataInt reads an integer metadata value, which is a float64 when the
+// metadata was decoded from JSON
+func metadataInt(metadata map[string]interface{}, key string) (int, bool) {
+	switch v := metadata[key].(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case string:
+		n, err := strconv.Atoi(v)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// containsQuote reports whether quote occurs in content, ignoring case and
+// differences in whitespace
+func containsQuote(content, quote string) bool {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.Join(strings.Fields(s), " "))
+	}
+	return strings.Contains(normalize(content), normalize(quote))
+}
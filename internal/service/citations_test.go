@@ -0,0 +1,81 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// TestParseCitations tests resolving citation markers of an answer
+func TestParseCitations(t *testing.T) {
+	sourceID := uuid.New()
+	first := models.NewSourceDocument(sourceID, 3, "Go has built-in concurrency with goroutines.", map[string]interface{}{
+		"file_name": "go.md",
+		"page":      float64(2),
+	})
+	second := models.NewDocument("Rust guarantees memory safety.", map[string]interface{}{
+		"chunk_index": float64(1),
+	})
+	results := []models.SearchResult{
+		{Document: first, Similarity: 0.9},
+		{Document: second, Similarity: 0.7},
+	}
+
+	answer := `Go supports concurrency [S1: "built-in   concurrency with goroutines"]. ` +
+		`Rust is memory safe [S2, S1]. Python is popular [S7]. Go is old [S1: "invented in 1950"].`
+
+	citations := parseCitations(answer, results)
+
+	// [S7] is unknown and the invalid quote collapses into the unquoted [S1]
+	if len(citations) != 3 {
+		t.Fatalf("Expected 3 citations, got %d: %+v", len(citations), citations)
+	}
+
+	quoted := citations[0]
+	if quoted.SourceLabel != "S1" || quoted.DocumentID != first.ID {
+		t.Errorf("Expected first citation to refer to S1, got %+v", quoted)
+	}
+	if quoted.Quote != "built-in   concurrency with goroutines" {
+		t.Errorf("Expected quote to be kept, got %q", quoted.Quote)
+	}
+	if quoted.FileName != "go.md" || quoted.ChunkIndex != 3 || quoted.Similarity != 0.9 {
+		t.Errorf("Expected source details of S1, got %+v", quoted)
+	}
+	if quoted.Page == nil || *quoted.Page != 2 {
+		t.Errorf("Expected page 2, got %v", quoted.Page)
+	}
+
+	if citations[1].SourceLabel != "S2" || citations[1].ChunkIndex != 1 || citations[1].Page != nil {
+		t.Errorf("Expected second citation to refer to S2, got %+v", citations[1])
+	}
+
+	if citations[2].SourceLabel != "S1" || citations[2].Quote != "" {
+		t.Errorf("Expected unquoted citation of S1, got %+v", citations[2])
+	}
+
+	// Answers without markers have no citations
+	if citations := parseCitations("No sources here.", results); len(citations) != 0 {
+		t.Errorf("Expected no citations, got %d", len(citations))
+	}
+}
+
+// TestAugmentQueryWithSourceLabels tests labeling sources in the prompt
+func TestAugmentQueryWithSourceLabels(t *testing.T) {
+	service := &DefaultRAGService{}
+
+	docs := []models.Document{
+		models.NewDocument("First content", map[string]interface{}{"file_name": "a.md", "chunk_index": 0}),
+		models.NewDocument("Second content", nil),
+	}
+
+	augmented := service.augmentQueryWithContext("test query", docs)
+
+	for _, expected := range []string{"[S1] (file: a.md, chunk: 0):\nFirst content", "[S2]:\nSecond content", "[S1: \"quoted text\"]"} {
+		if !strings.Contains(augmented, expected) {
+			t.Errorf("Expected prompt to contain %q, got %s", expected, augmented)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// EchoData is the data the echo generator renders its template with
+type EchoData struct {
+	Prompt            string
+	History           []models.ChatMessage
+	SystemInstruction string
+}
+
+// EchoGenerator implements Generator without a language model by rendering a
+// template with the prompt, so that the system runs offline in development
+// and tests. The default template echoes the prompt.
+type EchoGenerator struct {
+	template *template.Template
+}
+
+// NewEchoGenerator creates a new echo generator rendering the given Go
+// text/template, e.g. "{{.Prompt}}"
+func NewEchoGenerator(text string) (*EchoGenerator, error) {
+	tmpl, err := template.New("echo").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse echo template: %w", err)
+	}
+	return &EchoGenerator{template: tmpl}, nil
+}
+
+// Generate renders the template with the prompt and the conversation history
+func (g *EchoGenerator) Generate(
+	ctx context.Context,
+	history []models.ChatMessage,
+	prompt string,
+	options models.GenerationOptions,
+) (string, error) {
+	var answer strings.Builder
+	data := EchoData{
+		Prompt:            prompt,
+		History:           history,
+		SystemInstruction: options.SystemInstruction,
+	}
+	if err := g.template.Execute(&answer, data); err != nil {
+		return "", fmt.Errorf("failed to render echo template: %w", err)
+	}
+	return answer.String(), nil
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// TestEchoGenerator tests rendering answers from the prompt without a model
+func TestEchoGenerator(t *testing.T) {
+	history := []models.ChatMessage{
+		{Role: models.ChatRoleUser, Content: "How do I install Go?"},
+		{Role: models.ChatRoleModel, Content: "Extract the archive."},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{name: "Echo", template: "{{.Prompt}}", expected: "Where? [S1]"},
+		{
+			name:     "Template",
+			template: "{{.SystemInstruction}}: {{len .History}} turns, last {{(index .History 1).Content}} {{.Prompt}}",
+			expected: "Be brief: 2 turns, last Extract the archive. Where? [S1]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, err := NewEchoGenerator(tt.template)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			answer, err := generator.Generate(context.Background(), history, "Where? [S1]",
+				models.GenerationOptions{SystemInstruction: "Be brief"})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if answer != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, answer)
+			}
+		})
+	}
+
+	if _, err := NewEchoGenerator("{{.Prompt"); err == nil {
+		t.Error("Expected an error for an invalid template")
+	}
+
+	// Execution errors are returned
+	generator, _ := NewEchoGenerator("{{index .History 5}}")
+	if _, err := generator.Generate(context.Background(), nil, "prompt", models.GenerationOptions{}); err == nil {
+		t.Error("Expected an error for a failing template")
+	}
+}
@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// Explanation describes how an answer was produced so that bad answers can be
+// debugged without reproducing them by hand
+type Explanation struct {
+	// QueryEmbeddings describes the vectors searched with, one per searched query
+	QueryEmbeddings []QueryEmbeddingInfo `json:"query_embeddings"`
+	// Candidates are all retrieved documents in retrieval order
+	Candidates []ExplainedCandidate `json:"candidates"`
+	// Prompt is the exact prompt sent to the model for the answer
+	Prompt string `json:"prompt"`
+	// RawResponse is the unparsed model response of the answer
+	RawResponse string      `json:"raw_response,omitempty"`
+	Usage       *TokenUsage `json:"usage,omitempty"`
+	// ModelCalls lists every model call of the request, including query
+	// rewriting and reranking
+	ModelCalls []ModelCall    `json:"model_calls"`
+	Latency    []StageLatency `json:"latency"`
+}
+
+// QueryEmbeddingInfo describes the vector a query was searched with
+type QueryEmbeddingInfo struct {
+	Query      string               `json:"query"`
+	Mode       models.RetrievalMode `json:"mode"`
+	Dimensions int                  `json:"dimensions"`
+	Norm       float64              `json:"norm"`
+}
+
+// ExplainedCandidate reports the scores of a retrieved document and whether it
+// made it into the context
+type ExplainedCandidate struct {
+	DocumentID  uuid.UUID  `json:"document_id"`
+	SourceID    *uuid.UUID `json:"source_id,omitempty"`
+	Position    int        `json:"position"`
+	Similarity  float32    `json:"similarity"`
+	Score       float32    `json:"score,omitempty"`
+	RerankScore *float32   `json:"rerank_score,omitempty"`
+	// Selected is false when the document was cut by the reranker
+	Selected bool `json:"selected"`
+	// Context is the packing status of selected documents
+	Context models.ContextStatus `json:"context,omitempty"`
+}
+
+// TokenUsage reports the tokens counted by the model for a call
+type TokenUsage struct {
+	PromptTokens   int `json:"prompt_tokens"`
+	ResponseTokens int `json:"response_tokens"`
+	TotalTokens    int `json:"total_tokens"`
+}
+
+// ModelCall records a single request to the text model
+type ModelCall struct {
+	Model       string      `json:"model"`
+	Prompt      string      `json:"prompt"`
+	RawResponse string      `json:"raw_response"`
+	Usage       *TokenUsage `json:"usage,omitempty"`
+	DurationMs  float64     `json:"duration_ms"`
+}
+
+// StageLatency reports the time spent in a stage of the pipeline
+type StageLatency struct {
+	Stage      string  `json:"stage"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// explainTrace collects details of a request from the stages that run deeper
+// in the call stack. It travels in the request context; recording on a nil
+// trace is a no-op.
+type explainTrace struct {
+	mu              sync.Mutex
+	queryEmbeddings []QueryEmbeddingInfo
+	modelCalls      []ModelCall
+}
+
+// traceKey is the context key of the explain trace
+type traceKey struct{}
+
+// withTrace returns a context carrying the trace
+func withTrace(ctx context.Context, trace *explainTrace) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// traceFrom returns the trace of the context, or nil when the request is not explained
+func traceFrom(ctx context.Context) *explainTrace {
+	trace, _ := ctx.Value(traceKey{}).(*explainTrace)
+	return trace
+}
+
+// recordQueryEmbedding records the vector a query was searched with
+func (t *explainTrace) recordQueryEmbedding(query string, mode models.RetrievalMode, embedding []float32) {
+	if t == nil {
+		return
+	}
+	if mode == "" {
+		mode = models.RetrievalVector
+	}
+
+	var sum float64
+	for _, v := range embedding {
+		sum += float64(v) * float64(v)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.queryEmbeddings = append(t.queryEmbeddings, QueryEmbeddingInfo{
+		Query:      query,
+		Mode:       mode,
+		Dimensions: len(embedding),
+		Norm:       math.Sqrt(sum),
+	})
+}
+
+// recordModelCall records a request to the text model
+func (t *explainTrace) recordModelCall(call ModelCall) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.modelCalls = append(t.modelCalls, call)
+}
+
+// modelCallCount returns the number of model calls recorded so far
+func (t *explainTrace) modelCallCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.modelCalls)
+}
+
+// explain builds the explanation of an answer. answerCall is the index of the
+// model call that generated the answer.
+func (t *explainTrace) explain(
+	candidates []models.SearchResult,
+	selected []models.SearchResult,
+	contextReport models.ContextReport,
+	prompt string,
+	answerCall int,
+	latency []StageLatency,
+) *Explanation {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	explanation := &Explanation{
+		QueryEmbeddings: append([]QueryEmbeddingInfo{}, t.queryEmbeddings...),
+		Candidates:      explainCandidates(candidates, selected, contextReport),
+		Prompt:          prompt,
+		ModelCalls:      append([]ModelCall{}, t.modelCalls...),
+		Latency:         latency,
+	}
+
+	// Generators other than Gemini do not record their calls
+	if answerCall < len(t.modelCalls) {
+		explanation.RawResponse = t.modelCalls[answerCall].RawResponse
+		explanation.Usage = t.modelCalls[answerCall].Usage
+	}
+
+	return explanation
+}
+
+// explainCandidates reports every candidate with its scores, whether it was
+// selected and its packing status
+func explainCandidates(
+	candidates []models.SearchResult,
+	selected []models.SearchResult,
+	contextReport models.ContextReport,
+) []ExplainedCandidate {
+	selectedResults := make(map[uuid.UUID]models.SearchResult, len(selected))
+	for _, result := range selected {
+		selectedResults[result.Document.ID] = result
+	}
+	statuses := make(map[uuid.UUID]models.ContextStatus, len(contextReport.Documents))
+	for _, entry := range contextReport.Documents {
+		statuses[entry.DocumentID] = entry.Status
+	}
+
+	explained := make([]ExplainedCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		entry := ExplainedCandidate{
+			DocumentID: candidate.Document.ID,
+			SourceID:   candidate.Document.SourceID,
+			Position:   candidate.Document.Position,
+			Similarity: candidate.Similarity,
+			Score:      candidate.Score,
+		}
+		if result, ok := selectedResults[candidate.Document.ID]; ok {
+			entry.Selected = true
+			entry.RerankScore = result.RerankScore
+			entry.Context = statuses[candidate.Document.ID]
+		}
+		explained = append(explained, entry)
+	}
+
+	return explained
+}
+
+// stageTimer measures the latency of consecutive pipeline stages
+type stageTimer struct {
+	start  time.Time
+	last   time.Time
+	stages []StageLatency
+}
+
+// newStageTimer starts timing the first stage
+func newStageTimer() *stageTimer {
+	now := time.Now()
+	return &stageTimer{start: now, last: now}
+}
+
+// mark ends the current stage and starts the next one
+func (t *stageTimer) mark(stage string) {
+	now := time.Now()
+	t.stages = append(t.stages, StageLatency{Stage: stage, DurationMs: durationMs(now.Sub(t.last))})
+	t.last = now
+}
+
+// latency returns the stages followed by the total time
+func (t *stageTimer) latency() []StageLatency {
+	return append(append([]StageLatency{}, t.stages...), StageLatency{
+		Stage:      "total",
+		DurationMs: durationMs(t.last.Sub(t.start)),
+	})
+}
+
+// durationMs converts a duration to fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/go-rag/internal/config"
+	"github.com/yourusername/go-rag/internal/embeddings"
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// TestQueryExplain tests that explained queries report candidates, prompt,
+// raw response, token usage and latencies
+func TestQueryExplain(t *testing.T) {
+	candidates := rerankTestResults("a", "b", "c")
+
+	mockEmbedding := &MockEmbeddingService{
+		GenerateEmbeddingFunc: func(ctx context.Context, text string, options embeddings.Options) ([]float32, error) {
+			return []float32{3, 4}, nil
+		},
+	}
+	mockDB := &MockVectorDB{
+		FindSimilarFunc: func(ctx context.Context, query models.VectorQuery) ([]models.SearchResult, error) {
+			return candidates, nil
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"The answer [S1]."}]}}],` +
+			`"usageMetadata":{"promptTokenCount":120,"candidatesTokenCount":5,"totalTokenCount":125}}`))
+	}))
+	defer server.Close()
+
+	mockConfig := &config.GeminiConfig{APIKey: "test-api-key", TextModel: "test-model"}
+	ragService, _ := NewRAGService(mockDB, mockEmbedding, mockConfig,
+		WithGenerator(newTestGeminiGenerator(mockConfig, server.URL)),
+		WithReranker(&MockReranker{}, 3),
+	)
+
+	response, err := ragService.QueryWithOptions(context.Background(), models.RAGQuery{Query: "q", Limit: 2, Explain: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	explanation, ok := response.Metadata.(map[string]interface{})["explain"].(*Explanation)
+	if !ok {
+		t.Fatalf("Expected an explanation in the metadata, got %+v", response.Metadata)
+	}
+
+	if len(explanation.QueryEmbeddings) != 1 || explanation.QueryEmbeddings[0].Norm != 5 {
+		t.Errorf("Expected the query embedding norm 5, got %+v", explanation.QueryEmbeddings)
+	}
+
+	// The mock reranker reverses the candidates and keeps the last two
+	if len(explanation.Candidates) != 3 {
+		t.Fatalf("Expected 3 candidates, got %d", len(explanation.Candidates))
+	}
+	first, last := explanation.Candidates[0], explanation.Candidates[2]
+	if first.Selected || first.Context != "" {
+		t.Errorf("Expected the first candidate to be cut by the reranker, got %+v", first)
+	}
+	if !last.Selected || last.RerankScore == nil || last.Context != models.ContextIncluded {
+		t.Errorf("Expected the last candidate to be reranked into the context, got %+v", last)
+	}
+
+	if !strings.Contains(explanation.Prompt, "c") || !strings.Contains(explanation.RawResponse, "usageMetadata") {
+		t.Errorf("Expected the prompt and raw response, got %+v", explanation)
+	}
+	if explanation.Usage == nil || explanation.Usage.TotalTokens != 125 {
+		t.Errorf("Expected the token usage, got %+v", explanation.Usage)
+	}
+
+	var stages []string
+	for _, stage := range explanation.Latency {
+		stages = append(stages, stage.Stage)
+	}
+	if strings.Join(stages, ",") != "retrieval,rerank,neighbors,packing,prompt,generation,total" {
+		t.Errorf("Unexpected stages %v", stages)
+	}
+
+	// The explanation is serializable
+	if _, err := json.Marshal(response); err != nil {
+		t.Errorf("Failed to marshal explained response: %v", err)
+	}
+
+	// Without explain mode no explanation is added
+	response, _ = ragService.QueryWithOptions(context.Background(), models.RAGQuery{Query: "q", Limit: 2})
+	if _, ok := response.Metadata.(map[string]interface{})["explain"]; ok {
+		t.Errorf("Expected no explanation without explain mode")
+	}
+}
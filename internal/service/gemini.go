@@ -0,0 +1,246 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/yourusername/go-rag/internal/config"
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// geminiAPIBaseURL is the base URL of the Gemini API
+const geminiAPIBaseURL = "https://generativelanguage.googleapis.com/v1"
+
+// GeminiGenerationRequest represents a request to the Gemini API for text generation
+type GeminiGenerationRequest struct {
+	Contents          []GeminiContent         `json:"contents"`
+	SystemInstruction *GeminiContent          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+	SafetySettings    []GeminiSafetySetting   `json:"safetySettings,omitempty"`
+}
+
+// GeminiGenerationConfig represents the generation parameters of a Gemini request
+type GeminiGenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"topP,omitempty"`
+	TopK            *int     `json:"topK,omitempty"`
+	MaxOutputTokens *int     `json:"maxOutputTokens,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+}
+
+// GeminiSafetySetting represents the blocking threshold of a harm category in a Gemini request
+type GeminiSafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+// GeminiContent represents the content part of a Gemini request
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+// GeminiPart represents a part of the content in a Gemini request
+type GeminiPart struct {
+	Text string `json:"text"`
+}
+
+// GeminiGenerationResponse represents a response from the Gemini API for text generation
+type GeminiGenerationResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+	} `json:"candidates"`
+	UsageMetadata *GeminiUsageMetadata `json:"usageMetadata,omitempty"`
+}
+
+// GeminiUsageMetadata represents the token counts of a Gemini generation
+type GeminiUsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+// Generator generates text with a language model
+type Generator interface {
+	// Generate answers the prompt, continuing the conversation in history when it is not empty
+	Generate(ctx context.Context, history []models.ChatMessage, prompt string, options models.GenerationOptions) (string, error)
+}
+
+// GeminiGenerator implements Generator using the Gemini API
+type GeminiGenerator struct {
+	config     *config.GeminiConfig
+	httpClient *http.Client
+	baseURL    string
+}
+
+// NewGeminiGenerator creates a new Gemini generator
+func NewGeminiGenerator(config *config.GeminiConfig) *GeminiGenerator {
+	return &GeminiGenerator{
+		config: config,
+		httpClient: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+		baseURL: geminiAPIBaseURL,
+	}
+}
+
+// Generate generates a response using Google's Gemini model, passing previous
+// turns as multi-turn contents
+func (g *GeminiGenerator) Generate(
+	ctx context.Context,
+	history []models.ChatMessage,
+	prompt string,
+	options models.GenerationOptions,
+) (string, error) {
+	return g.generateContent(ctx, newChatGenerationRequest(history, prompt, options))
+}
+
+// newGenerationRequest creates a Gemini generation request for the query with the given options
+func newGenerationRequest(query string, options models.GenerationOptions) GeminiGenerationRequest {
+	reqBody := GeminiGenerationRequest{
+		Contents: []GeminiContent{
+			{
+				Parts: []GeminiPart{
+					{
+						Text: query,
+					},
+				},
+			},
+		},
+	}
+
+	if options.SystemInstruction != "" {
+		reqBody.SystemInstruction = &GeminiContent{
+			Parts: []GeminiPart{{Text: options.SystemInstruction}},
+		}
+	}
+
+	// Only send a generation config when at least one parameter is set
+	generationConfig := GeminiGenerationConfig{
+		Temperature:     options.Temperature,
+		TopP:            options.TopP,
+		TopK:            options.TopK,
+		MaxOutputTokens: options.MaxOutputTokens,
+		StopSequences:   options.StopSequences,
+	}
+	if generationConfig.Temperature != nil || generationConfig.TopP != nil || generationConfig.TopK != nil ||
+		generationConfig.MaxOutputTokens != nil || len(generationConfig.StopSequences) > 0 {
+		reqBody.GenerationConfig = &generationConfig
+	}
+
+	for _, setting := range options.SafetySettings {
+		reqBody.SafetySettings = append(reqBody.SafetySettings, GeminiSafetySetting{
+			Category:  setting.Category,
+			Threshold: setting.Threshold,
+		})
+	}
+
+	return reqBody
+}
+
+// newChatGenerationRequest creates a Gemini generation request that continues
+// the conversation in history with the query
+func newChatGenerationRequest(
+	history []models.ChatMessage,
+	query string,
+	options models.GenerationOptions,
+) GeminiGenerationRequest {
+	reqBody := newGenerationRequest(query, options)
+	if len(history) == 0 {
+		return reqBody
+	}
+
+	contents := make([]GeminiContent, 0, len(history)+1)
+	for _, message := range history {
+		contents = append(contents, GeminiContent{
+			Role:  message.Role,
+			Parts: []GeminiPart{{Text: message.Content}},
+		})
+	}
+
+	// Multi-turn contents need a role on every turn
+	reqBody.Contents = append(contents, GeminiContent{
+		Role:  models.ChatRoleUser,
+		Parts: []GeminiPart{{Text: query}},
+	})
+
+	return reqBody
+}
+
+// generateContent sends a generation request to Gemini and returns the generated text
+func (g *GeminiGenerator) generateContent(ctx context.Context, reqBody GeminiGenerationRequest) (string, error) {
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	// Create HTTP request
+	url := fmt.Sprintf("%s/models/%s:generateContent?key=%s",
+		g.baseURL, g.config.TextModel, g.config.APIKey)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	// Send request
+	start := time.Now()
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Record the call when the request is explained
+	call := ModelCall{
+		Model:       g.config.TextModel,
+		Prompt:      reqBody.Contents[len(reqBody.Contents)-1].Parts[0].Text,
+		RawResponse: string(body),
+		DurationMs:  durationMs(time.Since(start)),
+	}
+	defer func() { traceFrom(ctx).recordModelCall(call) }()
+
+	// Check status code
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Gemini API error response: %s", string(body))
+		return "", fmt.Errorf("API error (status %d)", resp.StatusCode)
+	}
+
+	// Parse response
+	var genResponse GeminiGenerationResponse
+	if err := json.Unmarshal(body, &genResponse); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if usage := genResponse.UsageMetadata; usage != nil {
+		call.Usage = &TokenUsage{
+			PromptTokens:   usage.PromptTokenCount,
+			ResponseTokens: usage.CandidatesTokenCount,
+			TotalTokens:    usage.TotalTokenCount,
+		}
+	}
+
+	// Extract text from response
+	if len(genResponse.Candidates) == 0 || len(genResponse.Candidates[0].Content.Parts) == 0 {
+		return "", fmt.Errorf("no content in response")
+	}
+
+	return genResponse.Candidates[0].Content.Parts[0].Text, nil
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/yourusername/go-rag/internal/config"
+)
+
+// Generator providers selected by configuration
+const (
+	GeneratorGemini = "gemini"
+	GeneratorEcho   = "echo"
+)
+
+// NewGenerator creates the text generator selected by the configuration
+func NewGenerator(cfg *config.Config) (Generator, error) {
+	switch cfg.Generator.Provider {
+	case "", GeneratorGemini:
+		if cfg.Gemini.APIKey == "" {
+			return nil, fmt.Errorf("GEMINI_API_KEY is required for the Gemini generator")
+		}
+		return NewGeminiGenerator(&cfg.Gemini), nil
+	case GeneratorEcho:
+		return NewEchoGenerator(cfg.Generator.Template)
+	default:
+		return nil, fmt.Errorf("unknown generator provider %q", cfg.Generator.Provider)
+	}
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/yourusername/go-rag/internal/config"
+)
+
+// TestNewGenerator tests selecting the generator by configuration
+func TestNewGenerator(t *testing.T) {
+	cfg := &config.Config{Generator: config.GeneratorConfig{Template: "{{.Prompt}}"}}
+
+	// Gemini is the default and requires an API key
+	if _, err := NewGenerator(cfg); err == nil {
+		t.Error("Expected an error without a Gemini API key")
+	}
+	cfg.Gemini.APIKey = "test-api-key"
+	if generator, err := NewGenerator(cfg); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	} else if _, ok := generator.(*GeminiGenerator); !ok {
+		t.Errorf("Expected the Gemini generator, got %T", generator)
+	}
+
+	cfg.Gemini.APIKey = ""
+	cfg.Generator.Provider = GeneratorEcho
+	if generator, err := NewGenerator(cfg); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	} else if _, ok := generator.(*EchoGenerator); !ok {
+		t.Errorf("Expected the echo generator, got %T", generator)
+	}
+
+	cfg.Generator.Provider = "gpt"
+	if _, err := NewGenerator(cfg); err == nil {
+		t.Error("Expected an error for an unknown provider")
+	}
+}
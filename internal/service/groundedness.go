@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/yourusername/go-rag/internal/embeddings"
+	"github.com/yourusername/go-rag/internal/loader"
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// defaultGroundednessThreshold is the fraction of supported sentences an answer needs by default
+const defaultGroundednessThreshold = 0.8
+
+// defaultSentenceSupportThreshold is the default similarity an answer sentence
+// needs to a context sentence to be supported in embedding checks
+const defaultSentenceSupportThreshold = 0.75
+
+// defaultRefusalMessage replaces answers that are not grounded in the context
+const defaultRefusalMessage = "I cannot answer this question reliably based on the available documents."
+
+// maxContextSentences caps the number of context sentences embedded for a check
+const maxContextSentences = 64
+
+// groundednessPrompt asks the model which answer sentences the context supports
+const groundednessPrompt = `Check whether each numbered sentence of an answer is supported by the context.
+A sentence is supported only if the context states it or directly implies it.
+For every sentence, write its number followed by SUPPORTED or UNSUPPORTED on its own line.
+
+Context:
+%s
+Answer sentences:
+%s`
+
+// citedSpacePattern matches the whitespace left before punctuation once
+// citation markers are removed
+var citedSpacePattern = regexp.MustCompile(`[ \t]+([.,;:!?])`)
+
+// verdictPattern matches a sentence verdict such as "2: UNSUPPORTED"
+var verdictPattern = regexp.MustCompile(`(?im)^\s*(\d+)\s*[.):\-]*\s*(UNSUPPORTED|SUPPORTED)\b`)
+
+// GroundednessReport describes how well an answer is supported by the retrieved context
+type GroundednessReport struct {
+	Method models.GroundednessMethod `json:"method"`
+	// Score is the fraction of answer sentences supported by the context
+	Score     float64 `json:"score"`
+	Threshold float64 `json:"threshold"`
+	// LowConfidence is set when the score is below the threshold
+	LowConfidence bool `json:"low_confidence"`
+	// Refused is set when the answer was replaced with a refusal
+	Refused   bool              `json:"refused"`
+	Sentences []SentenceSupport `json:"sentences"`
+	// Error is set when the check failed; the answer then counts as unsupported
+	Error string `json:"error,omitempty"`
+}
+
+// SentenceSupport reports whether an answer sentence is supported by the context
+type SentenceSupport struct {
+	Text      string `json:"text"`
+	Supported bool   `json:"supported"`
+	// Similarity is the highest similarity to a context sentence in embedding checks
+	Similarity float32 `json:"similarity,omitempty"`
+}
+
+// checkGroundedness verifies every sentence of the answer against the
+// retrieved documents and applies the configured action when too few are
+// supported. Failed checks count as unsupported so that unverified answers
+// are never passed off as grounded.
+func (s *DefaultRAGService) checkGroundedness(
+	ctx context.Context,
+	response *models.RAGResponse,
+	results []models.SearchResult,
+	options models.GroundednessOptions,
+) *GroundednessReport {
+	report := &GroundednessReport{
+		Method:    options.Method,
+		Threshold: defaultGroundednessThreshold,
+		Sentences: []SentenceSupport{},
+	}
+	if options.Threshold != nil {
+		report.Threshold = *options.Threshold
+	}
+
+	// Citation markers are not part of the claims
+	answer := citedSpacePattern.ReplaceAllString(citationPattern.ReplaceAllString(response.Answer, ""), "$1")
+	for _, sentence := range loader.SplitSentences(answer) {
+		report.Sentences = append(report.Sentences, SentenceSupport{Text: sentence})
+	}
+
+	var err error
+	switch {
+	case len(report.Sentences) == 0:
+		// Nothing is claimed
+	case len(results) == 0:
+		// Nothing can be supported without context
+	case options.Method == models.GroundednessLLM:
+		err = s.judgeSentences(ctx, report.Sentences, results)
+	default:
+		err = s.compareSentences(ctx, report.Sentences, citedResults(response.Citations, results))
+	}
+	if err != nil {
+		log.Printf("Failed to check answer groundedness: %v", err)
+		report.Error = err.Error()
+		for i := range report.Sentences {
+			report.Sentences[i].Supported = false
+		}
+	}
+
+	supported := 0
+	for _, sentence := range report.Sentences {
+		if sentence.Supported {
+			supported++
+		}
+	}
+	report.Score = 1
+	if len(report.Sentences) > 0 {
+		report.Score = float64(supported) / float64(len(report.Sentences))
+	}
+	if report.Error != "" {
+		report.Score = 0
+	}
+
+	report.LowConfidence = report.Score < report.Threshold
+	if report.LowConfidence && options.Action == models.GroundednessRefuse {
+		response.Answer = s.refusalMessage
+		response.Citations = nil
+		report.Refused = true
+	}
+
+	return report
+}
+
+// judgeSentences asks the model which sentences the context supports.
+// Sentences without a verdict count as unsupported.
+func (s *DefaultRAGService) judgeSentences(ctx context.Context, sentences []SentenceSupport, results []models.SearchResult) error {
+	var contextText strings.Builder
+	for i, result := range results {
+		contextText.WriteString(fmt.Sprintf("[%s] %s\n\n", sourceLabel(i), result.Document.Content))
+	}
+	var numbered strings.Builder
+	for i, sentence := range sentences {
+		numbered.WriteString(fmt.Sprintf("%d. %s\n", i+1, sentence.Text))
+	}
+
+	temperature := 0.0
+	options := models.GenerationOptions{Temperature: &temperature}
+	verdicts, err := s.generator.Generate(ctx, nil, fmt.Sprintf(groundednessPrompt, contextText.String(), numbered.String()), options)
+	if err != nil {
+		return fmt.Errorf("failed to judge sentences: %w", err)
+	}
+
+	for _, match := range verdictPattern.FindAllStringSubmatch(verdicts, -1) {
+		n, err := strconv.Atoi(match[1])
+		if err != nil || n < 1 || n > len(sentences) {
+			continue
+		}
+		sentences[n-1].Supported = strings.EqualFold(match[2], "SUPPORTED")
+	}
+
+	return nil
+}
+
+// compareSentences marks the sentences whose embedding is similar enough to a
+// sentence of the context. The answer and context sentences are embedded in
+// one batch, as every sentence may cost a request.
+func (s *DefaultRAGService) compareSentences(ctx context.Context, sentences []SentenceSupport, results []models.SearchResult) error {
+	var contextSentences []string
+	for _, result := range results {
+		contextSentences = append(contextSentences, loader.SplitSentences(result.Document.Content)...)
+	}
+	if len(contextSentences) > maxContextSentences {
+		contextSentences = contextSentences[:maxContextSentences]
+	}
+	if len(contextSentences) == 0 {
+		return nil
+	}
+
+	texts := make([]string, 0, len(sentences)+len(contextSentences))
+	for _, sentence := range sentences {
+		texts = append(texts, sentence.Text)
+	}
+	texts = append(texts, contextSentences...)
+
+	options := embeddings.Options{Purpose: embeddings.PurposeSimilarity}
+	vectors, err := s.embeddingService.BatchGenerateEmbeddings(ctx, texts, options)
+	if err != nil {
+		return fmt.Errorf("failed to embed sentences: %w", err)
+	}
+	if len(vectors) != len(texts) {
+		return fmt.Errorf("expected %d sentence embeddings, got %d", len(texts), len(vectors))
+	}
+	answerEmbeddings, contextEmbeddings := vectors[:len(sentences)], vectors[len(sentences):]
+
+	for i, answerEmbedding := range answerEmbeddings {
+		var best float32
+		for _, contextEmbedding := range contextEmbeddings {
+			if similarity := s.embeddingService.CalculateSimilarity(answerEmbedding, contextEmbedding); similarity > best {
+				best = similarity
+			}
+		}
+		sentences[i].Similarity = best
+		sentences[i].Supported = float64(best) >= s.sentenceSupportThreshold
+	}
+
+	return nil
+}
+
+// citedResults returns the results cited by the answer, or every result when
+// it cites none
+func citedResults(citations []models.Citation, results []models.SearchResult) []models.SearchResult {
+	cited := make(map[uuid.UUID]bool, len(citations))
+	for _, citation := range citations {
+		cited[citation.DocumentID] = true
+	}
+
+	var filtered []models.SearchResult
+	for _, result := range results {
+		if cited[result.Document.ID] {
+			filtered = append(filtered, result)
+		}
+	}
+	if len(filtered) == 0 {
+		return results
+	}
+	return filtered
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/go-rag/internal/config"
+	"github.com/yourusername/go-rag/internal/embeddings"
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// newGroundednessTestService creates a service that retrieves a single
+// document and answers with the given text
+func newGroundednessTestService(
+	answer string,
+	verdicts func(prompt string) (string, error),
+	mockEmbedding *MockEmbeddingService,
+	cfg config.GroundednessConfig,
+) RAGService {
+	doc := models.NewDocument("Refunds are possible within 30 days. Shipping is free.", nil)
+
+	if mockEmbedding.GenerateEmbeddingFunc == nil {
+		mockEmbedding.GenerateEmbeddingFunc = func(ctx context.Context, text string, options embeddings.Options) ([]float32, error) {
+			return []float32{1, 0}, nil
+		}
+	}
+	mockDB := &MockVectorDB{
+		FindSimilarFunc: func(ctx context.Context, query models.VectorQuery) ([]models.SearchResult, error) {
+			return []models.SearchResult{{Document: doc, Similarity: 0.9}}, nil
+		},
+	}
+	generator := &MockGenerator{
+		GenerateFunc: func(ctx context.Context, history []models.ChatMessage, prompt string, options models.GenerationOptions) (string, error) {
+			if strings.Contains(prompt, "Answer sentences:") {
+				return verdicts(prompt)
+			}
+			return answer, nil
+		},
+	}
+
+	mockConfig := &config.GeminiConfig{APIKey: "test-api-key", TextModel: "test-model"}
+	ragService, _ := NewRAGService(mockDB, mockEmbedding, mockConfig, WithGenerator(generator), WithGroundedness(cfg))
+	return ragService
+}
+
+// groundednessReport returns the groundedness report of a response
+func groundednessReport(t *testing.T, response *models.RAGResponse) *GroundednessReport {
+	t.Helper()
+	report, ok := response.Metadata.(map[string]interface{})["groundedness"].(*GroundednessReport)
+	if !ok {
+		t.Fatalf("Expected a groundedness report, got %+v", response.Metadata)
+	}
+	return report
+}
+
+// TestGroundednessLLMRefusal tests refusing answers with unsupported sentences
+func TestGroundednessLLMRefusal(t *testing.T) {
+	var judgedPrompt string
+	ragService := newGroundednessTestService(
+		"Refunds are possible within 30 days [S1]. Refunds are also paid in cash.",
+		func(prompt string) (string, error) {
+			judgedPrompt = prompt
+			return "1: SUPPORTED\n2: UNSUPPORTED", nil
+		},
+		&MockEmbeddingService{},
+		config.GroundednessConfig{
+			Options:        models.GroundednessOptions{Method: models.GroundednessLLM, Action: models.GroundednessRefuse},
+			RefusalMessage: "Please contact support.",
+		},
+	)
+
+	response, err := ragService.QueryWithOptions(context.Background(), models.RAGQuery{Query: "Can I get a refund?"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Citation markers are removed from the checked sentences
+	if !strings.Contains(judgedPrompt, "1. Refunds are possible within 30 days.") {
+		t.Errorf("Expected numbered sentences without citations, got %q", judgedPrompt)
+	}
+
+	report := groundednessReport(t, response)
+	if report.Score != 0.5 || !report.LowConfidence || !report.Refused {
+		t.Errorf("Expected a refused answer with score 0.5, got %+v", report)
+	}
+	if response.Answer != "Please contact support." || response.Citations != nil {
+		t.Errorf("Expected the refusal message without citations, got %q %+v", response.Answer, response.Citations)
+	}
+}
+
+// TestGroundednessEmbeddingFlag tests flagging answers with the embedding check
+func TestGroundednessEmbeddingFlag(t *testing.T) {
+	mockEmbedding := &MockEmbeddingService{
+		BatchGenerateEmbeddingsFunc: func(ctx context.Context, texts []string, options embeddings.Options) ([][]float32, error) {
+			embeddings := make([][]float32, len(texts))
+			for i, text := range texts {
+				switch {
+				case strings.Contains(text, "cash"):
+					embeddings[i] = []float32{0, 1}
+				default:
+					embeddings[i] = []float32{1, 0}
+				}
+			}
+			return embeddings, nil
+		},
+		CalculateSimilarityFunc: func(vec1, vec2 []float32) float32 {
+			return vec1[0]*vec2[0] + vec1[1]*vec2[1]
+		},
+	}
+	ragService := newGroundednessTestService(
+		"Refunds are possible within 30 days. Refunds are also paid in cash.",
+		nil,
+		mockEmbedding,
+		config.GroundednessConfig{Options: models.GroundednessOptions{Method: models.GroundednessEmbedding}},
+	)
+
+	// The threshold of the request overrides the default
+	threshold := 0.4
+	response, err := ragService.QueryWithOptions(context.Background(), models.RAGQuery{
+		Query:        "Can I get a refund?",
+		Groundedness: &models.GroundednessOptions{Threshold: &threshold},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	report := groundednessReport(t, response)
+	if len(report.Sentences) != 2 || !report.Sentences[0].Supported || report.Sentences[1].Supported {
+		t.Errorf("Expected only the first sentence to be supported, got %+v", report.Sentences)
+	}
+	if report.Score != 0.5 || report.LowConfidence || report.Refused {
+		t.Errorf("Expected a confident answer at threshold 0.4, got %+v", report)
+	}
+
+	// With the default threshold the answer is flagged but kept
+	response, _ = ragService.QueryWithOptions(context.Background(), models.RAGQuery{Query: "Can I get a refund?"})
+	report = groundednessReport(t, response)
+	if !report.LowConfidence || report.Refused || !strings.Contains(response.Answer, "cash") {
+		t.Errorf("Expected a flagged answer, got %+v", report)
+	}
+}
+
+// TestGroundednessCheckFailure tests that failed checks count as unsupported
+func TestGroundednessCheckFailure(t *testing.T) {
+	ragService := newGroundednessTestService(
+		"Refunds are possible within 30 days.",
+		func(prompt string) (string, error) {
+			return "", errors.New("model unavailable")
+		},
+		&MockEmbeddingService{},
+		config.GroundednessConfig{Options: models.GroundednessOptions{Method: models.GroundednessLLM, Action: models.GroundednessRefuse}},
+	)
+
+	response, err := ragService.QueryWithOptions(context.Background(), models.RAGQuery{Query: "Can I get a refund?"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	report := groundednessReport(t, response)
+	if report.Error == "" || report.Score != 0 || !report.Refused || response.Answer != defaultRefusalMessage {
+		t.Errorf("Expected a refusal after the failed check, got %+v", report)
+	}
+
+	// Unknown methods are rejected
+	_, err = ragService.QueryWithOptions(context.Background(), models.RAGQuery{
+		Query:        "Can I get a refund?",
+		Groundedness: &models.GroundednessOptions{Method: "regex"},
+	})
+	if !errors.Is(err, models.ErrInvalidGroundednessOptions) {
+		t.Errorf("Expected ErrInvalidGroundednessOptions, got %v", err)
+	}
+}
+
+// TestGroundednessEmbeddingCitedContext tests that embedding checks embed the
+// answer and the sentences of the cited documents in one batch
+func TestGroundednessEmbeddingCitedContext(t *testing.T) {
+	var batches [][]string
+	mockEmbedding := &MockEmbeddingService{
+		BatchGenerateEmbeddingsFunc: func(ctx context.Context, texts []string, options embeddings.Options) ([][]float32, error) {
+			batches = append(batches, texts)
+			embeddings := make([][]float32, len(texts))
+			for i := range texts {
+				embeddings[i] = []float32{1, 0}
+			}
+			return embeddings, nil
+		},
+		CalculateSimilarityFunc: func(vec1, vec2 []float32) float32 {
+			return vec1[0]*vec2[0] + vec1[1]*vec2[1]
+		},
+	}
+	ragService := &DefaultRAGService{embeddingService: mockEmbedding, sentenceSupportThreshold: defaultSentenceSupportThreshold}
+
+	uncited := models.NewDocument("Shipping takes a week. Returns are free.", nil)
+	cited := models.NewDocument("Refunds are possible within 30 days.", nil)
+	results := []models.SearchResult{{Document: uncited}, {Document: cited}}
+	response := &models.RAGResponse{
+		Answer:    "Refunds are possible within 30 days [S2].",
+		Citations: []models.Citation{{SourceLabel: "S2", DocumentID: cited.ID}},
+	}
+
+	report := ragService.checkGroundedness(context.Background(), response, results, models.GroundednessOptions{Method: models.GroundednessEmbedding})
+
+	if len(batches) != 1 {
+		t.Fatalf("Expected one embedding batch, got %d", len(batches))
+	}
+	expected := []string{"Refunds are possible within 30 days.", "Refunds are possible within 30 days."}
+	if strings.Join(batches[0], "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected the answer and the cited sentence, got %q", batches[0])
+	}
+	if report.Score != 1 {
+		t.Errorf("Expected a supported answer, got %+v", report)
+	}
+
+	// Without citations every document is compared
+	if got := citedResults(nil, results); len(got) != 2 {
+		t.Errorf("Expected every result without citations, got %d", len(got))
+	}
+}
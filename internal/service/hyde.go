@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/yourusername/go-rag/internal/embeddings"
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// hydePrompt asks the model for a passage that would answer the question
+const hydePrompt = `Write a short passage that answers the question below, in the style of a documentation excerpt.
+If you are unsure, write a plausible answer anyway; it is only used to find relevant documents.
+
+Question: %s
+Passage:`
+
+// hypotheticalDocumentEmbedding embeds a hypothetical answer to the query
+// instead of the query itself, since answer passages are closer to the stored
+// documents than questions are. The answer is embedded as a document. With
+// includeQuery the hypothetical answer embedding is averaged with the query
+// embedding. The query embedding is used when no hypothetical answer can be
+// generated.
+func (s *DefaultRAGService) hypotheticalDocumentEmbedding(
+	ctx context.Context,
+	query string,
+	includeQuery bool,
+) ([]float32, error) {
+	options := models.GenerationOptions{SafetySettings: s.geminiConfig.Generation.SafetySettings}
+	passage, err := s.generateResponseWithGemini(ctx, fmt.Sprintf(hydePrompt, query), options)
+	if err == nil && strings.TrimSpace(passage) == "" {
+		err = fmt.Errorf("empty hypothetical answer")
+	}
+	if err != nil {
+		log.Printf("Failed to generate hypothetical answer, searching with the query: %v", err)
+		return s.embeddingService.GenerateEmbedding(ctx, query, embeddings.Options{Purpose: embeddings.PurposeQuery})
+	}
+
+	passageEmbedding, err := s.embeddingService.GenerateEmbedding(ctx, passage, embeddings.Options{Purpose: embeddings.PurposeDocument})
+	if err != nil || !includeQuery {
+		return passageEmbedding, err
+	}
+
+	queryEmbedding, err := s.embeddingService.GenerateEmbedding(ctx, query, embeddings.Options{Purpose: embeddings.PurposeQuery})
+	if err != nil {
+		return nil, err
+	}
+	if len(passageEmbedding) != len(queryEmbedding) {
+		return nil, fmt.Errorf("unexpected embeddings for hypothetical answer and query")
+	}
+
+	return averageVectors(passageEmbedding, queryEmbedding), nil
+}
+
+// averageVectors returns the element-wise mean of vectors of equal length
+func averageVectors(vectors ...[]float32) []float32 {
+	if len(vectors) == 0 {
+		return nil
+	}
+
+	mean := make([]float32, len(vectors[0]))
+	for _, vector := range vectors {
+		for i, v := range vector {
+			mean[i] += v
+		}
+	}
+	for i := range mean {
+		mean[i] /= float32(len(vectors))
+	}
+
+	return mean
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yourusername/go-rag/internal/config"
+	"github.com/yourusername/go-rag/internal/embeddings"
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// TestSearchWithHyDE tests searching with the embedding of a hypothetical answer
+func TestSearchWithHyDE(t *testing.T) {
+	const passage = "Go is installed by extracting the archive into /usr/local."
+
+	vectors := map[string][]float32{
+		passage:           {1, 0},
+		"how install go?": {0, 1},
+	}
+	purposes := make(map[string]embeddings.Purpose)
+	mockEmbedding := &MockEmbeddingService{
+		GenerateEmbeddingFunc: func(ctx context.Context, text string, options embeddings.Options) ([]float32, error) {
+			purposes[text] = options.Purpose
+			return vectors[text], nil
+		},
+	}
+
+	var searchedVector []float32
+	mockDB := &MockVectorDB{
+		FindSimilarFunc: func(ctx context.Context, query models.VectorQuery) ([]models.SearchResult, error) {
+			searchedVector = query.Vector
+			return nil, nil
+		},
+	}
+
+	server := newGeminiTestServer(t, func(request GeminiGenerationRequest) string {
+		return passage
+	})
+
+	mockConfig := &config.GeminiConfig{APIKey: "test-api-key", TextModel: "test-model"}
+	ragService, _ := NewRAGService(mockDB, mockEmbedding, mockConfig)
+	ragService.(*DefaultRAGService).generator = newTestGeminiGenerator(mockConfig, server.URL)
+
+	tests := []struct {
+		name     string
+		options  models.RetrievalOptions
+		expected []float32
+	}{
+		{name: "Query embedding", options: models.RetrievalOptions{}, expected: []float32{0, 1}},
+		{name: "Hypothetical answer", options: models.RetrievalOptions{Mode: models.RetrievalHyDE}, expected: []float32{1, 0}},
+		{
+			name:     "Averaged with query",
+			options:  models.RetrievalOptions{Mode: models.RetrievalHyDE, HyDEIncludeQuery: true},
+			expected: []float32{0.5, 0.5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ragService.SearchWithOptions(context.Background(), "how install go?", 3, tt.options); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(searchedVector) != 2 || searchedVector[0] != tt.expected[0] || searchedVector[1] != tt.expected[1] {
+				t.Errorf("Expected vector %v, got %v", tt.expected, searchedVector)
+			}
+		})
+	}
+
+	// The hypothetical answer is embedded like the stored documents
+	if purposes[passage] != embeddings.PurposeDocument || purposes["how install go?"] != embeddings.PurposeQuery {
+		t.Errorf("Expected the answer embedded as a document and the query as a query, got %v", purposes)
+	}
+
+	// Unknown modes are rejected
+	_, err := ragService.SearchWithOptions(context.Background(), "how install go?", 3, models.RetrievalOptions{Mode: "keyword"})
+	if !errors.Is(err, models.ErrInvalidRetrievalOptions) {
+		t.Errorf("Expected ErrInvalidRetrievalOptions, got %v", err)
+	}
+}
+
+// TestSearchWithHyDEFallback tests falling back to the query when generation fails
+func TestSearchWithHyDEFallback(t *testing.T) {
+	var embedded string
+	mockEmbedding := &MockEmbeddingService{
+		GenerateEmbeddingFunc: func(ctx context.Context, text string, options embeddings.Options) ([]float32, error) {
+			embedded = text
+			return []float32{0.1}, nil
+		},
+	}
+	mockDB := &MockVectorDB{
+		FindSimilarFunc: func(ctx context.Context, query models.VectorQuery) ([]models.SearchResult, error) {
+			return nil, nil
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	mockConfig := &config.GeminiConfig{APIKey: "test-api-key", TextModel: "test-model"}
+	ragService, _ := NewRAGService(mockDB, mockEmbedding, mockConfig)
+	ragService.(*DefaultRAGService).generator = newTestGeminiGenerator(mockConfig, server.URL)
+
+	_, err := ragService.SearchWithOptions(context.Background(), "how install go?", 3, models.RetrievalOptions{Mode: models.RetrievalHyDE})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if embedded != "how install go?" {
+		t.Errorf("Expected the query to be embedded, got %q", embedded)
+	}
+}
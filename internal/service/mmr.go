@@ -0,0 +1,59 @@
+package service
+
+import (
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// selectMMR greedily selects up to limit candidates with maximal marginal
+// relevance: each step picks the candidate maximizing
+// lambda*similarity(query) - (1-lambda)*max similarity(selected), so that
+// near-duplicates of already selected chunks are passed over. Candidates are
+// expected in retrieval order with their stored vectors; ties keep that order.
+func selectMMR(
+	candidates []models.SearchResult,
+	limit int,
+	lambda float64,
+	similarity func(vec1, vec2 []float32) float32,
+) []models.SearchResult {
+	if limit <= 0 || len(candidates) <= limit {
+		limit = len(candidates)
+	}
+
+	remaining := append([]models.SearchResult{}, candidates...)
+	// redundancy holds the highest similarity of each remaining candidate to the selected ones
+	redundancy := make([]float64, len(remaining))
+
+	selected := make([]models.SearchResult, 0, limit)
+	for len(selected) < limit {
+		best := -1
+		var bestScore float64
+		for i, candidate := range remaining {
+			score := lambda*float64(candidate.Similarity) - (1-lambda)*redundancy[i]
+			if best < 0 || score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+
+		chosen := remaining[best]
+		selected = append(selected, chosen)
+		remaining = append(remaining[:best], remaining[best+1:]...)
+		redundancy = append(redundancy[:best], redundancy[best+1:]...)
+
+		// Update the redundancy of the remaining candidates with the chosen one
+		for i, candidate := range remaining {
+			if len(chosen.Vector) == 0 || len(candidate.Vector) == 0 {
+				continue
+			}
+			if sim := float64(similarity(chosen.Vector, candidate.Vector)); sim > redundancy[i] {
+				redundancy[i] = sim
+			}
+		}
+	}
+
+	// The vectors are only needed for the selection
+	for i := range selected {
+		selected[i].Vector = nil
+	}
+
+	return selected
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yourusername/go-rag/internal/config"
+	"github.com/yourusername/go-rag/internal/embeddings"
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// mmrTestCandidates returns two near-identical adjacent chunks followed by a
+// slightly less similar chunk of another source
+func mmrTestCandidates() []models.SearchResult {
+	return []models.SearchResult{
+		{Document: models.NewDocument("chunk 1", nil), Similarity: 0.90, Vector: []float32{1, 0, 0}},
+		{Document: models.NewDocument("chunk 1 overlap", nil), Similarity: 0.89, Vector: []float32{0.99, 0.01, 0}},
+		{Document: models.NewDocument("other source", nil), Similarity: 0.80, Vector: []float32{0, 1, 0}},
+	}
+}
+
+// TestSelectMMR tests that near-duplicates are passed over for diverse results
+func TestSelectMMR(t *testing.T) {
+	similarity := (&embeddings.GeminiEmbeddingService{}).CalculateSimilarity
+
+	selected := selectMMR(mmrTestCandidates(), 2, 0.5, similarity)
+	if len(selected) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(selected))
+	}
+	if selected[0].Document.Content != "chunk 1" || selected[1].Document.Content != "other source" {
+		t.Errorf("Expected the most relevant chunk and the other source, got %q and %q",
+			selected[0].Document.Content, selected[1].Document.Content)
+	}
+	if selected[0].Vector != nil {
+		t.Errorf("Expected vectors to be removed from the selection")
+	}
+
+	// A lambda of 1 ranks by relevance only
+	selected = selectMMR(mmrTestCandidates(), 2, 1, similarity)
+	if selected[1].Document.Content != "chunk 1 overlap" {
+		t.Errorf("Expected the overlapping chunk with lambda 1, got %q", selected[1].Document.Content)
+	}
+}
+
+// TestSearchWithMMR tests retrieving a candidate pool with vectors for MMR
+func TestSearchWithMMR(t *testing.T) {
+	var vectorQuery models.VectorQuery
+	mockEmbedding := &MockEmbeddingService{
+		GenerateEmbeddingFunc: func(ctx context.Context, text string, options embeddings.Options) ([]float32, error) {
+			return []float32{1, 0, 0}, nil
+		},
+		CalculateSimilarityFunc: (&embeddings.GeminiEmbeddingService{}).CalculateSimilarity,
+	}
+	mockDB := &MockVectorDB{
+		FindSimilarFunc: func(ctx context.Context, query models.VectorQuery) ([]models.SearchResult, error) {
+			vectorQuery = query
+			return mmrTestCandidates(), nil
+		},
+	}
+
+	mockConfig := &config.GeminiConfig{APIKey: "test-api-key", TextModel: "test-model"}
+	ragService, _ := NewRAGService(mockDB, mockEmbedding, mockConfig)
+
+	results, err := ragService.SearchWithOptions(context.Background(), "query", 2, models.RetrievalOptions{MMR: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !vectorQuery.IncludeVectors || vectorQuery.Limit != 2*models.DefaultMMRPoolFactor {
+		t.Errorf("Expected a pool of %d candidates with vectors, got %+v", 2*models.DefaultMMRPoolFactor, vectorQuery)
+	}
+	if len(results) != 2 || results[1].Document.Content != "other source" {
+		t.Errorf("Expected diverse results, got %+v", results)
+	}
+
+	// The pool size can be set per request
+	ragService.SearchWithOptions(context.Background(), "query", 2, models.RetrievalOptions{MMR: true, MMRCandidates: 30})
+	if vectorQuery.Limit != 30 {
+		t.Errorf("Expected a pool of 30 candidates, got %d", vectorQuery.Limit)
+	}
+
+	// Without MMR no vectors are requested
+	ragService.SearchWithOptions(context.Background(), "query", 2, models.RetrievalOptions{})
+	if vectorQuery.IncludeVectors || vectorQuery.Limit != 2 {
+		t.Errorf("Expected a plain search, got %+v", vectorQuery)
+	}
+}
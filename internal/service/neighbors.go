@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// maxNeighborChunks caps the number of neighboring chunks added on each side of a hit
+const maxNeighborChunks = 10
+
+// minOverlapLength is the shortest text overlap between adjacent chunks that is deduplicated
+const minOverlapLength = 10
+
+// chunkRun is a contiguous range of chunk positions within a single source
+type chunkRun struct {
+	sourceID uuid.UUID
+	from     int
+	to       int
+	// hit is the most relevant search result within the run
+	hit models.SearchResult
+}
+
+// expandWithNeighbors replaces each search hit that belongs to a source with
+// a document spanning the hit and up to n preceding and following chunks of
+// the same source. Overlapping or contiguous ranges of the same source are
+// merged into a single document. Results are kept in order of relevance.
+func (s *DefaultRAGService) expandWithNeighbors(
+	ctx context.Context,
+	results []models.SearchResult,
+	n int,
+) ([]models.SearchResult, error) {
+	if n <= 0 {
+		return results, nil
+	}
+	if n > maxNeighborChunks {
+		n = maxNeighborChunks
+	}
+
+	// Build one run per hit, merging it into an earlier run of the same source
+	// when the ranges overlap or touch. Entries without a source stay as they are.
+	type entry struct {
+		run    *chunkRun
+		result models.SearchResult
+	}
+	var entries []entry
+	for _, result := range results {
+		doc := result.Document
+		if doc.SourceID == nil {
+			entries = append(entries, entry{result: result})
+			continue
+		}
+
+		from, to := max(doc.Position-n, 0), doc.Position+n
+		merged := false
+		for _, e := range entries {
+			if e.run == nil || e.run.sourceID != *doc.SourceID {
+				continue
+			}
+			if from <= e.run.to+1 && to >= e.run.from-1 {
+				e.run.from = min(e.run.from, from)
+				e.run.to = max(e.run.to, to)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			entries = append(entries, entry{run: &chunkRun{sourceID: *doc.SourceID, from: from, to: to, hit: result}})
+		}
+	}
+
+	// Runs that grew may now touch other runs of the same source
+	for i := 0; i < len(entries); i++ {
+		for j := i + 1; j < len(entries); j++ {
+			a, b := entries[i].run, entries[j].run
+			if a == nil || b == nil || a.sourceID != b.sourceID {
+				continue
+			}
+			if b.from <= a.to+1 && b.to >= a.from-1 {
+				a.from, a.to = min(a.from, b.from), max(a.to, b.to)
+				entries = append(entries[:j], entries[j+1:]...)
+				j = i
+			}
+		}
+	}
+
+	// Fetch and stitch the chunks of every run
+	var expanded []models.SearchResult
+	for _, e := range entries {
+		if e.run == nil {
+			expanded = append(expanded, e.result)
+			continue
+		}
+
+		chunks, err := s.db.ListSourceChunksInRange(ctx, e.run.sourceID, e.run.from, e.run.to)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch neighboring chunks: %w", err)
+		}
+		if len(chunks) == 0 {
+			expanded = append(expanded, e.run.hit)
+			continue
+		}
+
+		expanded = append(expanded, models.SearchResult{
+			Document:    mergeChunks(e.run.hit.Document, chunks),
+			Similarity:  e.run.hit.Similarity,
+			Score:       e.run.hit.Score,
+			RerankScore: e.run.hit.RerankScore,
+		})
+	}
+
+	return expanded, nil
+}
+
+// mergeChunks stitches consecutive chunks of a source into a single document
+// based on the matched document, removing text repeated by chunk overlap
+func mergeChunks(hit models.Document, chunks []models.Document) models.Document {
+	var content string
+	for i, chunk := range chunks {
+		if i == 0 {
+			content = chunk.Content
+			continue
+		}
+		content = joinOverlapping(content, chunk.Content)
+	}
+
+	// Copy the metadata of the matched chunk and record the merged range; the
+	// position stays the one of the matched chunk, which citations refer to
+	metadata := make(map[string]interface{})
+	for k, v := range hit.Metadata {
+		metadata[k] = v
+	}
+	metadata["expanded_from"] = chunks[0].Position
+	metadata["expanded_to"] = chunks[len(chunks)-1].Position
+
+	merged := hit
+	merged.Content = content
+	merged.Metadata = metadata
+
+	return merged
+}
+
+// joinOverlapping appends next to prev, dropping the longest prefix of next
+// that repeats the end of prev
+func joinOverlapping(prev, next string) string {
+	for k := min(len(prev), len(next)); k >= minOverlapLength; k-- {
+		// Only consider overlaps that end on a word boundary in next
+		if k < len(next) && next[k] != ' ' && next[k] != '\n' {
+			continue
+		}
+		if strings.HasSuffix(prev, next[:k]) {
+			return prev + next[k:]
+		}
+	}
+
+	return prev + "\n\n" + next
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/yourusername/go-rag/internal/config"
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// newSourceChunks creates count chunks of a single source with predictable content
+func newSourceChunks(sourceID uuid.UUID, count int) []models.Document {
+	chunks := make([]models.Document, count)
+	for i := range chunks {
+		chunks[i] = models.NewSourceDocument(sourceID, i, fmt.Sprintf("Chunk number %d.", i), nil)
+	}
+	return chunks
+}
+
+// newRangeMockDB returns a mock database serving chunk ranges from the given chunks
+func newRangeMockDB(chunks []models.Document, calls *int) *MockVectorDB {
+	return &MockVectorDB{
+		ListSourceChunksInRangeFunc: func(ctx context.Context, sourceID uuid.UUID, from, to int) ([]models.Document, error) {
+			*calls++
+			var result []models.Document
+			for _, chunk := range chunks {
+				if *chunk.SourceID == sourceID && chunk.Position >= from && chunk.Position <= to {
+					result = append(result, chunk)
+				}
+			}
+			return result, nil
+		},
+	}
+}
+
+// TestExpandWithNeighbors tests expanding hits with the surrounding chunks
+func TestExpandWithNeighbors(t *testing.T) {
+	sourceID := uuid.New()
+	chunks := newSourceChunks(sourceID, 10)
+	standalone := models.NewDocument("Standalone document", nil)
+
+	calls := 0
+	mockConfig := &config.GeminiConfig{APIKey: "test-api-key"}
+	service, _ := NewRAGService(newRangeMockDB(chunks, &calls), &MockEmbeddingService{}, mockConfig)
+	ragService := service.(*DefaultRAGService)
+
+	results := []models.SearchResult{
+		{Document: chunks[5], Similarity: 0.9},
+		{Document: standalone, Similarity: 0.8},
+		{Document: chunks[7], Similarity: 0.7},
+		{Document: chunks[1], Similarity: 0.6},
+	}
+
+	expanded, err := ragService.expandWithNeighbors(context.Background(), results, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Chunks 4-8 form one contiguous run, chunks 0-2 another
+	if len(expanded) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(expanded))
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 range queries, got %d", calls)
+	}
+
+	first := expanded[0]
+	if first.Similarity != 0.9 {
+		t.Errorf("Expected the most relevant run first, got similarity %f", first.Similarity)
+	}
+	expected := "Chunk number 4.\n\nChunk number 5.\n\nChunk number 6.\n\nChunk number 7.\n\nChunk number 8."
+	if first.Document.Content != expected {
+		t.Errorf("Expected merged content %q, got %q", expected, first.Document.Content)
+	}
+	if first.Document.Metadata["expanded_from"] != 4 || first.Document.Metadata["expanded_to"] != 8 {
+		t.Errorf("Expected range 4-8 in metadata, got %v", first.Document.Metadata)
+	}
+	if first.Document.Position != 5 {
+		t.Errorf("Expected the position of the matched chunk 5, got %d", first.Document.Position)
+	}
+
+	if expanded[1].Document.ID != standalone.ID {
+		t.Errorf("Expected the document without a source to be kept as is")
+	}
+
+	if expanded[2].Document.Metadata["expanded_from"] != 0 || expanded[2].Document.Metadata["expanded_to"] != 2 {
+		t.Errorf("Expected range 0-2 in metadata, got %v", expanded[2].Document.Metadata)
+	}
+	if expanded[2].Document.Position != 1 {
+		t.Errorf("Expected the position of the matched chunk 1, got %d", expanded[2].Document.Position)
+	}
+
+	// Expansion is disabled by default
+	calls = 0
+	unchanged, _ := ragService.expandWithNeighbors(context.Background(), results, 0)
+	if len(unchanged) != len(results) || calls != 0 {
+		t.Errorf("Expected results to be unchanged without neighbor chunks")
+	}
+}
+
+// TestJoinOverlapping tests removing text repeated by chunk overlap
+func TestJoinOverlapping(t *testing.T) {
+	tests := []struct {
+		name     string
+		prev     string
+		next     string
+		expected string
+	}{
+		{
+			name:     "Overlapping chunks",
+			prev:     "The quick brown fox jumps over the lazy dog",
+			next:     "jumps over the lazy dog and runs away",
+			expected: "The quick brown fox jumps over the lazy dog and runs away",
+		},
+		{
+			name:     "Separate chunks",
+			prev:     "First paragraph.",
+			next:     "Second paragraph.",
+			expected: "First paragraph.\n\nSecond paragraph.",
+		},
+		{
+			name:     "Overlap shorter than the minimum",
+			prev:     "Ends with dog",
+			next:     "dog starts here",
+			expected: "Ends with dog\n\ndog starts here",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := joinOverlapping(tt.prev, tt.next); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/yourusername/go-rag/internal/loader"
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// minTruncatedTokens is the smallest remaining budget worth filling with a truncated document
+const minTruncatedTokens = 50
+
+// truncationMarker is appended to documents that were cut to fit the budget
+const truncationMarker = " [...]"
+
+// packContext orders results by relevance and fits them into what a token
+// budget leaves after the promptTokens of the rest of the prompt. Documents
+// that fit are included as they are; the first document that does not fit is
+// truncated when enough budget is left for a meaningful excerpt, and the
+// remaining ones are dropped unless they still fit. A budget of zero or less
+// includes every document.
+func packContext(results []models.SearchResult, budget, promptTokens int, tok loader.Tokenizer) ([]models.SearchResult, models.ContextReport) {
+	report := models.ContextReport{Budget: budget, PromptTokens: promptTokens}
+
+	// Order by relevance, keeping the retrieval order for equal relevance
+	ordered := append([]models.SearchResult{}, results...)
+	models.SortByRelevance(ordered)
+
+	var packed []models.SearchResult
+	for _, result := range ordered {
+		tokens := tok.CountTokens(result.Document.Content)
+		entry := models.ContextDocument{
+			DocumentID:     result.Document.ID,
+			Similarity:     result.Similarity,
+			OriginalTokens: tokens,
+		}
+
+		remaining := budget - promptTokens - report.UsedTokens
+		switch {
+		case budget <= 0 || tokens <= remaining:
+			entry.Status = models.ContextIncluded
+			entry.Tokens = tokens
+			packed = append(packed, result)
+		case remaining >= minTruncatedTokens:
+			entry.Status = models.ContextTruncated
+			result.Document.Content = truncateToTokens(result.Document.Content, remaining, tok)
+			entry.Tokens = tok.CountTokens(result.Document.Content)
+			packed = append(packed, result)
+		default:
+			entry.Status = models.ContextDropped
+		}
+
+		report.UsedTokens += entry.Tokens
+		report.Documents = append(report.Documents, entry)
+	}
+
+	return packed, report
+}
+
+// truncateToTokens cuts text to at most maxTokens tokens including the truncation marker
+func truncateToTokens(text string, maxTokens int, tok loader.Tokenizer) string {
+	spans := tok.Tokenize(text)
+	keep := maxTokens - tok.CountTokens(truncationMarker)
+	if keep <= 0 {
+		return ""
+	}
+	if keep >= len(spans) {
+		return text
+	}
+
+	return strings.TrimSpace(text[:spans[keep-1].End]) + truncationMarker
+}
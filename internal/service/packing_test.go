@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/go-rag/internal/config"
+	"github.com/yourusername/go-rag/internal/embeddings"
+	"github.com/yourusername/go-rag/internal/loader"
+	"github.com/yourusername/go-rag/internal/models"
+	"github.com/yourusername/go-rag/internal/prompt"
+)
+
+// TestPackContext tests fitting retrieved documents into a token budget
+func TestPackContext(t *testing.T) {
+	tok := loader.CharacterTokenizer{}
+
+	small := models.NewDocument(strings.Repeat("a", 40), nil)
+	large := models.NewDocument(strings.Repeat("b ", 100), nil)
+	medium := models.NewDocument(strings.Repeat("c", 60), nil)
+	tiny := models.NewDocument(strings.Repeat("d", 10), nil)
+
+	// Results are not ordered by relevance on purpose
+	results := []models.SearchResult{
+		{Document: medium, Similarity: 0.5},
+		{Document: small, Similarity: 0.9},
+		{Document: large, Similarity: 0.7},
+		{Document: tiny, Similarity: 0.1},
+	}
+
+	packed, report := packContext(results, 150, 0, tok)
+
+	// small (40) and large truncated to the remaining 110, then no room is left
+	expected := []struct {
+		doc    models.Document
+		status models.ContextStatus
+	}{
+		{small, models.ContextIncluded},
+		{large, models.ContextTruncated},
+		{medium, models.ContextDropped},
+		{tiny, models.ContextDropped},
+	}
+
+	if len(report.Documents) != len(expected) {
+		t.Fatalf("Expected %d report entries, got %d", len(expected), len(report.Documents))
+	}
+	for i, e := range expected {
+		entry := report.Documents[i]
+		if entry.DocumentID != e.doc.ID || entry.Status != e.status {
+			t.Errorf("Entry %d: expected %s to be %s, got %s %s", i, e.doc.ID, e.status, entry.DocumentID, entry.Status)
+		}
+	}
+
+	if len(packed) != 2 {
+		t.Fatalf("Expected 2 packed documents, got %d", len(packed))
+	}
+	if packed[0].Document.ID != small.ID {
+		t.Errorf("Expected the most relevant document first")
+	}
+	if !strings.HasSuffix(packed[1].Document.Content, truncationMarker) {
+		t.Errorf("Expected truncated document to end with the truncation marker, got %q", packed[1].Document.Content)
+	}
+	if report.UsedTokens > report.Budget {
+		t.Errorf("Expected at most %d tokens, used %d", report.Budget, report.UsedTokens)
+	}
+
+	// The original results are not modified
+	if results[2].Document.Content != large.Content {
+		t.Errorf("Expected original results to be unchanged")
+	}
+}
+
+// TestPackContextSkipsToSmallerDocuments tests that smaller documents still fill the budget
+func TestPackContextSkipsToSmallerDocuments(t *testing.T) {
+	tok := loader.CharacterTokenizer{}
+
+	first := models.NewDocument(strings.Repeat("a", 80), nil)
+	second := models.NewDocument(strings.Repeat("b", 80), nil)
+	third := models.NewDocument(strings.Repeat("c", 15), nil)
+
+	results := []models.SearchResult{
+		{Document: first, Similarity: 0.9},
+		{Document: second, Similarity: 0.8},
+		{Document: third, Similarity: 0.7},
+	}
+
+	// 20 tokens remain after the first document: too few to truncate the second
+	packed, report := packContext(results, 100, 0, tok)
+
+	if len(packed) != 2 || packed[1].Document.ID != third.ID {
+		t.Fatalf("Expected the first and third documents, got %d documents", len(packed))
+	}
+	if report.Documents[1].Status != models.ContextDropped || report.Documents[2].Status != models.ContextIncluded {
+		t.Errorf("Unexpected report %+v", report.Documents)
+	}
+}
+
+// TestPackContextWithoutBudget tests that every document is included without a budget
+func TestPackContextWithoutBudget(t *testing.T) {
+	results := []models.SearchResult{
+		{Document: models.NewDocument(strings.Repeat("a", 1000), nil), Similarity: 0.9},
+		{Document: models.NewDocument(strings.Repeat("b", 1000), nil), Similarity: 0.8},
+	}
+
+	packed, report := packContext(results, 0, 0, loader.CharacterTokenizer{})
+
+	if len(packed) != 2 {
+		t.Errorf("Expected 2 documents, got %d", len(packed))
+	}
+	if report.UsedTokens != 2000 {
+		t.Errorf("Expected 2000 used tokens, got %d", report.UsedTokens)
+	}
+	for _, entry := range report.Documents {
+		if entry.Status != models.ContextIncluded {
+			t.Errorf("Expected every document to be included, got %s", entry.Status)
+		}
+	}
+}
+
+// TestPackContextReservesPromptTokens tests that the rest of the prompt is taken from the budget
+func TestPackContextReservesPromptTokens(t *testing.T) {
+	tok := loader.CharacterTokenizer{}
+
+	first := models.NewDocument(strings.Repeat("a", 60), nil)
+	second := models.NewDocument(strings.Repeat("b", 60), nil)
+	results := []models.SearchResult{
+		{Document: first, Similarity: 0.9},
+		{Document: second, Similarity: 0.8},
+	}
+
+	// Both documents fit into the budget alone, but only the first one next to the prompt
+	packed, report := packContext(results, 150, 80, tok)
+
+	if len(packed) != 1 || packed[0].Document.ID != first.ID {
+		t.Fatalf("Expected only the first document, got %d documents", len(packed))
+	}
+	if report.PromptTokens != 80 || report.UsedTokens != 60 {
+		t.Errorf("Expected 80 prompt and 60 used tokens, got %d and %d", report.PromptTokens, report.UsedTokens)
+	}
+
+	// A prompt exceeding the budget leaves no room for documents
+	packed, _ = packContext(results, 150, 200, tok)
+	if len(packed) != 0 {
+		t.Errorf("Expected no documents, got %d", len(packed))
+	}
+}
+
+// TestQueryPromptFitsContextBudget tests that the prompt sent to the model,
+// including the system instruction and previous turns, stays within the budget
+func TestQueryPromptFitsContextBudget(t *testing.T) {
+	mockDB := &MockVectorDB{
+		FindSimilarFunc: func(ctx context.Context, query models.VectorQuery) ([]models.SearchResult, error) {
+			return []models.SearchResult{
+				{Document: models.NewDocument(strings.Repeat("a", 400), nil), Similarity: 0.9},
+				{Document: models.NewDocument(strings.Repeat("b", 400), nil), Similarity: 0.8},
+			}, nil
+		},
+	}
+	mockEmbedding := &MockEmbeddingService{
+		GenerateEmbeddingFunc: func(ctx context.Context, text string, options embeddings.Options) ([]float32, error) {
+			return []float32{0.1, 0.2, 0.3}, nil
+		},
+	}
+
+	var sent int
+	tok := loader.CharacterTokenizer{}
+	generator := &MockGenerator{
+		GenerateFunc: func(ctx context.Context, history []models.ChatMessage, prompt string, options models.GenerationOptions) (string, error) {
+			sent = tok.CountTokens(prompt) + tok.CountTokens(options.SystemInstruction)
+			for _, message := range history {
+				sent += tok.CountTokens(message.Content)
+			}
+			return "Answer [S1].", nil
+		},
+	}
+
+	mockConfig := &config.GeminiConfig{
+		APIKey:             "test-api-key",
+		TextModel:          "test-model",
+		ContextTokenBudget: 1200,
+		Generation:         models.GenerationOptions{SystemInstruction: strings.Repeat("s", 200)},
+	}
+	ragService, err := NewRAGService(mockDB, mockEmbedding, mockConfig, WithGenerator(generator))
+	if err != nil {
+		t.Fatalf("Failed to create RAG service: %v", err)
+	}
+	service := ragService.(*DefaultRAGService)
+	service.tokenizer = tok
+
+	history := []models.ChatMessage{
+		{Role: models.ChatRoleUser, Content: strings.Repeat("q", 100)},
+		{Role: models.ChatRoleModel, Content: strings.Repeat("r", 100)},
+	}
+	response, _, err := service.answer(context.Background(), models.RAGQuery{Query: "What is in the documents?"}, history)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if sent > mockConfig.ContextTokenBudget {
+		t.Errorf("Expected at most %d tokens to be sent, got %d", mockConfig.ContextTokenBudget, sent)
+	}
+	report := response.Metadata.(map[string]interface{})["context"].(models.ContextReport)
+	if report.PromptTokens == 0 || report.Documents[1].Status == models.ContextIncluded {
+		t.Errorf("Expected the prompt to leave no room for the second document, got %+v", report)
+	}
+}
+
+// TestPromptTokensCountsHistoryOnce tests that previous turns are counted once,
+// whether or not the template renders them
+func TestPromptTokensCountsHistoryOnce(t *testing.T) {
+	templates := prompt.NewTemplates()
+	if err := templates.Add("plain", "{{.Query}}"); err != nil {
+		t.Fatalf("Failed to add template: %v", err)
+	}
+	if err := templates.Add("chat", "{{range .History}}{{.Content}}\n{{end}}{{.Query}}"); err != nil {
+		t.Fatalf("Failed to add template: %v", err)
+	}
+	service := &DefaultRAGService{templates: templates, tokenizer: loader.CharacterTokenizer{}}
+
+	history := []models.ChatMessage{
+		{Role: models.ChatRoleUser, Content: strings.Repeat("u", 10)},
+		{Role: models.ChatRoleModel, Content: strings.Repeat("m", 10)},
+	}
+
+	tests := []struct {
+		template string
+		expected int
+	}{
+		// The query and the turns sent as multi-turn contents
+		{"plain", 1 + 20},
+		// The prompt already contains the turns
+		{"chat", 10 + 1 + 10 + 1 + 1},
+	}
+	for _, tt := range tests {
+		tokens, err := service.promptTokens(models.RAGQuery{Query: "q", Template: tt.template}, nil, history, models.GenerationOptions{})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if tokens != tt.expected {
+			t.Errorf("Template %s: expected %d tokens, got %d", tt.template, tt.expected, tokens)
+		}
+	}
+}
@@ -1,53 +1,34 @@
 package service
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
-	"strings"
-	"time"
+
+	"github.com/google/uuid"
 
 	"github.com/yourusername/go-rag/internal/config"
 	"github.com/yourusername/go-rag/internal/database"
 	"github.com/yourusername/go-rag/internal/embeddings"
+	"github.com/yourusername/go-rag/internal/loader"
 	"github.com/yourusername/go-rag/internal/models"
+	"github.com/yourusername/go-rag/internal/prompt"
 )
 
-// GeminiGenerationRequest represents a request to the Gemini API for text generation
-type GeminiGenerationRequest struct {
-	Contents []GeminiContent `json:"contents"`
-}
-
-// GeminiContent represents the content part of a Gemini request
-type GeminiContent struct {
-	Parts []GeminiPart `json:"parts"`
-}
-
-// GeminiPart represents a part of the content in a Gemini request
-type GeminiPart struct {
-	Text string `json:"text"`
-}
-
-// GeminiGenerationResponse represents a response from the Gemini API for text generation
-type GeminiGenerationResponse struct {
-	Candidates []struct {
-		Content struct {
-			Parts []struct {
-				Text string `json:"text"`
-			} `json:"parts"`
-		} `json:"content"`
-	} `json:"candidates"`
-}
-
 // RAGService provides Retrieval Augmented Generation functionality
 type RAGService interface {
 	AddDocument(ctx context.Context, content string, metadata map[string]interface{}) (string, error)
 	SearchSimilar(ctx context.Context, query string, limit int) ([]models.SearchResult, error)
+	SearchWithOptions(ctx context.Context, query string, limit int, options models.RetrievalOptions) ([]models.SearchResult, error)
 	Query(ctx context.Context, query string, limit int) (*models.RAGResponse, error)
+	QueryWithOptions(ctx context.Context, request models.RAGQuery) (*models.RAGResponse, error)
+	Retrieve(ctx context.Context, request models.RAGQuery) ([]models.SearchResult, error)
+	ListSources(ctx context.Context, limit, offset int) ([]models.Source, error)
+	ListSourceChunks(ctx context.Context, sourceID uuid.UUID) ([]models.Document, error)
+	DeleteSource(ctx context.Context, sourceID uuid.UUID) error
+	Chat(ctx context.Context, request models.ChatRequest) (*models.ChatResponse, error)
+	GetChatHistory(ctx context.Context, sessionID uuid.UUID) ([]models.ChatMessage, error)
+	DeleteChatSession(ctx context.Context, sessionID uuid.UUID) error
 }
 
 // DefaultRAGService is the default implementation of the RAGService
@@ -55,7 +36,73 @@ type DefaultRAGService struct {
 	db               database.VectorDB
 	embeddingService embeddings.EmbeddingService
 	geminiConfig     *config.GeminiConfig
-	httpClient       *http.Client
+	generator        Generator
+	templates        *prompt.Templates
+	tokenizer        loader.Tokenizer
+	conversations    database.ConversationStore
+	reranker         Reranker
+	rerankCandidates int
+	// groundedness holds the default verification of answers
+	groundedness             models.GroundednessOptions
+	sentenceSupportThreshold float64
+	refusalMessage           string
+	cache                    *AnswerCache
+}
+
+// Option configures optional dependencies of the DefaultRAGService
+type Option func(*DefaultRAGService)
+
+// WithPromptTemplates sets the prompt templates used to build the RAG prompt
+func WithPromptTemplates(templates *prompt.Templates) Option {
+	return func(s *DefaultRAGService) {
+		if templates != nil {
+			s.templates = templates
+		}
+	}
+}
+
+// WithGenerator sets the generator used to answer queries instead of Gemini
+func WithGenerator(generator Generator) Option {
+	return func(s *DefaultRAGService) {
+		if generator != nil {
+			s.generator = generator
+		}
+	}
+}
+
+// WithReranker sets the reranker applied after retrieving the given number of candidates
+func WithReranker(reranker Reranker, candidates int) Option {
+	return func(s *DefaultRAGService) {
+		s.reranker = reranker
+		s.rerankCandidates = candidates
+	}
+}
+
+// WithGroundedness sets the default verification of answers against the retrieved context
+func WithGroundedness(cfg config.GroundednessConfig) Option {
+	return func(s *DefaultRAGService) {
+		s.groundedness = cfg.Options
+		if cfg.SentenceThreshold > 0 {
+			s.sentenceSupportThreshold = cfg.SentenceThreshold
+		}
+		if cfg.RefusalMessage != "" {
+			s.refusalMessage = cfg.RefusalMessage
+		}
+	}
+}
+
+// WithAnswerCache sets the cache of answers reused for similar queries
+func WithAnswerCache(cache *AnswerCache) Option {
+	return func(s *DefaultRAGService) {
+		s.cache = cache
+	}
+}
+
+// WithConversationStore sets the store of chat sessions used by Chat
+func WithConversationStore(store database.ConversationStore) Option {
+	return func(s *DefaultRAGService) {
+		s.conversations = store
+	}
 }
 
 // NewRAGService creates a new RAG service
@@ -63,6 +110,7 @@ func NewRAGService(
 	db database.VectorDB,
 	embeddingService embeddings.EmbeddingService,
 	geminiConfig *config.GeminiConfig,
+	opts ...Option,
 ) (RAGService, error) {
 	if db == nil {
 		return nil, fmt.Errorf("database is required")
@@ -74,14 +122,22 @@ func NewRAGService(
 		return nil, fmt.Errorf("Gemini config is required")
 	}
 
-	return &DefaultRAGService{
+	s := &DefaultRAGService{
 		db:               db,
 		embeddingService: embeddingService,
 		geminiConfig:     geminiConfig,
-		httpClient: &http.Client{
-			Timeout: 60 * time.Second,
-		},
-	}, nil
+		generator:        NewGeminiGenerator(geminiConfig),
+		templates:        prompt.NewTemplates(),
+		tokenizer:        loader.NewApproximateTokenizer(),
+
+		sentenceSupportThreshold: defaultSentenceSupportThreshold,
+		refusalMessage:           defaultRefusalMessage,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
 }
 
 // AddDocument adds a document to the RAG system
@@ -97,8 +153,15 @@ func (s *DefaultRAGService) AddDocument(
 	// Create a new document
 	doc := models.NewDocument(content, metadata)
 
-	// Generate embedding for the document
-	embedding, err := s.embeddingService.GenerateEmbedding(ctx, content)
+	// Generate embedding for the document, titled like loaded files
+	title, ok := metadata["title"].(string)
+	if !ok {
+		title, _ = metadata["file_name"].(string)
+	}
+	embedding, err := s.embeddingService.GenerateEmbedding(ctx, content, embeddings.Options{
+		Purpose: embeddings.PurposeDocument,
+		Title:   title,
+	})
 	if err != nil {
 		return "", fmt.Errorf("failed to generate embedding: %w", err)
 	}
@@ -107,6 +170,7 @@ func (s *DefaultRAGService) AddDocument(
 	if err := s.db.StoreDocument(ctx, doc, embedding); err != nil {
 		return "", fmt.Errorf("failed to store document: %w", err)
 	}
+	s.cache.invalidate()
 
 	return doc.ID.String(), nil
 }
@@ -116,17 +180,36 @@ func (s *DefaultRAGService) SearchSimilar(
 	ctx context.Context,
 	query string,
 	limit int,
+) ([]models.SearchResult, error) {
+	return s.SearchWithOptions(ctx, query, limit, models.RetrievalOptions{})
+}
+
+// SearchWithOptions searches for documents similar to the query using the given retrieval mode
+func (s *DefaultRAGService) SearchWithOptions(
+	ctx context.Context,
+	query string,
+	limit int,
+	options models.RetrievalOptions,
 ) ([]models.SearchResult, error) {
 	if query == "" {
 		return nil, fmt.Errorf("query cannot be empty")
 	}
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
 
 	if limit <= 0 {
 		limit = 5 // Default limit
 	}
 
-	// Generate embedding for the query
-	queryEmbedding, err := s.embeddingService.GenerateEmbedding(ctx, query)
+	// Generate the vector to search with
+	var queryEmbedding []float32
+	var err error
+	if options.Mode == models.RetrievalHyDE {
+		queryEmbedding, err = s.hypotheticalDocumentEmbedding(ctx, query, options.HyDEIncludeQuery)
+	} else {
+		queryEmbedding, err = s.embeddingService.GenerateEmbedding(ctx, query, embeddings.Options{Purpose: embeddings.PurposeQuery})
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
 	}
@@ -138,12 +221,24 @@ func (s *DefaultRAGService) SearchSimilar(
 		Threshold: 0.0, // No threshold for now
 	}
 
+	// MMR selects from a larger pool and compares the stored vectors
+	if options.MMR {
+		vectorQuery.Limit = options.PoolSize(limit)
+		vectorQuery.IncludeVectors = true
+	}
+
+	traceFrom(ctx).recordQueryEmbedding(query, options.Mode, queryEmbedding)
+
 	// Search for similar documents
 	results, err := s.db.FindSimilar(ctx, vectorQuery)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find similar documents: %w", err)
 	}
 
+	if options.MMR {
+		results = selectMMR(results, limit, options.Lambda(), s.embeddingService.CalculateSimilarity)
+	}
+
 	return results, nil
 }
 
@@ -153,15 +248,80 @@ func (s *DefaultRAGService) Query(
 	query string,
 	limit int,
 ) (*models.RAGResponse, error) {
+	return s.QueryWithOptions(ctx, models.RAGQuery{Query: query, Limit: limit})
+}
+
+// QueryWithOptions performs a RAG query using the per-request options of the
+// query. Explained queries always run the whole pipeline.
+func (s *DefaultRAGService) QueryWithOptions(
+	ctx context.Context,
+	request models.RAGQuery,
+) (*models.RAGResponse, error) {
+	if s.cache == nil || request.Query == "" || request.Explain || request.NoCache {
+		return s.uncachedAnswer(ctx, request)
+	}
+	return s.cachedAnswer(ctx, request)
+}
+
+// answer runs the RAG pipeline for a query, continuing the conversation in
+// history when it is not empty. It also returns the search results the answer
+// was generated from.
+func (s *DefaultRAGService) answer(
+	ctx context.Context,
+	request models.RAGQuery,
+	history []models.ChatMessage,
+) (*models.RAGResponse, []models.SearchResult, error) {
+	query := request.Query
 	if query == "" {
-		return nil, fmt.Errorf("query cannot be empty")
+		return nil, nil, fmt.Errorf("query cannot be empty")
 	}
 
-	// Retrieve relevant documents
-	results, err := s.SearchSimilar(ctx, query, limit)
+	// Apply the per-request generation options on top of the configured ones
+	generation := s.geminiConfig.Generation.Merge(request.Generation)
+	if err := generation.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if err := request.RetrievalOptions.Validate(); err != nil {
+		return nil, nil, err
+	}
+	groundedness := s.groundedness.Merge(request.Groundedness)
+	if err := groundedness.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	metadata := make(map[string]interface{})
+
+	// Collect the details of the deeper stages when the request is explained
+	var trace *explainTrace
+	if request.Explain {
+		trace = &explainTrace{}
+		ctx = withTrace(ctx, trace)
+	}
+	timer := newStageTimer()
+
+	// Retrieve the candidates and select the documents to answer from
+	candidates, selected, err := s.retrieve(ctx, request, metadata, timer)
+	if err != nil {
+		return nil, nil, err
+	}
+	results := selected
+
+	// Add the surrounding chunks of each hit
+	results, err = s.expandWithNeighbors(ctx, results, request.NeighborChunks)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to expand documents: %w", err)
+	}
+	timer.mark("neighbors")
+
+	// Fit the documents into what the context token budget of the model leaves
+	// after the rest of the prompt
+	promptTokens, err := s.promptTokens(request, results, history, generation)
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve documents: %w", err)
+		return nil, nil, fmt.Errorf("failed to build prompt: %w", err)
 	}
+	results, contextReport := packContext(results, s.geminiConfig.ContextTokenBudget, promptTokens, s.tokenizer)
+	metadata["context"] = contextReport
+	timer.mark("packing")
 
 	// Extract documents for the response
 	var documents []models.Document
@@ -170,21 +330,146 @@ func (s *DefaultRAGService) Query(
 	}
 
 	// Augment query with document context
-	augmentedQuery := s.augmentQueryWithContext(query, documents)
+	augmentedQuery, err := s.buildPrompt(request, results, history)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build prompt: %w", err)
+	}
+	timer.mark("prompt")
 
-	// Generate response using Gemini
-	answer, err := s.generateResponseWithGemini(ctx, augmentedQuery)
+	// Generate the response, passing previous turns as multi-turn contents
+	var answerCall int
+	if trace != nil {
+		answerCall = trace.modelCallCount()
+	}
+	answer, err := s.generator.Generate(ctx, history, augmentedQuery, generation)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate response: %w", err)
+		return nil, nil, fmt.Errorf("failed to generate response: %w", err)
 	}
+	timer.mark("generation")
 
-	// Create RAG response
+	// Create RAG response, resolving the sources cited in the answer
 	response := &models.RAGResponse{
 		Answer:    answer,
 		Documents: documents,
+		Citations: parseCitations(answer, results),
+		Metadata:  metadata,
+	}
+
+	// Verify the answer against the context it was generated from
+	if groundedness.Enabled() {
+		metadata["groundedness"] = s.checkGroundedness(ctx, response, results, groundedness)
+		timer.mark("groundedness")
+	}
+
+	if trace != nil {
+		metadata["explain"] = trace.explain(candidates, selected, contextReport, augmentedQuery, answerCall, timer.latency())
+	}
+
+	return response, results, nil
+}
+
+// Retrieve runs the retrieval stages of a RAG query, multi-query retrieval
+// and reranking, without generating an answer. It returns the results an
+// answer would be generated from, before neighbor expansion and packing.
+func (s *DefaultRAGService) Retrieve(ctx context.Context, request models.RAGQuery) ([]models.SearchResult, error) {
+	if request.Query == "" {
+		return nil, fmt.Errorf("query cannot be empty")
+	}
+	if err := request.RetrievalOptions.Validate(); err != nil {
+		return nil, err
 	}
 
-	return response, nil
+	_, selected, err := s.retrieve(ctx, request, make(map[string]interface{}), newStageTimer())
+	return selected, err
+}
+
+// retrieve searches the candidates of a query and selects the request limit
+// of them with the reranker, recording the query rewrite and rerank reports in
+// metadata. It returns the candidates and the selected results.
+func (s *DefaultRAGService) retrieve(
+	ctx context.Context,
+	request models.RAGQuery,
+	metadata map[string]interface{},
+	timer *stageTimer,
+) ([]models.SearchResult, []models.SearchResult, error) {
+	limit := request.Limit
+	if limit <= 0 {
+		limit = 5 // Default limit
+	}
+
+	// Over-fetch candidates when they are reranked afterwards
+	retrieveLimit := limit
+	if s.reranker != nil {
+		candidates := request.RerankCandidates
+		if candidates <= 0 {
+			candidates = s.rerankCandidates
+		}
+		if candidates > retrieveLimit {
+			retrieveLimit = candidates
+		}
+	}
+
+	// Retrieve relevant documents, optionally for generated alternatives of the query too
+	var results []models.SearchResult
+	var err error
+	query := request.Query
+	if request.MultiQuery > 0 {
+		var rewrite *QueryRewrite
+		results, rewrite, err = s.multiQuerySearch(ctx, query, request.MultiQuery, retrieveLimit, request.RetrievalOptions)
+		metadata["query_rewrite"] = rewrite
+	} else {
+		results, err = s.SearchWithOptions(ctx, query, retrieveLimit, request.RetrievalOptions)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to retrieve documents: %w", err)
+	}
+	timer.mark("retrieval")
+	candidates := results
+
+	// Keep the best candidates according to the reranker
+	if s.reranker != nil {
+		var rerankReport *RerankReport
+		results, rerankReport = s.rerank(ctx, query, results, limit)
+		metadata["rerank"] = rerankReport
+		timer.mark("rerank")
+	}
+
+	return candidates, results, nil
+}
+
+// ListSources returns the ingested sources with pagination
+func (s *DefaultRAGService) ListSources(ctx context.Context, limit, offset int) ([]models.Source, error) {
+	sources, err := s.db.ListSources(ctx, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sources: %w", err)
+	}
+
+	return sources, nil
+}
+
+// ListSourceChunks returns the chunks of a source ordered by position
+func (s *DefaultRAGService) ListSourceChunks(ctx context.Context, sourceID uuid.UUID) ([]models.Document, error) {
+	// Make sure the source exists so that an unknown ID is reported as such
+	if _, err := s.db.GetSource(ctx, sourceID); err != nil {
+		return nil, fmt.Errorf("failed to get source: %w", err)
+	}
+
+	chunks, err := s.db.ListSourceChunks(ctx, sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source chunks: %w", err)
+	}
+
+	return chunks, nil
+}
+
+// DeleteSource deletes a source together with all of its chunks
+func (s *DefaultRAGService) DeleteSource(ctx context.Context, sourceID uuid.UUID) error {
+	if err := s.db.DeleteSource(ctx, sourceID); err != nil {
+		return fmt.Errorf("failed to delete source: %w", err)
+	}
+	s.cache.invalidate()
+
+	return nil
 }
 
 // retrieveRelevantDocuments fetches documents relevant to the query
@@ -205,88 +490,108 @@ func (s *DefaultRAGService) retrieveRelevantDocuments(ctx context.Context, query
 }
 
 // augmentQueryWithContext adds context from retrieved documents to the query
+// using the default prompt template
 func (s *DefaultRAGService) augmentQueryWithContext(query string, documents []models.Document) string {
-	if len(documents) == 0 {
-		return query
+	results := make([]models.SearchResult, 0, len(documents))
+	for _, doc := range documents {
+		results = append(results, models.SearchResult{Document: doc})
 	}
 
-	var sb strings.Builder
-
-	// Add context from documents
-	sb.WriteString("Context information is below.\n")
-	sb.WriteString("---------------------\n")
-
-	for i, doc := range documents {
-		sb.WriteString(fmt.Sprintf("Document %d:\n%s\n\n", i+1, doc.Content))
+	augmented, err := s.buildPrompt(models.RAGQuery{Query: query}, results, nil)
+	if err != nil {
+		log.Printf("Failed to build prompt, using the query as is: %v", err)
+		return query
 	}
 
-	sb.WriteString("---------------------\n")
-	sb.WriteString("Given the context information and not prior knowledge, answer the following query:\n")
-	sb.WriteString(query)
-
-	return sb.String()
+	return augmented
 }
 
-// generateResponseWithGemini generates a response using Google's Gemini model
-func (s *DefaultRAGService) generateResponseWithGemini(ctx context.Context, query string) (string, error) {
-	// Create request body
-	reqBody := GeminiGenerationRequest{
-		Contents: []GeminiContent{
-			{
-				Parts: []GeminiPart{
-					{
-						Text: query,
-					},
-				},
-			},
-		},
-	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+// buildPrompt renders the prompt template selected by the request with the
+// query, the retrieved documents and the conversation history, labeling each
+// source so that it can be cited
+func (s *DefaultRAGService) buildPrompt(
+	request models.RAGQuery,
+	results []models.SearchResult,
+	history []models.ChatMessage,
+) (string, error) {
+	templates := s.templates
+	if templates == nil {
+		templates = prompt.NewTemplates()
 	}
 
-	// Create HTTP request
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1/models/%s:generateContent?key=%s",
-		s.geminiConfig.TextModel, s.geminiConfig.APIKey)
-
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	name, err := templates.Resolve(request.Template, request.Collection)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return "", err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-
-	// Send request
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+	data := prompt.Data{
+		Query:    request.Query,
+		Metadata: map[string]interface{}{"template": name},
+	}
+	if request.Collection != "" {
+		data.Metadata["collection"] = request.Collection
 	}
-	defer resp.Body.Close()
+	for i, result := range results {
+		data.Documents = append(data.Documents, prompt.Document{
+			Label:       sourceLabel(i),
+			Description: describeSource(result.Document),
+			Content:     result.Document.Content,
+			Metadata:    result.Document.Metadata,
+			Similarity:  result.Similarity,
+		})
+	}
+	for _, message := range history {
+		data.History = append(data.History, prompt.Message{Role: message.Role, Content: message.Content})
+	}
+
+	return templates.Render(name, data)
+}
 
-	// Read response
-	body, err := io.ReadAll(resp.Body)
+// promptTokens counts the tokens sent to the model besides the document
+// contents: the prompt rendered with empty documents, the system instruction
+// and the previous turns. Every result is rendered, so the count also covers
+// the labels of documents that packing drops. Previous turns are counted once,
+// as part of the prompt when the template renders .History.
+func (s *DefaultRAGService) promptTokens(
+	request models.RAGQuery,
+	results []models.SearchResult,
+	history []models.ChatMessage,
+	generation models.GenerationOptions,
+) (int, error) {
+	empty := make([]models.SearchResult, len(results))
+	for i, result := range results {
+		result.Document.Content = ""
+		empty[i] = result
+	}
+	rendered, err := s.buildPrompt(request, empty, history)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return 0, err
 	}
 
-	// Check status code
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Gemini API error response: %s", string(body))
-		return "", fmt.Errorf("API error (status %d)", resp.StatusCode)
+	tokens := s.tokenizer.CountTokens(rendered) + s.tokenizer.CountTokens(generation.SystemInstruction)
+	if len(history) == 0 {
+		return tokens, nil
 	}
 
-	// Parse response
-	var genResponse GeminiGenerationResponse
-	if err := json.Unmarshal(body, &genResponse); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	// The template renders .History when the prompt changes without it
+	withoutHistory, err := s.buildPrompt(request, empty, nil)
+	if err != nil {
+		return 0, err
 	}
-
-	// Extract text from response
-	if len(genResponse.Candidates) == 0 || len(genResponse.Candidates[0].Content.Parts) == 0 {
-		return "", fmt.Errorf("no content in response")
+	if withoutHistory == rendered {
+		for _, message := range history {
+			tokens += s.tokenizer.CountTokens(message.Content)
+		}
 	}
 
-	return genResponse.Candidates[0].Content.Parts[0].Text, nil
+	return tokens, nil
+}
+
+// generateResponseWithGemini generates a single-turn response with the configured generator
+func (s *DefaultRAGService) generateResponseWithGemini(
+	ctx context.Context,
+	query string,
+	options models.GenerationOptions,
+) (string, error) {
+	return s.generator.Generate(ctx, nil, query, options)
 }
@@ -2,11 +2,16 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
 
 	"github.com/yourusername/go-rag/internal/config"
+	"github.com/yourusername/go-rag/internal/database"
+	"github.com/yourusername/go-rag/internal/embeddings"
 	"github.com/yourusername/go-rag/internal/models"
 )
 
@@ -19,6 +24,17 @@ type MockVectorDB struct {
 	DeleteDocumentFunc func(ctx context.Context, id uuid.UUID) error
 	ConnectFunc        func(ctx context.Context) error
 	CloseFunc          func() error
+
+	StoreSourceFunc      func(ctx context.Context, source models.Source) error
+	ReplaceSourceFunc    func(ctx context.Context, source models.Source, docs []models.Document, embeddings [][]float32) error
+	GetSourceFunc        func(ctx context.Context, id uuid.UUID) (models.Source, error)
+	FindSourceByURIFunc  func(ctx context.Context, uri string) (models.Source, error)
+	ListSourcesFunc      func(ctx context.Context, limit, offset int) ([]models.Source, error)
+	ListSourceChunksFunc func(ctx context.Context, sourceID uuid.UUID) ([]models.Document, error)
+	DeleteSourceFunc     func(ctx context.Context, id uuid.UUID) error
+
+	ListSourceChunksInRangeFunc func(ctx context.Context, sourceID uuid.UUID, from, to int) ([]models.Document, error)
+	CorpusVersionFunc           func(ctx context.Context) (int64, error)
 }
 
 func (m *MockVectorDB) StoreDocument(ctx context.Context, doc models.Document, embedding []float32) error {
@@ -49,19 +65,55 @@ func (m *MockVectorDB) Close() error {
 	return m.CloseFunc()
 }
 
+func (m *MockVectorDB) StoreSource(ctx context.Context, source models.Source) error {
+	return m.StoreSourceFunc(ctx, source)
+}
+
+func (m *MockVectorDB) ReplaceSource(ctx context.Context, source models.Source, docs []models.Document, embeddings [][]float32) error {
+	return m.ReplaceSourceFunc(ctx, source, docs, embeddings)
+}
+
+func (m *MockVectorDB) GetSource(ctx context.Context, id uuid.UUID) (models.Source, error) {
+	return m.GetSourceFunc(ctx, id)
+}
+
+func (m *MockVectorDB) FindSourceByURI(ctx context.Context, uri string) (models.Source, error) {
+	return m.FindSourceByURIFunc(ctx, uri)
+}
+
+func (m *MockVectorDB) ListSources(ctx context.Context, limit, offset int) ([]models.Source, error) {
+	return m.ListSourcesFunc(ctx, limit, offset)
+}
+
+func (m *MockVectorDB) ListSourceChunks(ctx context.Context, sourceID uuid.UUID) ([]models.Document, error) {
+	return m.ListSourceChunksFunc(ctx, sourceID)
+}
+
+func (m *MockVectorDB) DeleteSource(ctx context.Context, id uuid.UUID) error {
+	return m.DeleteSourceFunc(ctx, id)
+}
+
+func (m *MockVectorDB) ListSourceChunksInRange(ctx context.Context, sourceID uuid.UUID, from, to int) ([]models.Document, error) {
+	return m.ListSourceChunksInRangeFunc(ctx, sourceID, from, to)
+}
+
+func (m *MockVectorDB) CorpusVersion(ctx context.Context) (int64, error) {
+	return m.CorpusVersionFunc(ctx)
+}
+
 // MockEmbeddingService is a mock implementation of the EmbeddingService interface
 type MockEmbeddingService struct {
-	GenerateEmbeddingFunc       func(ctx context.Context, text string) ([]float32, error)
-	BatchGenerateEmbeddingsFunc func(ctx context.Context, texts []string) ([][]float32, error)
+	GenerateEmbeddingFunc       func(ctx context.Context, text string, options embeddings.Options) ([]float32, error)
+	BatchGenerateEmbeddingsFunc func(ctx context.Context, texts []string, options embeddings.Options) ([][]float32, error)
 	CalculateSimilarityFunc     func(vec1, vec2 []float32) float32
 }
 
-func (m *MockEmbeddingService) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
-	return m.GenerateEmbeddingFunc(ctx, text)
+func (m *MockEmbeddingService) GenerateEmbedding(ctx context.Context, text string, options embeddings.Options) ([]float32, error) {
+	return m.GenerateEmbeddingFunc(ctx, text, options)
 }
 
-func (m *MockEmbeddingService) BatchGenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
-	return m.BatchGenerateEmbeddingsFunc(ctx, texts)
+func (m *MockEmbeddingService) BatchGenerateEmbeddings(ctx context.Context, texts []string, options embeddings.Options) ([][]float32, error) {
+	return m.BatchGenerateEmbeddingsFunc(ctx, texts, options)
 }
 
 func (m *MockEmbeddingService) CalculateSimilarity(vec1, vec2 []float32) float32 {
@@ -138,7 +190,7 @@ func TestAddDocument(t *testing.T) {
 	}
 
 	mockEmbedding := &MockEmbeddingService{
-		GenerateEmbeddingFunc: func(ctx context.Context, text string) ([]float32, error) {
+		GenerateEmbeddingFunc: func(ctx context.Context, text string, options embeddings.Options) ([]float32, error) {
 			// Validate input
 			if text != content {
 				t.Errorf("Expected text '%s', got '%s'", content, text)
@@ -213,7 +265,7 @@ func TestSearchSimilar(t *testing.T) {
 	}
 
 	mockEmbedding := &MockEmbeddingService{
-		GenerateEmbeddingFunc: func(ctx context.Context, text string) ([]float32, error) {
+		GenerateEmbeddingFunc: func(ctx context.Context, text string, options embeddings.Options) ([]float32, error) {
 			// Validate input
 			if text != query {
 				t.Errorf("Expected query '%s', got '%s'", query, text)
@@ -301,5 +353,118 @@ func TestAugmentQueryWithContext(t *testing.T) {
 
 // Helper function to check if a string contains a substring
 func contains(s, substr string) bool {
-	return s != "" && substr != "" && s != substr && len(s) > len(substr) && s != substr
+	return s != "" && substr != "" && len(s) > len(substr) && strings.Contains(s, substr)
+}
+
+// TestListSourceChunks tests the ListSourceChunks method
+func TestListSourceChunks(t *testing.T) {
+	sourceID := uuid.New()
+
+	mockDB := &MockVectorDB{
+		GetSourceFunc: func(ctx context.Context, id uuid.UUID) (models.Source, error) {
+			if id != sourceID {
+				return models.Source{}, database.ErrSourceNotFound
+			}
+			return models.Source{ID: sourceID}, nil
+		},
+		ListSourceChunksFunc: func(ctx context.Context, id uuid.UUID) ([]models.Document, error) {
+			return []models.Document{models.NewSourceDocument(id, 0, "chunk", nil)}, nil
+		},
+	}
+
+	mockConfig := &config.GeminiConfig{APIKey: "test-api-key"}
+	service, _ := NewRAGService(mockDB, &MockEmbeddingService{}, mockConfig)
+
+	ctx := context.Background()
+	chunks, err := service.ListSourceChunks(ctx, sourceID)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if len(chunks) != 1 {
+		t.Errorf("Expected 1 chunk, got %d", len(chunks))
+	}
+
+	// Unknown sources are reported with ErrSourceNotFound
+	_, err = service.ListSourceChunks(ctx, uuid.New())
+	if !errors.Is(err, database.ErrSourceNotFound) {
+		t.Errorf("Expected ErrSourceNotFound, got %v", err)
+	}
+}
+
+// TestDeleteSource tests the DeleteSource method
+func TestDeleteSource(t *testing.T) {
+	sourceID := uuid.New()
+	var deletedID uuid.UUID
+
+	mockDB := &MockVectorDB{
+		DeleteSourceFunc: func(ctx context.Context, id uuid.UUID) error {
+			deletedID = id
+			return nil
+		},
+	}
+
+	mockConfig := &config.GeminiConfig{APIKey: "test-api-key"}
+	service, _ := NewRAGService(mockDB, &MockEmbeddingService{}, mockConfig)
+
+	if err := service.DeleteSource(context.Background(), sourceID); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if deletedID != sourceID {
+		t.Errorf("Expected source %s to be deleted, got %s", sourceID, deletedID)
+	}
+}
+
+// TestNewGenerationRequest tests building the Gemini request from generation options
+func TestNewGenerationRequest(t *testing.T) {
+	// Without options only the contents are sent
+	plain, _ := json.Marshal(newGenerationRequest("test query", models.GenerationOptions{}))
+	if string(plain) != `{"contents":[{"parts":[{"text":"test query"}]}]}` {
+		t.Errorf("Unexpected request body: %s", plain)
+	}
+
+	temperature, maxTokens := 0.0, 256
+	reqBody := newGenerationRequest("test query", models.GenerationOptions{
+		Temperature:       &temperature,
+		MaxOutputTokens:   &maxTokens,
+		StopSequences:     []string{"END"},
+		SystemInstruction: "Answer formally.",
+		SafetySettings: []models.SafetySetting{
+			{Category: "HARM_CATEGORY_HARASSMENT", Threshold: "BLOCK_ONLY_HIGH"},
+		},
+	})
+
+	body, _ := json.Marshal(reqBody)
+	for _, expected := range []string{
+		`"systemInstruction":{"parts":[{"text":"Answer formally."}]}`,
+		`"generationConfig":{"temperature":0,"maxOutputTokens":256,"stopSequences":["END"]}`,
+		`"safetySettings":[{"category":"HARM_CATEGORY_HARASSMENT","threshold":"BLOCK_ONLY_HIGH"}]`,
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Expected request body to contain %s, got %s", expected, body)
+		}
+	}
+}
+
+// TestQueryWithInvalidGenerationOptions tests that invalid options are rejected before retrieval
+func TestQueryWithInvalidGenerationOptions(t *testing.T) {
+	mockEmbedding := &MockEmbeddingService{
+		GenerateEmbeddingFunc: func(ctx context.Context, text string, options embeddings.Options) ([]float32, error) {
+			t.Error("Expected no retrieval with invalid generation options")
+			return nil, errors.New("unexpected call")
+		},
+	}
+
+	mockConfig := &config.GeminiConfig{APIKey: "test-api-key"}
+	service, _ := NewRAGService(&MockVectorDB{}, mockEmbedding, mockConfig)
+
+	temperature := 3.0
+	_, err := service.QueryWithOptions(context.Background(), models.RAGQuery{
+		Query:      "test query",
+		Generation: &models.GenerationOptions{Temperature: &temperature},
+	})
+	if !errors.Is(err, models.ErrInvalidGenerationOptions) {
+		t.Errorf("Expected ErrInvalidGenerationOptions, got %v", err)
+	}
 }
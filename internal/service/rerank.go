@@ -0,0 +1,397 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/yourusername/go-rag/internal/config"
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// Reranker types selected by configuration
+const (
+	RerankerNone         = "none"
+	RerankerLLMPointwise = "llm_pointwise"
+	RerankerLLMListwise  = "llm_listwise"
+	RerankerCrossEncoder = "cross_encoder"
+)
+
+// defaultRerankConcurrency is the number of documents graded at once by the pointwise reranker
+const defaultRerankConcurrency = 4
+
+// maxRerankDocumentLength is the number of characters of a document shown to the LLM reranker
+const maxRerankDocumentLength = 2000
+
+// pointwiseRerankPrompt asks the model to grade the relevance of a single document
+const pointwiseRerankPrompt = `Rate how relevant the document below is for answering the question on a scale from 0 (not relevant) to 10 (fully answers the question).
+Only return the number.
+
+Question: %s
+
+Document:
+%s
+
+Relevance:`
+
+// listwiseRerankPrompt asks the model to order labeled documents by relevance
+const listwiseRerankPrompt = `Rank the documents below by how relevant they are for answering the question, most relevant first.
+Only return the document labels separated by commas, e.g. S2, S1, S3.
+
+Question: %s
+
+%s
+Ranking:`
+
+// scorePattern matches the first number in a pointwise rating
+var scorePattern = regexp.MustCompile(`\d+(?:\.\d+)?`)
+
+// rankingLabelPattern matches a source label in a listwise ranking
+var rankingLabelPattern = regexp.MustCompile(`S(\d+)`)
+
+// Reranker reorders retrieved documents by their relevance to the query
+type Reranker interface {
+	// Rerank sets the RerankScore of the results and returns them ordered by
+	// descending score
+	Rerank(ctx context.Context, query string, results []models.SearchResult) ([]models.SearchResult, error)
+}
+
+// NewReranker creates the reranker selected by the configuration. No reranker
+// is returned when reranking is disabled.
+func NewReranker(cfg config.RerankerConfig, generator Generator) (Reranker, error) {
+	switch cfg.Type {
+	case "", RerankerNone:
+		return nil, nil
+	case RerankerLLMPointwise:
+		return NewLLMReranker(generator, false, cfg.Concurrency), nil
+	case RerankerLLMListwise:
+		return NewLLMReranker(generator, true, cfg.Concurrency), nil
+	case RerankerCrossEncoder:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("cross-encoder reranker requires a URL")
+		}
+		return NewCrossEncoderReranker(cfg.URL), nil
+	default:
+		return nil, fmt.Errorf("unknown reranker type %q", cfg.Type)
+	}
+}
+
+// RerankReport describes the reranking of the retrieved candidates
+type RerankReport struct {
+	Candidates int              `json:"candidates"`
+	Results    []RerankedResult `json:"results"`
+	// Error is set when reranking failed and the retrieval order was kept
+	Error string `json:"error,omitempty"`
+}
+
+// RerankedResult reports the rerank score of a candidate and how its rank changed
+type RerankedResult struct {
+	DocumentID   uuid.UUID `json:"document_id"`
+	Similarity   float32   `json:"similarity"`
+	RerankScore  float32   `json:"rerank_score"`
+	Rank         int       `json:"rank"`
+	OriginalRank int       `json:"original_rank"`
+}
+
+// rerank reorders the candidates with the configured reranker and keeps the
+// best limit results. The retrieval order is kept when reranking fails.
+func (s *DefaultRAGService) rerank(
+	ctx context.Context,
+	query string,
+	candidates []models.SearchResult,
+	limit int,
+) ([]models.SearchResult, *RerankReport) {
+	report := &RerankReport{Candidates: len(candidates)}
+
+	reranked, err := s.reranker.Rerank(ctx, query, candidates)
+	if err != nil {
+		log.Printf("Failed to rerank documents, keeping the retrieval order: %v", err)
+		report.Error = err.Error()
+		reranked = candidates
+	}
+
+	originalRanks := make(map[uuid.UUID]int, len(candidates))
+	for i, result := range candidates {
+		originalRanks[result.Document.ID] = i + 1
+	}
+	for i, result := range reranked {
+		entry := RerankedResult{
+			DocumentID:   result.Document.ID,
+			Similarity:   result.Similarity,
+			Rank:         i + 1,
+			OriginalRank: originalRanks[result.Document.ID],
+		}
+		if result.RerankScore != nil {
+			entry.RerankScore = *result.RerankScore
+		}
+		report.Results = append(report.Results, entry)
+	}
+
+	if limit > 0 && len(reranked) > limit {
+		reranked = reranked[:limit]
+	}
+
+	return reranked, report
+}
+
+// LLMReranker reranks documents with a language model, either by grading each
+// document separately (pointwise) or by ordering all documents at once (listwise)
+type LLMReranker struct {
+	generator Generator
+	listwise  bool
+	// concurrency limits the documents graded at once when grading pointwise
+	concurrency int
+}
+
+// NewLLMReranker creates a new LLM-based reranker grading up to concurrency
+// documents at once
+func NewLLMReranker(generator Generator, listwise bool, concurrency int) *LLMReranker {
+	if concurrency <= 0 {
+		concurrency = defaultRerankConcurrency
+	}
+	return &LLMReranker{generator: generator, listwise: listwise, concurrency: concurrency}
+}
+
+// Rerank scores the results with the language model
+func (r *LLMReranker) Rerank(ctx context.Context, query string, results []models.SearchResult) ([]models.SearchResult, error) {
+	if len(results) == 0 {
+		return results, nil
+	}
+	if r.listwise {
+		return r.rerankListwise(ctx, query, results)
+	}
+	return r.rerankPointwise(ctx, query, results)
+}
+
+// rerankPointwise grades the documents with up to r.concurrency requests at
+// once and scales the grades to [0, 1]
+func (r *LLMReranker) rerankPointwise(ctx context.Context, query string, results []models.SearchResult) ([]models.SearchResult, error) {
+	options := rerankGenerationOptions()
+
+	scores := make([]float32, len(results))
+	errs := make([]error, len(results))
+	semaphore := make(chan struct{}, r.concurrency)
+	var wg sync.WaitGroup
+	for i, result := range results {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int, content string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			rating, err := r.generator.Generate(ctx, nil, fmt.Sprintf(pointwiseRerankPrompt, query, truncateRunes(content, maxRerankDocumentLength)), options)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			scores[i] = parseRelevanceRating(rating)
+		}(i, result.Document.Content)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to grade document: %w", err)
+		}
+	}
+
+	reranked := append([]models.SearchResult{}, results...)
+	for i := range reranked {
+		score := scores[i]
+		reranked[i].RerankScore = &score
+	}
+	sortByRerankScore(reranked)
+
+	return reranked, nil
+}
+
+// rerankListwise asks for an ordering of all documents and scores them by
+// their position. Documents missing from the ordering keep their retrieval
+// order after the ranked ones.
+func (r *LLMReranker) rerankListwise(ctx context.Context, query string, results []models.SearchResult) ([]models.SearchResult, error) {
+	var documents strings.Builder
+	for i, result := range results {
+		documents.WriteString(fmt.Sprintf("[%s] %s\n\n", sourceLabel(i), truncateRunes(result.Document.Content, maxRerankDocumentLength)))
+	}
+
+	ranking, err := r.generator.Generate(ctx, nil, fmt.Sprintf(listwiseRerankPrompt, query, documents.String()), rerankGenerationOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to rank documents: %w", err)
+	}
+
+	order := parseRanking(ranking, len(results))
+	reranked := make([]models.SearchResult, 0, len(results))
+	for position, i := range order {
+		result := results[i]
+		score := float32(len(results)-position) / float32(len(results))
+		result.RerankScore = &score
+		reranked = append(reranked, result)
+	}
+
+	return reranked, nil
+}
+
+// rerankGenerationOptions returns deterministic generation options for grading documents
+func rerankGenerationOptions() models.GenerationOptions {
+	temperature := 0.0
+	return models.GenerationOptions{Temperature: &temperature}
+}
+
+// parseRelevanceRating extracts a 0-10 rating from generated text and scales it
+// to [0, 1]; text without a rating scores zero
+func parseRelevanceRating(text string) float32 {
+	rating, err := strconv.ParseFloat(scorePattern.FindString(text), 32)
+	if err != nil {
+		return 0
+	}
+	if rating > 10 {
+		rating = 10
+	}
+	return float32(rating / 10)
+}
+
+// parseRanking returns the indexes of n documents in the order of the source
+// labels in text, followed by the documents that were not ranked
+func parseRanking(text string, n int) []int {
+	seen := make(map[int]bool, n)
+	order := make([]int, 0, n)
+	for _, match := range rankingLabelPattern.FindAllStringSubmatch(text, -1) {
+		label, err := strconv.Atoi(match[1])
+		if err != nil || label < 1 || label > n || seen[label-1] {
+			continue
+		}
+		seen[label-1] = true
+		order = append(order, label-1)
+	}
+
+	for i := 0; i < n; i++ {
+		if !seen[i] {
+			order = append(order, i)
+		}
+	}
+
+	return order
+}
+
+// CrossEncoderReranker reranks documents with a cross-encoder served by a
+// Text Embeddings Inference compatible /rerank endpoint
+type CrossEncoderReranker struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// crossEncoderRequest represents a request to the /rerank endpoint
+type crossEncoderRequest struct {
+	Query    string   `json:"query"`
+	Texts    []string `json:"texts"`
+	Truncate bool     `json:"truncate"`
+}
+
+// crossEncoderScore represents the score of a text in a /rerank response
+type crossEncoderScore struct {
+	Index int     `json:"index"`
+	Score float32 `json:"score"`
+}
+
+// NewCrossEncoderReranker creates a new cross-encoder reranker for the server at baseURL
+func NewCrossEncoderReranker(baseURL string) *CrossEncoderReranker {
+	return &CrossEncoderReranker{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// Rerank scores the results with the cross-encoder
+func (r *CrossEncoderReranker) Rerank(ctx context.Context, query string, results []models.SearchResult) ([]models.SearchResult, error) {
+	if len(results) == 0 {
+		return results, nil
+	}
+
+	reqBody := crossEncoderRequest{Query: query, Truncate: true}
+	for _, result := range results {
+		reqBody.Texts = append(reqBody.Texts, result.Document.Content)
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", r.baseURL+"/rerank", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Cross-encoder error response: %s", string(body))
+		return nil, fmt.Errorf("API error (status %d)", resp.StatusCode)
+	}
+
+	var scores []crossEncoderScore
+	if err := json.Unmarshal(body, &scores); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if len(scores) != len(results) {
+		return nil, fmt.Errorf("expected %d scores, got %d", len(results), len(scores))
+	}
+
+	reranked := append([]models.SearchResult{}, results...)
+	scored := make([]bool, len(reranked))
+	for _, s := range scores {
+		if s.Index < 0 || s.Index >= len(reranked) {
+			return nil, fmt.Errorf("score for unknown text %d", s.Index)
+		}
+		// With as many scores as texts, a duplicate leaves another text unscored
+		if scored[s.Index] {
+			return nil, fmt.Errorf("duplicate score for text %d", s.Index)
+		}
+		scored[s.Index] = true
+		score := s.Score
+		reranked[s.Index].RerankScore = &score
+	}
+	sortByRerankScore(reranked)
+
+	return reranked, nil
+}
+
+// sortByRerankScore orders results by descending rerank score, keeping the
+// retrieval order for equal scores
+func sortByRerankScore(results []models.SearchResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return *results[i].RerankScore > *results[j].RerankScore
+	})
+}
+
+// truncateRunes cuts text to at most n characters
+func truncateRunes(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n])
+}
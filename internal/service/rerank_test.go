@@ -0,0 +1,379 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yourusername/go-rag/internal/config"
+	"github.com/yourusername/go-rag/internal/embeddings"
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// MockGenerator is a mock implementation of the Generator interface
+type MockGenerator struct {
+	GenerateFunc func(ctx context.Context, history []models.ChatMessage, prompt string, options models.GenerationOptions) (string, error)
+}
+
+func (m *MockGenerator) Generate(ctx context.Context, history []models.ChatMessage, prompt string, options models.GenerationOptions) (string, error) {
+	return m.GenerateFunc(ctx, history, prompt, options)
+}
+
+// rerankTestResults returns search results with the given contents in decreasing similarity
+func rerankTestResults(contents ...string) []models.SearchResult {
+	results := make([]models.SearchResult, len(contents))
+	for i, content := range contents {
+		results[i] = models.SearchResult{
+			Document:   models.NewDocument(content, nil),
+			Similarity: 0.9 - float32(i)*0.1,
+		}
+	}
+	return results
+}
+
+// TestCrossEncoderReranker tests reranking with a TEI-compatible /rerank endpoint
+func TestCrossEncoderReranker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rerank" {
+			t.Errorf("Expected /rerank, got %s", r.URL.Path)
+		}
+
+		var request crossEncoderRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		if request.Query != "go install" || len(request.Texts) != 3 {
+			t.Errorf("Unexpected request %+v", request)
+		}
+
+		// TEI returns the scores ordered by score
+		json.NewEncoder(w).Encode([]crossEncoderScore{
+			{Index: 2, Score: 0.9},
+			{Index: 0, Score: 0.5},
+			{Index: 1, Score: 0.1},
+		})
+	}))
+	defer server.Close()
+
+	results := rerankTestResults("a", "b", "c")
+	reranked, err := NewCrossEncoderReranker(server.URL+"/").Rerank(context.Background(), "go install", results)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []string{"c", "a", "b"}
+	for i, content := range expected {
+		if reranked[i].Document.Content != content {
+			t.Errorf("Position %d: expected %q, got %q", i, content, reranked[i].Document.Content)
+		}
+	}
+	if reranked[0].RerankScore == nil || *reranked[0].RerankScore != 0.9 {
+		t.Errorf("Expected rerank score 0.9, got %v", reranked[0].RerankScore)
+	}
+
+	// The input results are not modified
+	if results[0].RerankScore != nil {
+		t.Errorf("Expected original results to be unchanged")
+	}
+}
+
+// TestCrossEncoderRerankerError tests that server errors are reported
+func TestCrossEncoderRerankerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	_, err := NewCrossEncoderReranker(server.URL).Rerank(context.Background(), "q", rerankTestResults("a"))
+	if err == nil {
+		t.Errorf("Expected an error for a failing server")
+	}
+}
+
+// TestCrossEncoderRerankerDuplicateIndex tests that a response scoring a text
+// twice, and therefore another text not at all, is rejected
+func TestCrossEncoderRerankerDuplicateIndex(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]crossEncoderScore{
+			{Index: 0, Score: 0.9},
+			{Index: 0, Score: 0.5},
+			{Index: 1, Score: 0.1},
+		})
+	}))
+	defer server.Close()
+
+	_, err := NewCrossEncoderReranker(server.URL).Rerank(context.Background(), "q", rerankTestResults("a", "b", "c"))
+	if err == nil {
+		t.Errorf("Expected an error for a duplicate index")
+	}
+}
+
+// TestLLMRerankerPointwiseConcurrency tests that no more than the configured
+// number of documents are graded at once
+func TestLLMRerankerPointwiseConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	generator := &MockGenerator{
+		GenerateFunc: func(ctx context.Context, history []models.ChatMessage, prompt string, options models.GenerationOptions) (string, error) {
+			mu.Lock()
+			inFlight++
+			if inFlight > maxInFlight {
+				maxInFlight = inFlight
+			}
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			return "5", nil
+		},
+	}
+
+	contents := make([]string, 20)
+	for i := range contents {
+		contents[i] = fmt.Sprintf("document %d", i)
+	}
+	reranked, err := NewLLMReranker(generator, false, 3).Rerank(context.Background(), "q", rerankTestResults(contents...))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(reranked) != len(contents) {
+		t.Errorf("Expected %d results, got %d", len(contents), len(reranked))
+	}
+	if maxInFlight > 3 {
+		t.Errorf("Expected at most 3 concurrent gradings, got %d", maxInFlight)
+	}
+}
+
+// TestLLMRerankerPointwise tests grading every document with the generator
+func TestLLMRerankerPointwise(t *testing.T) {
+	generator := &MockGenerator{
+		GenerateFunc: func(ctx context.Context, history []models.ChatMessage, prompt string, options models.GenerationOptions) (string, error) {
+			if options.Temperature == nil || *options.Temperature != 0 {
+				t.Errorf("Expected deterministic generation options")
+			}
+			switch {
+			case strings.Contains(prompt, "tarball"):
+				return "9", nil
+			case strings.Contains(prompt, "weather"):
+				return "Relevance: 1/10", nil
+			default:
+				return "no idea", nil
+			}
+		},
+	}
+
+	results := rerankTestResults("It is sunny weather.", "Unrelated.", "Extract the tarball.")
+	reranked, err := NewLLMReranker(generator, false, 0).Rerank(context.Background(), "How to install Go?", results)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []struct {
+		content string
+		score   float32
+	}{
+		{"Extract the tarball.", 0.9},
+		{"It is sunny weather.", 0.1},
+		{"Unrelated.", 0},
+	}
+	for i, e := range expected {
+		if reranked[i].Document.Content != e.content || *reranked[i].RerankScore != e.score {
+			t.Errorf("Position %d: expected %q (%v), got %q (%v)", i, e.content, e.score, reranked[i].Document.Content, *reranked[i].RerankScore)
+		}
+	}
+}
+
+// TestLLMRerankerListwise tests ordering the documents by a generated ranking
+func TestLLMRerankerListwise(t *testing.T) {
+	calls := 0
+	generator := &MockGenerator{
+		GenerateFunc: func(ctx context.Context, history []models.ChatMessage, prompt string, options models.GenerationOptions) (string, error) {
+			calls++
+			if !strings.Contains(prompt, "[S3] c") {
+				t.Errorf("Expected labeled documents in prompt, got %q", prompt)
+			}
+			return "S3, S1", nil
+		},
+	}
+
+	reranked, err := NewLLMReranker(generator, true, 0).Rerank(context.Background(), "q", rerankTestResults("a", "b", "c"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected a single generation, got %d", calls)
+	}
+
+	// Unranked documents follow the ranked ones
+	var order []string
+	for _, result := range reranked {
+		order = append(order, result.Document.Content)
+	}
+	if strings.Join(order, ",") != "c,a,b" {
+		t.Errorf("Expected order c,a,b, got %v", order)
+	}
+	if *reranked[0].RerankScore <= *reranked[1].RerankScore || *reranked[1].RerankScore <= *reranked[2].RerankScore {
+		t.Errorf("Expected decreasing rerank scores")
+	}
+}
+
+// TestParseRanking tests extracting document indexes from a listwise ranking
+func TestParseRanking(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []int
+	}{
+		{"S2, S1, S3", []int{1, 0, 2}},
+		{"[S3] > [S3] > [S7] > [S1]", []int{2, 0, 1}},
+		{"I cannot rank these.", []int{0, 1, 2}},
+	}
+
+	for _, test := range tests {
+		if got := parseRanking(test.text, 3); fmt.Sprint(got) != fmt.Sprint(test.expected) {
+			t.Errorf("parseRanking(%q) = %v, expected %v", test.text, got, test.expected)
+		}
+	}
+}
+
+// TestNewReranker tests selecting the reranker by configuration
+func TestNewReranker(t *testing.T) {
+	if reranker, err := NewReranker(config.RerankerConfig{Type: RerankerNone}, nil); reranker != nil || err != nil {
+		t.Errorf("Expected no reranker, got %v (%v)", reranker, err)
+	}
+	if reranker, _ := NewReranker(config.RerankerConfig{Type: RerankerLLMListwise}, &MockGenerator{}); reranker == nil {
+		t.Errorf("Expected an LLM reranker")
+	}
+	if _, err := NewReranker(config.RerankerConfig{Type: RerankerCrossEncoder}, nil); err == nil {
+		t.Errorf("Expected an error without cross-encoder URL")
+	}
+	if _, err := NewReranker(config.RerankerConfig{Type: "bogus"}, nil); err == nil {
+		t.Errorf("Expected an error for an unknown type")
+	}
+}
+
+// MockReranker reverses the order of the results and scores them by position
+type MockReranker struct {
+	err error
+}
+
+func (m *MockReranker) Rerank(ctx context.Context, query string, results []models.SearchResult) ([]models.SearchResult, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	reranked := make([]models.SearchResult, len(results))
+	for i, result := range results {
+		score := float32(i+1) / float32(len(results))
+		result.RerankScore = &score
+		reranked[len(results)-1-i] = result
+	}
+	return reranked, nil
+}
+
+// TestQueryWithReranker tests over-fetching candidates and keeping the best reranked ones
+func TestQueryWithReranker(t *testing.T) {
+	candidates := rerankTestResults("a", "b", "c", "d", "e", "f")
+
+	var retrieveLimit int
+	mockEmbedding := &MockEmbeddingService{
+		GenerateEmbeddingFunc: func(ctx context.Context, text string, options embeddings.Options) ([]float32, error) {
+			return []float32{0.1, 0.2}, nil
+		},
+	}
+	mockDB := &MockVectorDB{
+		FindSimilarFunc: func(ctx context.Context, query models.VectorQuery) ([]models.SearchResult, error) {
+			retrieveLimit = query.Limit
+			return candidates, nil
+		},
+	}
+	generator := &MockGenerator{
+		GenerateFunc: func(ctx context.Context, history []models.ChatMessage, prompt string, options models.GenerationOptions) (string, error) {
+			return "answer", nil
+		},
+	}
+
+	mockConfig := &config.GeminiConfig{APIKey: "test-api-key", TextModel: "test-model"}
+	reranker := &MockReranker{}
+	ragService, _ := NewRAGService(mockDB, mockEmbedding, mockConfig, WithGenerator(generator), WithReranker(reranker, 6))
+
+	response, err := ragService.QueryWithOptions(context.Background(), models.RAGQuery{Query: "q", Limit: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if retrieveLimit != 6 {
+		t.Errorf("Expected 6 candidates to be retrieved, got %d", retrieveLimit)
+	}
+	if len(response.Documents) != 2 || response.Documents[0].Content != "f" || response.Documents[1].Content != "e" {
+		t.Errorf("Expected the two best reranked documents, got %+v", response.Documents)
+	}
+
+	report := response.Metadata.(map[string]interface{})["rerank"].(*RerankReport)
+	if report.Candidates != 6 || len(report.Results) != 6 || report.Results[0].OriginalRank != 6 {
+		t.Errorf("Unexpected rerank report %+v", report)
+	}
+
+	// The per-request number of candidates overrides the configured one
+	ragService.QueryWithOptions(context.Background(), models.RAGQuery{Query: "q", Limit: 2, RerankCandidates: 10})
+	if retrieveLimit != 10 {
+		t.Errorf("Expected 10 candidates to be retrieved, got %d", retrieveLimit)
+	}
+
+	// Reranking failures keep the retrieval order
+	reranker.err = errors.New("reranker down")
+	response, err = ragService.QueryWithOptions(context.Background(), models.RAGQuery{Query: "q", Limit: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if response.Documents[0].Content != "a" {
+		t.Errorf("Expected the retrieval order, got %+v", response.Documents)
+	}
+	report = response.Metadata.(map[string]interface{})["rerank"].(*RerankReport)
+	if report.Error == "" {
+		t.Errorf("Expected the rerank error to be reported")
+	}
+}
+
+// TestRetrieveWithReranker tests that retrieval without generation selects the reranked documents
+func TestRetrieveWithReranker(t *testing.T) {
+	mockEmbedding := &MockEmbeddingService{
+		GenerateEmbeddingFunc: func(ctx context.Context, text string, options embeddings.Options) ([]float32, error) {
+			return []float32{0.1, 0.2}, nil
+		},
+	}
+	mockDB := &MockVectorDB{
+		FindSimilarFunc: func(ctx context.Context, query models.VectorQuery) ([]models.SearchResult, error) {
+			return rerankTestResults("a", "b", "c", "d"), nil
+		},
+	}
+	generator := &MockGenerator{
+		GenerateFunc: func(ctx context.Context, history []models.ChatMessage, prompt string, options models.GenerationOptions) (string, error) {
+			t.Error("Expected no answer to be generated")
+			return "", nil
+		},
+	}
+
+	mockConfig := &config.GeminiConfig{APIKey: "test-api-key", TextModel: "test-model"}
+	ragService, _ := NewRAGService(mockDB, mockEmbedding, mockConfig, WithGenerator(generator), WithReranker(&MockReranker{}, 4))
+
+	results, err := ragService.Retrieve(context.Background(), models.RAGQuery{Query: "q", Limit: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(results) != 2 || results[0].Document.Content != "d" || results[1].Document.Content != "c" {
+		t.Errorf("Expected the two best reranked documents, got %+v", results)
+	}
+
+	if _, err := ragService.Retrieve(context.Background(), models.RAGQuery{}); err == nil {
+		t.Error("Expected error for an empty query")
+	}
+}
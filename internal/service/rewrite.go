@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// maxRewrittenQueries caps the number of alternative queries generated for a request
+const maxRewrittenQueries = 5
+
+// rrfK is the rank constant of reciprocal rank fusion; larger values flatten
+// the difference between top and lower ranks
+const rrfK = 60
+
+// rewritePrompt asks the model for alternative formulations of a query
+const rewritePrompt = `Generate %d different search queries that help answer the question below.
+Use paraphrases with different wording and, for complex questions, simpler sub-questions.
+Write each query on its own line without numbering or explanations.
+
+Question: %s`
+
+// listMarkerPattern matches numbering and bullets at the start of a generated line
+var listMarkerPattern = regexp.MustCompile(`^\s*(?:\d+[.)]|[-*•])\s*`)
+
+// QueryRewrite describes the queries used for multi-query retrieval
+type QueryRewrite struct {
+	Queries []string      `json:"queries"`
+	Fused   []FusedResult `json:"fused"`
+}
+
+// FusedResult reports the reciprocal rank fusion score of a retrieved document
+type FusedResult struct {
+	DocumentID uuid.UUID `json:"document_id"`
+	Score      float64   `json:"score"`
+	// Ranks maps each query index to the 1-based rank of the document in its results
+	Ranks map[int]int `json:"ranks"`
+}
+
+// multiQuerySearch retrieves documents for the query and n generated
+// alternatives concurrently and fuses the results with reciprocal rank fusion.
+// Alternatives whose search fails are skipped.
+func (s *DefaultRAGService) multiQuerySearch(
+	ctx context.Context,
+	query string,
+	n int,
+	limit int,
+	options models.RetrievalOptions,
+) ([]models.SearchResult, *QueryRewrite, error) {
+	queries := append([]string{query}, s.rewriteQuery(ctx, query, n)...)
+
+	// Search every query concurrently
+	resultLists := make([][]models.SearchResult, len(queries))
+	errs := make([]error, len(queries))
+	var wg sync.WaitGroup
+	for i, q := range queries {
+		wg.Add(1)
+		go func(i int, q string) {
+			defer wg.Done()
+			resultLists[i], errs[i] = s.SearchWithOptions(ctx, q, limit, options)
+		}(i, q)
+	}
+	wg.Wait()
+
+	// Only the original query is required; failed alternatives are skipped
+	if errs[0] != nil {
+		return nil, nil, fmt.Errorf("failed to search query %q: %w", query, errs[0])
+	}
+	for i, err := range errs[1:] {
+		if err != nil {
+			log.Printf("Failed to search rewritten query %q, skipping it: %v", queries[i+1], err)
+			resultLists[i+1] = nil
+		}
+	}
+
+	if limit <= 0 {
+		limit = 5 // Default limit
+	}
+	results, fused := fuseResults(resultLists, limit)
+
+	return results, &QueryRewrite{Queries: queries, Fused: fused}, nil
+}
+
+// rewriteQuery asks the model for up to n alternative queries. No alternatives
+// are returned when generation fails, so retrieval falls back to the query.
+func (s *DefaultRAGService) rewriteQuery(ctx context.Context, query string, n int) []string {
+	if n > maxRewrittenQueries {
+		n = maxRewrittenQueries
+	}
+
+	options := models.GenerationOptions{SafetySettings: s.geminiConfig.Generation.SafetySettings}
+	generated, err := s.generateResponseWithGemini(ctx, fmt.Sprintf(rewritePrompt, n, query), options)
+	if err != nil {
+		log.Printf("Failed to rewrite query, searching the original query only: %v", err)
+		return nil
+	}
+
+	return parseRewrittenQueries(generated, query, n)
+}
+
+// parseRewrittenQueries extracts up to n distinct queries from generated
+// text, one per line, skipping the original query
+func parseRewrittenQueries(text, original string, n int) []string {
+	seen := map[string]bool{strings.ToLower(strings.TrimSpace(original)): true}
+
+	var queries []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(listMarkerPattern.ReplaceAllString(line, ""))
+		line = strings.Trim(line, `"`)
+		key := strings.ToLower(line)
+		if line == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		queries = append(queries, line)
+		if len(queries) == n {
+			break
+		}
+	}
+
+	return queries
+}
+
+// fuseResults merges ranked result lists with reciprocal rank fusion: every
+// document scores the sum of 1/(rrfK+rank) over the lists it appears in. The
+// fused results keep the highest similarity of each document.
+func fuseResults(resultLists [][]models.SearchResult, limit int) ([]models.SearchResult, []FusedResult) {
+	type candidate struct {
+		result models.SearchResult
+		fused  FusedResult
+		order  int
+	}
+
+	candidates := make(map[uuid.UUID]*candidate)
+	for i, results := range resultLists {
+		for rank, result := range results {
+			id := result.Document.ID
+			c, ok := candidates[id]
+			if !ok {
+				c = &candidate{
+					result: result,
+					fused:  FusedResult{DocumentID: id, Ranks: make(map[int]int)},
+					order:  len(candidates),
+				}
+				candidates[id] = c
+			}
+
+			c.fused.Score += 1 / float64(rrfK+rank+1)
+			c.fused.Ranks[i] = rank + 1
+			if result.Similarity > c.result.Similarity {
+				c.result.Similarity = result.Similarity
+			}
+		}
+	}
+
+	// Order by fused score, keeping first-seen order for ties
+	ordered := make([]*candidate, 0, len(candidates))
+	for _, c := range candidates {
+		ordered = append(ordered, c)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].fused.Score != ordered[j].fused.Score {
+			return ordered[i].fused.Score > ordered[j].fused.Score
+		}
+		return ordered[i].order < ordered[j].order
+	})
+
+	if limit > 0 && len(ordered) > limit {
+		ordered = ordered[:limit]
+	}
+
+	results := make([]models.SearchResult, len(ordered))
+	fused := make([]FusedResult, len(ordered))
+	for i, c := range ordered {
+		results[i] = c.result
+		results[i].Score = float32(c.fused.Score)
+		fused[i] = c.fused
+	}
+
+	return results, fused
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yourusername/go-rag/internal/config"
+	"github.com/yourusername/go-rag/internal/embeddings"
+	"github.com/yourusername/go-rag/internal/models"
+)
+
+// TestParseRewrittenQueries tests extracting generated queries
+func TestParseRewrittenQueries(t *testing.T) {
+	text := "1. How to install Go on Linux?\n- \"Go installation steps\"\n\nhow do i install go?\n2) Go installation steps\n3. Setting up GOPATH"
+
+	queries := parseRewrittenQueries(text, "How do I install Go?", 5)
+
+	expected := []string{"How to install Go on Linux?", "Go installation steps", "Setting up GOPATH"}
+	if strings.Join(queries, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected %v, got %v", expected, queries)
+	}
+
+	// At most n queries are returned
+	if queries := parseRewrittenQueries(text, "", 2); len(queries) != 2 {
+		t.Errorf("Expected 2 queries, got %d", len(queries))
+	}
+}
+
+// TestFuseResults tests reciprocal rank fusion of result lists
+func TestFuseResults(t *testing.T) {
+	a := models.NewDocument("a", nil)
+	b := models.NewDocument("b", nil)
+	c := models.NewDocument("c", nil)
+
+	lists := [][]models.SearchResult{
+		{{Document: a, Similarity: 0.9}, {Document: b, Similarity: 0.8}},
+		{{Document: b, Similarity: 0.85}, {Document: c, Similarity: 0.7}},
+		{{Document: c, Similarity: 0.6}, {Document: b, Similarity: 0.5}},
+	}
+
+	results, fused := fuseResults(lists, 2)
+
+	// b appears in every list and wins, a and c tie on one first rank and one second rank
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].Document.ID != b.ID || results[1].Document.ID != c.ID {
+		t.Errorf("Expected b then c, got %s then %s", results[0].Document.Content, results[1].Document.Content)
+	}
+	if results[0].Similarity != 0.85 {
+		t.Errorf("Expected the highest similarity of b, got %f", results[0].Similarity)
+	}
+	if results[0].Score <= results[1].Score || results[0].Score != float32(fused[0].Score) {
+		t.Errorf("Expected fused scores on results, got %f and %f", results[0].Score, results[1].Score)
+	}
+	if fused[0].Ranks[0] != 2 || fused[0].Ranks[1] != 1 || fused[0].Ranks[2] != 2 {
+		t.Errorf("Unexpected ranks of b: %v", fused[0].Ranks)
+	}
+}
+
+// TestQueryWithMultiQuery tests searching generated alternatives of a query
+func TestQueryWithMultiQuery(t *testing.T) {
+	docs := map[string]models.Document{
+		"install go":        models.NewDocument("Download Go from go.dev.", nil),
+		"go setup on linux": models.NewDocument("Extract the tarball.", nil),
+		"configure gopath":  models.NewDocument("Set GOPATH.", nil),
+	}
+
+	var mu sync.Mutex
+	var searched []string
+	mockEmbedding := &MockEmbeddingService{
+		GenerateEmbeddingFunc: func(ctx context.Context, text string, options embeddings.Options) ([]float32, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			searched = append(searched, text)
+			return []float32{float32(len(searched))}, nil
+		},
+	}
+
+	// Every query finds its own document
+	mockDB := &MockVectorDB{
+		FindSimilarFunc: func(ctx context.Context, query models.VectorQuery) ([]models.SearchResult, error) {
+			mu.Lock()
+			text := searched[int(query.Vector[0])-1]
+			mu.Unlock()
+			return []models.SearchResult{{Document: docs[text], Similarity: 0.8}}, nil
+		},
+	}
+
+	server := newGeminiTestServer(t, func(request GeminiGenerationRequest) string {
+		if strings.Contains(request.Contents[0].Parts[0].Text, "different search queries") {
+			return "go setup on linux\nconfigure gopath"
+		}
+		return "Answer"
+	})
+
+	mockConfig := &config.GeminiConfig{APIKey: "test-api-key", TextModel: "test-model"}
+	ragService, _ := NewRAGService(mockDB, mockEmbedding, mockConfig)
+	ragService.(*DefaultRAGService).generator = newTestGeminiGenerator(mockConfig, server.URL)
+
+	response, err := ragService.QueryWithOptions(context.Background(), models.RAGQuery{
+		Query:      "install go",
+		MultiQuery: 2,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(searched) != 3 {
+		t.Errorf("Expected 3 searches, got %v", searched)
+	}
+	if len(response.Documents) != 3 {
+		t.Errorf("Expected the documents of all queries, got %d", len(response.Documents))
+	}
+
+	metadata := response.Metadata.(map[string]interface{})
+	rewrite, ok := metadata["query_rewrite"].(*QueryRewrite)
+	if !ok {
+		t.Fatalf("Expected query rewrite in metadata, got %v", metadata)
+	}
+	if strings.Join(rewrite.Queries, "|") != "install go|go setup on linux|configure gopath" {
+		t.Errorf("Unexpected queries: %v", rewrite.Queries)
+	}
+}
+
+// TestQueryWithMultiQueryFailedAlternative tests that a failed search of a
+// generated alternative is skipped while the original query must succeed
+func TestQueryWithMultiQueryFailedAlternative(t *testing.T) {
+	doc := models.NewDocument("Download Go from go.dev.", nil)
+
+	failOriginal := false
+	mockEmbedding := &MockEmbeddingService{
+		GenerateEmbeddingFunc: func(ctx context.Context, text string, options embeddings.Options) ([]float32, error) {
+			if text == "go setup on linux" || (failOriginal && text == "install go") {
+				return nil, errors.New("quota exceeded")
+			}
+			return []float32{0.1}, nil
+		},
+	}
+	mockDB := &MockVectorDB{
+		FindSimilarFunc: func(ctx context.Context, query models.VectorQuery) ([]models.SearchResult, error) {
+			return []models.SearchResult{{Document: doc, Similarity: 0.8}}, nil
+		},
+	}
+
+	server := newGeminiTestServer(t, func(request GeminiGenerationRequest) string {
+		if strings.Contains(request.Contents[0].Parts[0].Text, "different search queries") {
+			return "go setup on linux\nconfigure gopath"
+		}
+		return "Answer"
+	})
+
+	mockConfig := &config.GeminiConfig{APIKey: "test-api-key", TextModel: "test-model"}
+	ragService, _ := NewRAGService(mockDB, mockEmbedding, mockConfig)
+	ragService.(*DefaultRAGService).generator = newTestGeminiGenerator(mockConfig, server.URL)
+
+	request := models.RAGQuery{Query: "install go", MultiQuery: 2, NoCache: true}
+	response, err := ragService.QueryWithOptions(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Expected the failed alternative to be skipped, got %v", err)
+	}
+	if len(response.Documents) != 1 || response.Documents[0].ID != doc.ID {
+		t.Errorf("Expected the document of the successful queries, got %+v", response.Documents)
+	}
+
+	failOriginal = true
+	if _, err := ragService.QueryWithOptions(context.Background(), request); err == nil {
+		t.Error("Expected error when the original query fails")
+	}
+}